@@ -100,6 +100,7 @@ import (
 	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/font"              // 渲染任意文字到图片
 	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/fortune"           // 运势
 	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/funny"             // 笑话
+	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/gamerank"          // 小游戏排行与赛季
 	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/genshin"           // 原神抽卡
 	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/gif"               // 制图
 	_ "github.com/FloatTech/ZeroBot-Plugin/plugin/github"            // 搜索GitHub仓库
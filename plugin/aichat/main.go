@@ -4,10 +4,13 @@ package aichat
 import (
 	"encoding/json"
 	"math/rand"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/RomiChan/syncx"
 	"github.com/fumiama/deepinfra"
+	"github.com/fumiama/deepinfra/model"
 	goba "github.com/fumiama/go-onebot-agent"
 	"github.com/sirupsen/logrus"
 
@@ -16,10 +19,14 @@ import (
 	"github.com/wdvxdr1123/ZeroBot/message"
 
 	"github.com/FloatTech/AnimeAPI/airecord"
-	"github.com/FloatTech/floatbox/process"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/chat"
 	"github.com/FloatTech/zbputils/control"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/persona"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/provider"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/tool"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
 )
 
 var (
@@ -28,31 +35,108 @@ var (
 		DisableOnDefault: false,
 		Extra:            control.ExtraFromString("aichat"),
 		Brief:            "大模型聊天和Agent",
-		Help:             "- (随意聊天, 概率匹配)",
+		Help: "- (随意聊天, 概率匹配)\n" +
+			"- AI记忆查看\n" +
+			"- AI记忆遗忘 [编号|全部]\n" +
+			"Tips: 会从你的发言中定期提炼长期记忆, 并在与你聊天时参考 (Agent模式下不使用)\n" +
+			"Agent模式下可按权限调用已接入的插件功能, 如查钱包、翻译、点歌",
 
 		PrivateDataFolder: "aichat",
 	}).ApplySingle(single.New(
-		single.WithKeyFn(func(ctx *zero.Ctx) int64 {
-			if ctx.Event.GroupID == 0 {
-				return -ctx.Event.UserID
-			}
-			return ctx.Event.GroupID
-		}),
+		single.WithKeyFn(groupkey),
 		// no post option, silently quit
 	))
 )
 
 var (
 	fastfailnorecord = false
+	// toolagents 已加载工具权限表的 agent
+	toolagents = syncx.Map[*goba.Agent, struct{}]{}
 )
 
+// groupkey 私聊时为 -QQ
+func groupkey(ctx *zero.Ctx) int64 {
+	if ctx.Event.GroupID == 0 {
+		return -ctx.Event.UserID
+	}
+	return ctx.Event.GroupID
+}
+
+// loadtools 为 agent 加载包含插件工具的权限表
+func loadtools(ag *goba.Agent) error {
+	if _, ok := toolagents.Load(ag); ok {
+		return nil
+	}
+	path := en.DataFolder() + "actions.yaml"
+	err := tool.WritePermTable(path)
+	if err != nil {
+		return err
+	}
+	err = ag.LoadPermTable(path)
+	if err != nil {
+		return err
+	}
+	toolagents.Store(ag, struct{}{})
+	return nil
+}
+
 func init() {
+	go func() {
+		err := mem.open(en.DataFolder() + "memory.db")
+		if err != nil {
+			logrus.Warnln("[aichat] 打开记忆数据库失败:", err)
+		}
+		err = usage.Open(en.DataFolder() + "usage.db")
+		if err != nil {
+			logrus.Warnln("[aichat] 打开用量数据库失败:", err)
+		}
+		err = persona.Open(en.DataFolder() + "persona.db")
+		if err != nil {
+			logrus.Warnln("[aichat] 打开人格数据库失败:", err)
+		}
+		err = provider.Open(en.DataFolder() + "provider.db")
+		if err != nil {
+			logrus.Warnln("[aichat] 打开备用接口数据库失败:", err)
+		}
+	}()
+	en.OnFullMatch("AI记忆查看").SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		facts, err := mem.list(ctx.Event.UserID)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(facts) == 0 {
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("我对你还没有什么记忆哦~多和我聊聊吧"))
+			return
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("我记得关于你的这些事:\n", memstring(facts)))
+	})
+	en.OnRegex(`^AI记忆遗忘\s*(\d+|全部)$`).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		arg := ctx.State["regex_matched"].([]string)[1]
+		if arg == "全部" {
+			err := mem.forgetall(ctx.Event.UserID)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("已经忘掉关于你的所有事情了"))
+			return
+		}
+		i, _ := strconv.Atoi(arg)
+		err := mem.forget(ctx.Event.UserID, i)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("已经忘掉第", i, "条记忆了"))
+	})
 	en.OnMessage(chat.EnsureConfig, func(ctx *zero.Ctx) bool {
 		stor, ok := ctx.State[zero.StateKeyPrefixKeep+"aichatcfg_stor__"].(chat.Storage)
 		if !ok {
 			logrus.Warnln("ERROR: cannot get stor")
 			return false
 		}
+		mem.record(ctx.Event.UserID, ctx.Event.Sender.Name(), ctx.ExtractPlainText())
 		mp := ctx.State[control.StateKeySyncxState].(*syncx.Map[string, any])
 		if _, ok := mp.Load(chat.StateKeyAgentHooked); !ok && !stor.NoAgent() {
 			logrus.Infoln("[aichat] skip agent for ctx has not been hooked by agent")
@@ -63,6 +147,9 @@ func init() {
 			return false
 		}
 		rate := stor.Rate()
+		if p := persona.Of(groupkey(ctx), time.Now()); p != nil && p.Rate > 0 {
+			rate = uint8(p.Rate)
+		}
 		if !ctx.Event.IsToMe && rand.Intn(100) >= int(rate) {
 			return false
 		}
@@ -71,19 +158,41 @@ func init() {
 		}
 		return true
 	}).SetBlock(false).Handle(func(ctx *zero.Ctx) {
-		gid := ctx.Event.GroupID
-		if gid == 0 {
-			gid = -ctx.Event.UserID
-		}
+		gid := groupkey(ctx)
 		stor := ctx.State[zero.StateKeyPrefixKeep+"aichatcfg_stor__"].(chat.Storage)
 		temperature := stor.Temp()
 		topp, maxn := chat.AC.MParams()
+		sysp := chat.AC.SystemP
+		recCfg := airecord.GetConfig()
+		if p := persona.Of(gid, time.Now()); p != nil {
+			logrus.Debugln("[aichat] using persona", p.Name, "in", gid)
+			if p.Temp > 0 {
+				temperature = float32(p.Temp) / 100
+			}
+			if p.TopP > 0 {
+				topp = p.TopP
+			}
+			// agent 的系统提示词由 Agent性格/性别 决定, 人格的系统提示词只用于普通聊天
+			if p.SystemP != "" {
+				sysp = p.SystemP
+			}
+			if p.Voice != "" {
+				recCfg.ModelID = p.Voice
+			}
+		}
 		mp := ctx.State[control.StateKeySyncxState].(*syncx.Map[string, any])
+		uid := ctx.Event.UserID
+		if err := usage.Check(ctx.Event.GroupID, uid, false); err != nil {
+			if ctx.Event.IsToMe {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(err))
+			}
+			return
+		}
 
 		logrus.Debugln("[aichat] agent mode test: noagent", stor.NoAgent(), "hasapi", chat.AC.AgentAPI != "", "hasmodel", chat.AC.AgentModelName != "")
 		if !stor.NoAgent() && chat.AC.AgentAPI != "" && chat.AC.AgentModelName != "" && chat.AC.Key != "" {
 			logrus.Debugln("[aichat] enter agent mode")
-			x := deepinfra.NewAPI(chat.AC.AgentAPI, string(chat.AC.AgentKey))
+			x := usage.API(chat.AC.AgentAPI, string(chat.AC.AgentKey), gid, uid, "aichat", chat.AC.AgentModelName)
 			mod, err := chat.AC.Type.Protocol(chat.AC.AgentModelName, temperature, topp, maxn)
 			if err != nil {
 				logrus.Warnln("ERROR: ", err)
@@ -102,13 +211,19 @@ func init() {
 			}
 			ag := chat.AgentOf(ctx.Event.SelfID, c.Service)
 			logrus.Debugln("[aichat] got agent")
+			if tool.Len() > 0 {
+				if err := loadtools(ag); err != nil {
+					logrus.Warnln("[aichat] load agent tools err:", err)
+				}
+			}
 			if chat.AC.ImageAPI != "" && !ag.CanViewImage() {
 				mod, err := chat.AC.ImageType.Protocol(chat.AC.ImageModelName, temperature, topp, maxn)
 				if err != nil {
 					logrus.Warnln("ERROR: ", err)
 					return
 				}
-				ag.SetViewImageAPI(deepinfra.NewAPI(chat.AC.ImageAPI, string(chat.AC.ImageKey)), mod)
+				// agent 为全部群共用, 识图用量无法归属到具体的群和人
+				ag.SetViewImageAPI(usage.API(chat.AC.ImageAPI, string(chat.AC.ImageKey), 0, 0, "aichat", chat.AC.ImageModelName), mod)
 				logrus.Debugln("[aichat] agent set img")
 			}
 			ctx.NoTimeout()
@@ -128,6 +243,12 @@ func init() {
 					}
 					logrus.Debugln("[chat] agent triggered", gid, "add requ:", &req)
 					ag.AddRequest(gid, &req)
+					if t, ok := tool.Lookup(req.Action); ok {
+						rsp := t.Call(ctx, role, req.Params)
+						logrus.Debugln("[chat] agent triggered", gid, "add tool resp:", rsp)
+						ag.AddResponse(gid, rsp)
+						continue
+					}
 					rsp := ctx.CallAction(req.Action, req.Params)
 					logrus.Debugln("[chat] agent triggered", gid, "add resp:", &rsp)
 					ag.AddResponse(gid, &goba.APIResponse{
@@ -146,49 +267,36 @@ func init() {
 			logrus.Debugln("[aichat] agent fell back to normal chat")
 		}
 
-		x := deepinfra.NewAPI(chat.AC.API, string(chat.AC.Key))
-		mod, err := chat.AC.Type.Protocol(chat.AC.ModelName, temperature, topp, maxn)
-		if err != nil {
-			logrus.Warnln("ERROR: ", err)
-			return
+		// agent 为全部群共用且自行构造系统提示词, 记忆只用于普通聊天
+		sysp += mem.prompt(uid, ctx.Event.Sender.Name())
+		m := provider.Meter{GrpID: gid, UserID: uid, Plugin: "aichat"}
+		build := func(p model.Protocol) deepinfra.Model {
+			return chat.GetChatContext(p, gid, sysp, bool(chat.AC.NoSystemP))
 		}
-		data, err := x.Request(chat.GetChatContext(mod, gid, chat.AC.SystemP, bool(chat.AC.NoSystemP)))
-		if err != nil {
-			logrus.Warnln("[aichat] post err:", err)
-			return
-		}
-
-		txt := chat.Sanitize(strings.Trim(data, "\n 　"))
-		if len(txt) > 0 {
-			chat.AddChatReply(gid, txt)
-			nick := zero.BotConfig.NickName[rand.Intn(len(zero.BotConfig.NickName))]
-			txt = strings.ReplaceAll(txt, "{name}", ctx.CardOrNickName(ctx.Event.UserID))
-			txt = strings.ReplaceAll(txt, "{me}", nick)
-			id := any(nil)
-			if ctx.Event.IsToMe {
-				id = ctx.Event.MessageID
+		r := newreplier(ctx, stor, recCfg)
+		var err error
+		if provider.Streaming() {
+			seg := segmenter{emit: r.send}
+			_, err = provider.Stream(m, temperature, topp, maxn, build, seg.feed)
+			if err == nil {
+				seg.flush()
 			}
-			for _, t := range strings.Split(txt, "{segment}") {
-				if t == "" {
-					continue
-				}
-				logrus.Debugln("[aichat] 回复内容:", t)
-				recCfg := airecord.GetConfig()
-				record := ""
-				if !fastfailnorecord && !stor.NoRecord() {
-					record = ctx.GetAIRecord(recCfg.ModelID, recCfg.Customgid, t)
-					if record != "" {
-						ctx.SendChain(message.Record(record))
-						continue
-					}
-					fastfailnorecord = true
+		} else {
+			var data string
+			data, err = provider.Request(m, temperature, topp, maxn, build)
+			if err == nil {
+				for _, t := range strings.Split(chat.Sanitize(strings.Trim(data, "\n 　")), segsep) {
+					r.send(t)
 				}
-				if id != nil {
-					id = ctx.SendChain(message.Reply(id), message.Text(t))
-				} else {
-					id = ctx.SendChain(message.Text(t))
-				}
-				process.SleepAbout1sTo2s()
+			}
+		}
+		if txt := r.history(); txt != "" {
+			chat.AddChatReply(gid, txt)
+		}
+		if err != nil {
+			logrus.Warnln("[aichat] post err:", err)
+			if len(r.sent) == 0 && ctx.Event.IsToMe {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(err))
 			}
 		}
 	})
@@ -0,0 +1,253 @@
+package aichat
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fumiama/deepinfra"
+	"github.com/fumiama/deepinfra/model"
+	"github.com/sirupsen/logrus"
+
+	sql "github.com/FloatTech/sqlite"
+	"github.com/FloatTech/zbputils/chat"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/provider"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
+)
+
+const (
+	// memdistillsz 每积累这么多条发言提炼一次记忆
+	memdistillsz = 16
+	// memmaxn 每人最多保留的记忆条数
+	memmaxn = 24
+	// memlinemax 单条发言保留的最大字数
+	memlinemax = 200
+)
+
+var (
+	// errNoSuchMemory 找不到记忆
+	errNoSuchMemory = errors.New("没有这条记忆哦~")
+	// bulletre 模型可能输出的列表符号与编号
+	bulletre = regexp.MustCompile(`^(?:[-*•·]|\d+[.、)])\s*`)
+)
+
+const distillprompt = `你是一个记忆整理助手。下面给出用户「%s」已有的长期记忆, 以及该用户最近在聊天中的发言。
+请从发言中提炼值得长期记住的事实与偏好(如称呼、身份、兴趣、喜恶、习惯、与你的约定等), 与已有记忆合并去重, 删除过时或矛盾的条目。
+要求:
+1. 每行输出一条记忆, 每条不超过30字, 最多%d条。
+2. 只输出记忆本身, 不要编号, 不要输出任何解释。
+3. 不要记录一次性的闲聊内容, 也不要编造发言中没有的信息。
+4. 如果没有新的值得记住的内容, 原样输出已有记忆; 若已有记忆也为空, 输出"无"。`
+
+// memory 从对话中提炼出的用户长期记忆
+type memory struct {
+	ID   int64  `db:"id"`   // ID 记录时的 unix 纳秒
+	UID  int64  `db:"uid"`  // UID 记忆所属的用户
+	Fact string `db:"fact"` // Fact 记忆内容
+	Time int64  `db:"time"` // Time 提炼时的 unix 时间
+}
+
+// memstore 长期记忆存储与待提炼的发言缓冲
+type memstore struct {
+	sync.Mutex
+	db sql.Sqlite
+	// buf uid <-> 待提炼的发言
+	buf map[int64][]string
+	// busy uid <-> 正在提炼
+	busy map[int64]struct{}
+}
+
+var mem = memstore{
+	buf:  make(map[int64][]string, 64),
+	busy: make(map[int64]struct{}, 8),
+}
+
+func (ms *memstore) open(path string) error {
+	ms.db = sql.New(path)
+	err := ms.db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	return ms.db.Create("memory", &memory{}, "CREATE INDEX IF NOT EXISTS idx_memory_uid ON memory(uid);")
+}
+
+// record 缓存用户发言, 积累足够后在后台提炼
+func (ms *memstore) record(uid int64, name, txt string) {
+	txt = strings.TrimSpace(txt)
+	if uid == 0 || txt == "" {
+		return
+	}
+	if rs := []rune(txt); len(rs) > memlinemax {
+		txt = string(rs[:memlinemax])
+	}
+	ms.Lock()
+	lines := append(ms.buf[uid], txt)
+	_, isbusy := ms.busy[uid]
+	if len(lines) < memdistillsz || isbusy || chat.AC.Key == "" {
+		if len(lines) > memdistillsz*2 {
+			lines = lines[len(lines)-memdistillsz*2:]
+		}
+		ms.buf[uid] = lines
+		ms.Unlock()
+		return
+	}
+	delete(ms.buf, uid)
+	ms.busy[uid] = struct{}{}
+	ms.Unlock()
+	go func() {
+		defer func() {
+			ms.Lock()
+			delete(ms.busy, uid)
+			ms.Unlock()
+		}()
+		err := ms.distill(uid, name, lines)
+		if err != nil {
+			logrus.Warnln("[aichat] 提炼记忆失败:", err)
+		}
+	}()
+}
+
+// distill 调用配置的模型将发言合并进已有记忆
+func (ms *memstore) distill(uid int64, name string, lines []string) error {
+	facts, err := ms.list(uid)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("已有记忆:\n")
+	if len(facts) == 0 {
+		sb.WriteString("无\n")
+	}
+	for _, f := range facts {
+		sb.WriteString(f.Fact)
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("\n最近发言:\n")
+	for _, l := range lines {
+		sb.WriteString(l)
+		sb.WriteByte('\n')
+	}
+	if err := usage.Check(0, uid, false); err != nil {
+		return nil
+	}
+	topp, maxn := chat.AC.MParams()
+	data, err := provider.Request(provider.Meter{UserID: uid, Plugin: "aichat"}, 0.2, topp, maxn, func(p model.Protocol) deepinfra.Model {
+		return p.System(fmt.Sprintf(distillprompt, name, memmaxn)).User(model.NewContentText(sb.String()))
+	})
+	if err != nil {
+		return err
+	}
+	news := parsefacts(data)
+	if len(news) == 0 {
+		return nil
+	}
+	now := time.Now()
+	ms.Lock()
+	defer ms.Unlock()
+	err = ms.db.Del("memory", "WHERE uid = ?", uid)
+	if err != nil {
+		return err
+	}
+	for i, f := range news {
+		err = ms.db.Insert("memory", &memory{ID: now.UnixNano() + int64(i), UID: uid, Fact: f, Time: now.Unix()})
+		if err != nil {
+			return err
+		}
+	}
+	logrus.Debugln("[aichat] 提炼了", uid, "的", len(news), "条记忆")
+	return nil
+}
+
+// parsefacts 解析模型输出的记忆列表
+func parsefacts(data string) []string {
+	data = chat.Sanitize(data)
+	facts := make([]string, 0, memmaxn)
+	seen := make(map[string]struct{}, memmaxn)
+	for _, l := range strings.Split(data, "\n") {
+		l = strings.TrimSpace(bulletre.ReplaceAllString(strings.TrimSpace(l), ""))
+		if l == "" || l == "无" {
+			continue
+		}
+		if _, ok := seen[l]; ok {
+			continue
+		}
+		seen[l] = struct{}{}
+		facts = append(facts, l)
+		if len(facts) >= memmaxn {
+			break
+		}
+	}
+	return facts
+}
+
+// list 按记录先后列出用户的记忆
+func (ms *memstore) list(uid int64) ([]*memory, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	facts, err := sql.FindAll[memory](&ms.db, "memory", "WHERE uid = ? ORDER BY id ASC", uid)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return facts, err
+}
+
+// forgetall 删除用户的全部记忆
+func (ms *memstore) forgetall(uid int64) error {
+	ms.Lock()
+	defer ms.Unlock()
+	delete(ms.buf, uid)
+	return ms.db.Del("memory", "WHERE uid = ?", uid)
+}
+
+// forget 删除用户的第 i 条记忆, i 从 1 开始
+func (ms *memstore) forget(uid int64, i int) error {
+	facts, err := ms.list(uid)
+	if err != nil {
+		return err
+	}
+	if i < 1 || i > len(facts) {
+		return errNoSuchMemory
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	return ms.db.Del("memory", "WHERE id = ?", facts[i-1].ID)
+}
+
+// prompt 生成注入系统提示词的记忆段落
+func (ms *memstore) prompt(uid int64, name string) string {
+	facts, err := ms.list(uid)
+	if err != nil || len(facts) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n以下是你对正在说话的")
+	sb.WriteString(chat.NameL)
+	sb.WriteString(name)
+	sb.WriteString(chat.NameR)
+	sb.WriteString("的长期记忆, 请在合适时自然地运用, 不要逐条复述:\n")
+	for _, f := range facts {
+		sb.WriteString("- ")
+		sb.WriteString(f.Fact)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// memstring 记忆列表的可读描述
+func memstring(facts []*memory) string {
+	var sb strings.Builder
+	for i, f := range facts {
+		sb.WriteString(strconv.Itoa(i + 1))
+		sb.WriteString(". ")
+		sb.WriteString(f.Fact)
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("\n更新于 ")
+	sb.WriteString(time.Unix(facts[len(facts)-1].Time, 0).Format("2006-01-02 15:04"))
+	return sb.String()
+}
@@ -0,0 +1,316 @@
+// Package persona aichat 的人格预设
+package persona
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+)
+
+const (
+	personatable  = "persona"
+	activetable   = "active"
+	scheduletable = "schedule"
+)
+
+var (
+	// ErrNoSuchPersona 找不到人格
+	ErrNoSuchPersona = errors.New("没有这个人格哦~")
+	// ErrInvalidName 人格名不合法
+	ErrInvalidName = errors.New("人格名不能为空或包含空白")
+	// ErrInvalidPeriod 时段不合法
+	ErrInvalidPeriod = errors.New("时段格式应为 22:00-06:00")
+)
+
+// Persona 一个人格预设, 各项为零值时沿用全局或本群设置
+type Persona struct {
+	Name    string  `db:"name" json:"name"`                    // Name 人格名
+	SystemP string  `db:"sysp" json:"system_prompt,omitempty"` // SystemP 系统提示词
+	Temp    int64   `db:"temp" json:"temperature,omitempty"`   // Temp 温度 1~100
+	TopP    float32 `db:"topp" json:"top_p,omitempty"`         // TopP 0~1
+	Voice   string  `db:"voice" json:"voice,omitempty"`        // Voice airecord 语音模型ID
+	Rate    int64   `db:"rate" json:"rate,omitempty"`          // Rate 触发概率 1~100
+}
+
+// active 群当前选择的人格
+type active struct {
+	GrpID int64  `db:"gid"`
+	Name  string `db:"name"`
+}
+
+// Schedule 群在每天某时段内使用的人格
+type Schedule struct {
+	ID    string `db:"id"`    // ID gid_name
+	GrpID int64  `db:"gid"`   // GrpID 群号
+	Name  string `db:"name"`  // Name 人格名
+	From  int64  `db:"start"` // From 开始于当天第几分钟
+	To    int64  `db:"stop"`  // To 结束于当天第几分钟, 小于 From 时跨越午夜
+}
+
+// Period 时段的可读描述
+func (s *Schedule) Period() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", s.From/60, s.From%60, s.To/60, s.To%60)
+}
+
+// contains 时段是否包含当天第 m 分钟
+func (s *Schedule) contains(m int64) bool {
+	if s.From <= s.To {
+		return m >= s.From && m < s.To
+	}
+	return m >= s.From || m < s.To
+}
+
+var (
+	mu     sync.RWMutex
+	db     sql.Sqlite
+	opened bool
+)
+
+// Open 打开人格数据库, 由 aichat 在初始化时调用
+func Open(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	db = sql.New(path)
+	err := db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = db.Create(personatable, &Persona{})
+	if err != nil {
+		return err
+	}
+	err = db.Create(activetable, &active{})
+	if err != nil {
+		return err
+	}
+	err = db.Create(scheduletable, &Schedule{})
+	if err != nil {
+		return err
+	}
+	opened = true
+	return nil
+}
+
+func checkname(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// Save 保存人格预设, 同名时覆盖
+func Save(p *Persona) error {
+	if err := checkname(p.Name); err != nil {
+		return err
+	}
+	if p.Temp < 0 || p.Temp > 100 || p.Rate < 0 || p.Rate > 100 || p.TopP < 0 || p.TopP > 1 {
+		return errors.New("人格 " + p.Name + " 的参数超出范围")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Insert(personatable, p)
+}
+
+// Get 获取人格预设
+func Get(name string) (Persona, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, err := sql.Find[Persona](&db, personatable, "WHERE name = ?", name)
+	if err == sql.ErrNullResult {
+		err = ErrNoSuchPersona
+	}
+	return p, err
+}
+
+// List 按名称列出全部人格预设
+func List() ([]*Persona, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	ps, err := sql.FindAll[Persona](&db, personatable, "ORDER BY name ASC")
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return ps, err
+}
+
+// Delete 删除人格预设及引用它的选择与定时
+func Delete(name string) error {
+	if _, err := Get(name); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	err := db.Del(personatable, "WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	err = db.Del(activetable, "WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	return db.Del(scheduletable, "WHERE name = ?", name)
+}
+
+// Export 将人格预设导出为 JSON, 未指定名称时导出全部
+func Export(names ...string) ([]byte, error) {
+	var ps []*Persona
+	if len(names) == 0 {
+		var err error
+		ps, err = List()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range names {
+		p, err := Get(n)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, &p)
+	}
+	return json.MarshalIndent(ps, "", "  ")
+}
+
+// Import 导入单个人格或人格数组的 JSON, 返回导入的人格名
+func Import(data []byte) ([]string, error) {
+	data = []byte(strings.TrimSpace(string(data)))
+	var ps []*Persona
+	if len(data) > 0 && data[0] == '{' {
+		var p Persona
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		ps = append(ps, &p)
+	} else if err := json.Unmarshal(data, &ps); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ps))
+	for _, p := range ps {
+		if err := Save(p); err != nil {
+			return names, err
+		}
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+// Switch 切换群的人格, name 为空时恢复默认
+func Switch(gid int64, name string) error {
+	if name == "" {
+		mu.Lock()
+		defer mu.Unlock()
+		return db.Del(activetable, "WHERE gid = ?", gid)
+	}
+	if _, err := Get(name); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Insert(activetable, &active{GrpID: gid, Name: name})
+}
+
+// Active 群手动选择的人格名, 未选择时为空
+func Active(gid int64) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	a, err := sql.Find[active](&db, activetable, "WHERE gid = ?", gid)
+	if err != nil {
+		return ""
+	}
+	return a.Name
+}
+
+// ParsePeriod 解析形如 22:00-06:00 的时段
+func ParsePeriod(s string) (from, to int64, err error) {
+	a, b, ok := strings.Cut(strings.ReplaceAll(s, "：", ":"), "-")
+	if !ok {
+		return 0, 0, ErrInvalidPeriod
+	}
+	parse := func(x string) (int64, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(x))
+		if err != nil {
+			return 0, ErrInvalidPeriod
+		}
+		return int64(t.Hour()*60 + t.Minute()), nil
+	}
+	from, err = parse(a)
+	if err != nil {
+		return
+	}
+	to, err = parse(b)
+	if err == nil && from == to {
+		err = ErrInvalidPeriod
+	}
+	return
+}
+
+// SetSchedule 设置群在 [from, to) 时段内使用人格 name
+func SetSchedule(gid int64, name string, from, to int64) error {
+	if _, err := Get(name); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Insert(scheduletable, &Schedule{
+		ID:    fmt.Sprintf("%d_%s", gid, name),
+		GrpID: gid,
+		Name:  name,
+		From:  from,
+		To:    to,
+	})
+}
+
+// DelSchedule 删除群的人格定时, name 为空时全部删除
+func DelSchedule(gid int64, name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" {
+		return db.Del(scheduletable, "WHERE gid = ?", gid)
+	}
+	return db.Del(scheduletable, "WHERE id = ?", fmt.Sprintf("%d_%s", gid, name))
+}
+
+// Schedules 列出群的人格定时
+func Schedules(gid int64) ([]*Schedule, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	ss, err := sql.FindAll[Schedule](&db, scheduletable, "WHERE gid = ? ORDER BY start ASC", gid)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return ss, err
+}
+
+// Of 群在 t 时刻生效的人格, 定时优先于手动选择, 都没有时返回 nil
+func Of(gid int64, t time.Time) *Persona {
+	if !opened {
+		return nil
+	}
+	name := ""
+	ss, err := Schedules(gid)
+	if err == nil {
+		m := int64(t.Hour()*60 + t.Minute())
+		for _, s := range ss {
+			if s.contains(m) {
+				name = s.Name
+				break
+			}
+		}
+	}
+	if name == "" {
+		name = Active(gid)
+	}
+	if name == "" {
+		return nil
+	}
+	p, err := Get(name)
+	if err != nil {
+		return nil
+	}
+	return &p
+}
@@ -0,0 +1,60 @@
+package persona
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	err := Open(t.TempDir() + "/persona.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := Import([]byte(`[
+		{"name": "白天", "system_prompt": "你很活泼", "temperature": 80, "rate": 10},
+		{"name": "夜间", "system_prompt": "你很困", "voice": "lucy-voice-f1"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatal("unexpected imported", names)
+	}
+	if _, err = Import([]byte(`{"name": "坏掉", "temperature": 200}`)); err == nil {
+		t.Fatal("invalid temperature accepted")
+	}
+	from, to, err := ParsePeriod("22:00-06:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SetSchedule(1, "夜间", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 1, h, m, 0, 0, time.Local) }
+	if p := Of(1, at(12, 0)); p != nil {
+		t.Fatal("unexpected persona", p.Name)
+	}
+	err = Switch(1, "白天")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		h, m int
+		name string
+	}{{12, 0, "白天"}, {23, 0, "夜间"}, {6, 29, "夜间"}, {6, 30, "白天"}} {
+		if p := Of(1, at(c.h, c.m)); p == nil || p.Name != c.name {
+			t.Fatal("unexpected persona at", c.h, c.m, p)
+		}
+	}
+	if p := Of(2, at(23, 0)); p != nil {
+		t.Fatal("schedule leaked to other group")
+	}
+	err = Delete("夜间")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := Of(1, at(23, 0)); p == nil || p.Name != "白天" {
+		t.Fatal("schedule not deleted with persona", p)
+	}
+}
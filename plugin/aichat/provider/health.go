@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// tripfails 连续失败这么多次后熔断
+	tripfails = 3
+	// mincooldown 首次熔断的时长, 之后每次失败翻倍
+	mincooldown = 30 * time.Second
+	// maxcooldown 最长熔断时长
+	maxcooldown = 10 * time.Minute
+)
+
+// Health 接口的健康状态
+type Health struct {
+	Name      string
+	OK        int64         // OK 累计成功次数
+	Failed    int64         // Failed 累计失败次数
+	Fails     int           // Fails 连续失败次数
+	OpenUntil time.Time     // OpenUntil 熔断到何时
+	LastErr   string        // LastErr 最近一次错误
+	Latency   time.Duration // Latency 最近一次成功的耗时
+
+	probing bool // probing 熔断结束后是否已有一个试探请求在进行
+}
+
+// Open 是否处于熔断中
+func (h *Health) Open(now time.Time) bool {
+	return now.Before(h.OpenUntil)
+}
+
+type breaker struct {
+	sync.Mutex
+	m map[string]*Health
+}
+
+var br = breaker{m: make(map[string]*Health, 8)}
+
+// allow 接口当前是否允许调用, 熔断结束后只放行一个试探, 其结果返回前拒绝其它请求
+func (b *breaker) allow(name string, now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+	h, ok := b.m[name]
+	switch {
+	case !ok || h.Fails < tripfails:
+		return true
+	case h.Open(now) || h.probing:
+		return false
+	default:
+		h.probing = true
+		return true
+	}
+}
+
+// reopen 接口下次可用的时间
+func (b *breaker) reopen(name string) time.Time {
+	b.Lock()
+	defer b.Unlock()
+	if h, ok := b.m[name]; ok {
+		return h.OpenUntil
+	}
+	return time.Time{}
+}
+
+// report 记录一次调用的结果
+func (b *breaker) report(name string, err error, cost time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	h, ok := b.m[name]
+	if !ok {
+		h = &Health{Name: name}
+		b.m[name] = h
+	}
+	h.probing = false
+	if err == nil {
+		h.OK++
+		h.Fails = 0
+		h.OpenUntil = time.Time{}
+		h.Latency = cost
+		return
+	}
+	h.Failed++
+	h.Fails++
+	h.LastErr = err.Error()
+	if h.Fails >= tripfails {
+		cd := mincooldown << min(h.Fails-tripfails, 8)
+		h.OpenUntil = time.Now().Add(min(cd, maxcooldown))
+	}
+}
+
+// Status 列出全部接口的健康状态
+func Status() []Health {
+	br.Lock()
+	hs := make([]Health, 0, len(br.m))
+	for _, h := range br.m {
+		hs = append(hs, *h)
+	}
+	br.Unlock()
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Name < hs[j].Name })
+	return hs
+}
+
+// Reset 清除全部熔断状态
+func Reset() {
+	br.Lock()
+	br.m = make(map[string]*Health, 8)
+	br.Unlock()
+}
@@ -0,0 +1,207 @@
+// Package provider 大模型接口的备用列表、熔断与流式请求
+package provider
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+	"github.com/fumiama/deepinfra/model"
+
+	"github.com/FloatTech/zbputils/chat"
+)
+
+const (
+	fallbacktable = "fallback"
+	settingtable  = "setting"
+)
+
+// 配置项
+const (
+	settingnostream = "nostream"
+)
+
+var types = []string{"OpenAI", "OLLaMA", "GenAI"}
+
+var (
+	// ErrNoSuchFallback 找不到备用接口
+	ErrNoSuchFallback = errors.New("没有这个备用接口")
+	// ErrUnknownType 未知接口类型
+	ErrUnknownType = errors.New("接口类型应为 " + strings.Join(types, "|"))
+)
+
+// fallback 备用接口, 按 ID 顺序尝试
+type fallback struct {
+	ID    int64  `db:"id"`    // ID 添加时的 unix 纳秒
+	Type  string `db:"type"`  // Type OpenAI | OLLaMA | GenAI
+	API   string `db:"api"`   // API 接口地址
+	Key   string `db:"key"`   // Key 密钥
+	Model string `db:"model"` // Model 模型名
+}
+
+// setting 全局开关
+type setting struct {
+	Key   string `db:"key"`
+	Value bool   `db:"value"`
+}
+
+// Endpoint 一个可调用的大模型接口
+type Endpoint struct {
+	Type  string
+	API   string
+	Key   string
+	Model string
+}
+
+// Name 用于熔断与日志的接口名
+func (e *Endpoint) Name() string {
+	host := e.API
+	if u, err := url.Parse(e.API); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return e.Model + "@" + host
+}
+
+// protocol 按接口类型构造请求
+func (e *Endpoint) protocol(temp, topp float32, maxn uint) (model.Protocol, error) {
+	switch e.Type {
+	case "OpenAI":
+		return model.NewOpenAI(e.Model, chat.AC.Separator, temp, topp, maxn), nil
+	case "OLLaMA":
+		return model.NewOLLaMA(e.Model, chat.AC.Separator, temp, topp, maxn), nil
+	case "GenAI":
+		return model.NewGenAI(e.Model, temp, topp, maxn), nil
+	default:
+		return nil, ErrUnknownType
+	}
+}
+
+var (
+	mu     sync.RWMutex
+	db     sql.Sqlite
+	opened bool
+)
+
+// Open 打开备用接口数据库, 由 aichat 在初始化时调用
+func Open(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	db = sql.New(path)
+	err := db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = db.Create(fallbacktable, &fallback{})
+	if err != nil {
+		return err
+	}
+	err = db.Create(settingtable, &setting{})
+	if err != nil {
+		return err
+	}
+	opened = true
+	return nil
+}
+
+// NormType 将大小写不敏感的接口类型规范化
+func NormType(typ string) (string, error) {
+	for _, t := range types {
+		if strings.EqualFold(t, typ) {
+			return t, nil
+		}
+	}
+	return "", ErrUnknownType
+}
+
+// Add 在末尾添加备用接口
+func Add(typ, api, key, modn string) error {
+	typ, err := NormType(typ)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Insert(fallbacktable, &fallback{
+		ID:    time.Now().UnixNano(),
+		Type:  typ,
+		API:   api,
+		Key:   key,
+		Model: modn,
+	})
+}
+
+// Del 删除第 i 个备用接口
+func Del(i int) error {
+	fs, err := list()
+	if err != nil {
+		return err
+	}
+	if i <= 0 || i > len(fs) {
+		return ErrNoSuchFallback
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Del(fallbacktable, "WHERE id = ?", fs[i-1].ID)
+}
+
+func list() ([]*fallback, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !opened {
+		return nil, nil
+	}
+	fs, err := sql.FindAll[fallback](&db, fallbacktable, "ORDER BY id ASC")
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return fs, err
+}
+
+// Fallbacks 按顺序列出备用接口
+func Fallbacks() ([]Endpoint, error) {
+	fs, err := list()
+	if err != nil {
+		return nil, err
+	}
+	eps := make([]Endpoint, len(fs))
+	for i, f := range fs {
+		eps[i] = Endpoint{Type: f.Type, API: f.API, Key: f.Key, Model: f.Model}
+	}
+	return eps, nil
+}
+
+// Endpoints 主接口与备用接口, 按尝试顺序排列
+func Endpoints() []Endpoint {
+	eps := []Endpoint{{
+		Type:  chat.AC.Type.String(),
+		API:   chat.AC.API,
+		Key:   string(chat.AC.Key),
+		Model: chat.AC.ModelName,
+	}}
+	fs, err := Fallbacks()
+	if err == nil {
+		eps = append(eps, fs...)
+	}
+	return eps
+}
+
+// Streaming 是否使用流式输出, 默认开启
+func Streaming() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !opened {
+		return false
+	}
+	s, err := sql.Find[setting](&db, settingtable, "WHERE key = ?", settingnostream)
+	return err != nil || !s.Value
+}
+
+// SetStreaming 设置是否使用流式输出
+func SetStreaming(on bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Insert(settingtable, &setting{Key: settingnostream, Value: !on})
+}
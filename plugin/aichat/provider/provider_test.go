@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSepfilter(t *testing.T) {
+	f := newsepfilter("</think>", true)
+	var sb strings.Builder
+	for _, d := range []string{"<think>嗯", "...</th", "ink>\n\n你好", "呀"} {
+		sb.WriteString(f.feed(d))
+	}
+	sb.WriteString(f.flush())
+	if sb.String() != "你好呀" {
+		t.Fatal(sb.String())
+	}
+	f = newsepfilter("</think>", true)
+	if f.feed("没有分隔符") != "" || f.flush() != "没有分隔符" {
+		t.Fatal("unexpected output without separator")
+	}
+}
+
+func TestScan(t *testing.T) {
+	var chunks []string
+	err := scan(strings.NewReader("data: {\"a\":1}\n\n: ping\nevent: x\ndata: [DONE]\ndata: {\"b\":2}\n"), func(b []byte) bool {
+		chunks = append(chunks, string(b))
+		return true
+	})
+	if err != nil || len(chunks) != 1 || chunks[0] != `{"a":1}` {
+		t.Fatal(err, chunks)
+	}
+	if delta("OpenAI", []byte(`{"choices":[{"delta":{"content":"你"}}]}`)) != "你" {
+		t.Fatal("openai delta")
+	}
+	if delta("OLLaMA", []byte(`{"message":{"content":"好"}}`)) != "好" {
+		t.Fatal("ollama delta")
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	Reset()
+	defer Reset()
+	now := time.Now()
+	for i := 0; i < tripfails-1; i++ {
+		br.report("a", errors.New("x"), 0)
+	}
+	if !br.allow("a", now) {
+		t.Fatal("tripped too early")
+	}
+	br.report("a", errors.New("x"), 0)
+	if br.allow("a", now) {
+		t.Fatal("should be open")
+	}
+	later := now.Add(mincooldown + time.Second)
+	if !br.allow("a", later) {
+		t.Fatal("should half open after cooldown")
+	}
+	if br.allow("a", later) {
+		t.Fatal("should allow only one probe while half open")
+	}
+	br.report("a", errors.New("x"), 0)
+	if br.allow("a", later) {
+		t.Fatal("should open again after the probe fails")
+	}
+	if !br.allow("a", time.Now().Add(2*mincooldown+time.Second)) {
+		t.Fatal("should half open after the doubled cooldown")
+	}
+	br.report("a", nil, time.Second)
+	if !br.allow("a", now) || Status()[0].OK != 1 {
+		t.Fatal("should close on success")
+	}
+}
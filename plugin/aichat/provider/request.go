@@ -0,0 +1,285 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fumiama/deepinfra"
+	"github.com/fumiama/deepinfra/model"
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/FloatTech/zbputils/chat"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
+)
+
+// streamtimeout 单次流式请求的最长时间
+const streamtimeout = 5 * time.Minute
+
+var (
+	// ErrAllFailed 全部接口都调用失败
+	ErrAllFailed = errors.New("大模型接口暂时都不可用, 请稍后再试")
+	// errStopped 回调要求停止读取
+	errStopped = errors.New("stream stopped by callback")
+)
+
+// Meter 调用的用量归属
+type Meter struct {
+	GrpID  int64
+	UserID int64
+	Plugin string
+}
+
+// Builder 用接口对应的协议构造请求
+type Builder func(p model.Protocol) deepinfra.Model
+
+// partialError 流式输出了部分内容后失败, 不能再换接口重试
+type partialError struct{ error }
+
+func (e partialError) Unwrap() error { return e.error }
+
+// try 按顺序尝试可用的接口直到成功, 全部熔断时尝试最早恢复的一个
+func try(f func(e *Endpoint) error) error {
+	eps := Endpoints()
+	now := time.Now()
+	tried := false
+	for i := range eps {
+		e := &eps[i]
+		if !br.allow(e.Name(), now) {
+			continue
+		}
+		tried = true
+		err := call(e, f)
+		if err == nil {
+			return nil
+		}
+		var perr partialError
+		if errors.As(err, &perr) {
+			return perr.error
+		}
+	}
+	if tried {
+		return ErrAllFailed
+	}
+	soonest := &eps[0]
+	for i := range eps[1:] {
+		if br.reopen(eps[i+1].Name()).Before(br.reopen(soonest.Name())) {
+			soonest = &eps[i+1]
+		}
+	}
+	err := call(soonest, f)
+	if err == nil {
+		return nil
+	}
+	var perr partialError
+	if errors.As(err, &perr) {
+		return perr.error
+	}
+	return ErrAllFailed
+}
+
+// call 调用接口并记录健康状态
+func call(e *Endpoint, f func(e *Endpoint) error) error {
+	start := time.Now()
+	err := f(e)
+	br.report(e.Name(), err, time.Since(start))
+	if err != nil {
+		logrus.Warnln("[provider] 调用", e.Name(), "失败:", err)
+	}
+	return err
+}
+
+// Request 依次尝试主接口与备用接口, 返回第一个成功的回复
+func Request(m Meter, temp, topp float32, maxn uint, build Builder) (reply string, err error) {
+	err = try(func(e *Endpoint) error {
+		mod, err := e.protocol(temp, topp, maxn)
+		if err != nil {
+			return err
+		}
+		x := usage.API(e.API, e.Key, m.GrpID, m.UserID, m.Plugin, e.Model)
+		reply, err = x.Request(build(mod))
+		return err
+	})
+	return
+}
+
+// Stream 流式请求, 每收到一段文本调用 ondelta, ondelta 返回 false 时停止读取.
+// 尚未输出内容时失败会换下一个接口重试, 返回完整回复.
+func Stream(m Meter, temp, topp float32, maxn uint, build Builder, ondelta func(string) bool) (reply string, err error) {
+	err = try(func(e *Endpoint) error {
+		mod, err := e.protocol(temp, topp, maxn)
+		if err != nil {
+			return err
+		}
+		emitted := false
+		reply, err = stream(e, m, build(mod), func(s string) bool {
+			emitted = true
+			return ondelta(s)
+		})
+		if err != nil && emitted {
+			return partialError{err}
+		}
+		return err
+	})
+	return
+}
+
+// stream 向单个接口发起流式请求
+func stream(e *Endpoint, m Meter, mod deepinfra.Model, ondelta func(string) bool) (string, error) {
+	var body map[string]json.RawMessage
+	err := json.NewDecoder(mod.Body()).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+	api := mod.API(e.API, e.Key)
+	switch e.Type {
+	case "OpenAI":
+		body["stream"] = json.RawMessage(`true`)
+		body["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	case "OLLaMA":
+		body["stream"] = json.RawMessage(`true`)
+	case "GenAI":
+		api = strings.Replace(api, ":generateContent?", ":streamGenerateContent?alt=sse&", 1)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), streamtimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", api, strings.NewReader(string(data)))
+	if err != nil {
+		return "", err
+	}
+	mod.Header(e.Key, req.Header)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		sb := strings.Builder{}
+		sb.WriteString(resp.Status)
+		sb.WriteByte(' ')
+		_, _ = io.Copy(&sb, io.LimitReader(resp.Body, 1024))
+		return "", errors.New(sb.String())
+	}
+	var (
+		full               strings.Builder
+		prompt, completion int64
+	)
+	sep := newsepfilter(chat.AC.Separator, e.Type != "GenAI")
+	err = scan(resp.Body, func(chunk []byte) bool {
+		if p, c := usage.Tokens(chunk); p+c > 0 {
+			prompt, completion = p, c
+		}
+		d := delta(e.Type, chunk)
+		if d == "" {
+			return true
+		}
+		full.WriteString(d)
+		if out := sep.feed(d); out != "" {
+			return ondelta(out)
+		}
+		return true
+	})
+	if rerr := usage.Record(m.GrpID, m.UserID, m.Plugin, e.Model, prompt, completion, 0); rerr != nil {
+		logrus.Warnln("[provider] record usage err:", rerr)
+	}
+	if err != nil && err != errStopped {
+		return "", err
+	}
+	if err == nil {
+		if out := sep.flush(); out != "" {
+			ondelta(out)
+		}
+	}
+	return model.CutLast(full.String(), sep.sep), nil
+}
+
+// scan 逐条读取 SSE 的 data 或 NDJSON 行
+func scan(r io.Reader, onchunk func([]byte) bool) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+		if after, ok := strings.CutPrefix(line, "data:"); ok {
+			line = strings.TrimSpace(after)
+		} else if strings.HasPrefix(line, "event:") || strings.HasPrefix(line, "id:") {
+			continue
+		}
+		if line == "[DONE]" {
+			return nil
+		}
+		if !onchunk([]byte(line)) {
+			return errStopped
+		}
+	}
+	return s.Err()
+}
+
+// delta 读取一个流式块中的新增文本
+func delta(typ string, chunk []byte) string {
+	r := gjson.ParseBytes(chunk)
+	switch typ {
+	case "OLLaMA":
+		return r.Get("message.content").String()
+	case "GenAI":
+		var sb strings.Builder
+		for _, p := range r.Get("candidates.0.content.parts.#.text").Array() {
+			sb.WriteString(p.String())
+		}
+		return sb.String()
+	default:
+		return r.Get("choices.0.delta.content").String()
+	}
+}
+
+// sepfilter 模拟 model.CutLast, 在见到分隔符前暂不输出
+type sepfilter struct {
+	sep  string
+	seen bool
+	buf  strings.Builder
+}
+
+func newsepfilter(sep string, enabled bool) *sepfilter {
+	if !enabled {
+		sep = ""
+	}
+	return &sepfilter{sep: sep, seen: sep == ""}
+}
+
+// feed 输入新增文本, 返回可以输出的部分
+func (f *sepfilter) feed(d string) string {
+	if f.seen {
+		return d
+	}
+	f.buf.WriteString(d)
+	s := f.buf.String()
+	i := strings.LastIndex(s, f.sep)
+	if i < 0 {
+		return ""
+	}
+	f.seen = true
+	f.buf.Reset()
+	return strings.TrimLeft(s[i+len(f.sep):], " \t\r\n")
+}
+
+// flush 流结束时, 若从未见到分隔符则输出全部
+func (f *sepfilter) flush() string {
+	if f.seen {
+		return ""
+	}
+	f.seen = true
+	return strings.TrimSpace(f.buf.String())
+}
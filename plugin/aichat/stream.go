@@ -0,0 +1,115 @@
+package aichat
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/FloatTech/AnimeAPI/airecord"
+	"github.com/FloatTech/floatbox/process"
+	"github.com/FloatTech/zbputils/chat"
+	"github.com/sirupsen/logrus"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+const segsep = "{segment}"
+
+// segmenter 将流式输出按 {segment} 切分, 与 chat.Sanitize 一致只保留首行
+type segmenter struct {
+	buf     strings.Builder
+	started bool
+	done    bool
+	emit    func(string)
+}
+
+// feed 输入新增文本, 遇到换行后返回 false 以停止读取
+func (s *segmenter) feed(d string) bool {
+	if s.done {
+		return false
+	}
+	if !s.started {
+		d = strings.TrimLeft(d, "\n 　")
+		if d == "" {
+			return true
+		}
+		s.started = true
+	}
+	s.buf.WriteString(d)
+	text := s.buf.String()
+	line, _, cut := strings.Cut(text, "\n")
+	segs := strings.Split(line, segsep)
+	for _, seg := range segs[:len(segs)-1] {
+		s.emit(chat.Sanitize(seg))
+	}
+	s.buf.Reset()
+	if cut {
+		s.done = true
+		s.emit(chat.Sanitize(segs[len(segs)-1]))
+		return false
+	}
+	s.buf.WriteString(segs[len(segs)-1])
+	return true
+}
+
+// flush 输出剩余的文本
+func (s *segmenter) flush() {
+	if s.done {
+		return
+	}
+	s.done = true
+	s.emit(chat.Sanitize(s.buf.String()))
+}
+
+// replier 逐段发送回复
+type replier struct {
+	ctx    *zero.Ctx
+	record bool
+	recCfg airecord.RecordConfig
+	nick   string
+	id     any
+	// sent 已发送的原始段落, 用于写入聊天记录
+	sent []string
+}
+
+func newreplier(ctx *zero.Ctx, stor chat.Storage, recCfg airecord.RecordConfig) *replier {
+	r := &replier{
+		ctx:    ctx,
+		record: !stor.NoRecord(),
+		recCfg: recCfg,
+		nick:   zero.BotConfig.NickName[rand.Intn(len(zero.BotConfig.NickName))],
+	}
+	if ctx.Event.IsToMe {
+		r.id = ctx.Event.MessageID
+	}
+	return r
+}
+
+// send 发送一段回复, 优先使用AI语音
+func (r *replier) send(t string) {
+	if t == "" {
+		return
+	}
+	r.sent = append(r.sent, t)
+	t = strings.ReplaceAll(t, "{name}", r.ctx.CardOrNickName(r.ctx.Event.UserID))
+	t = strings.ReplaceAll(t, "{me}", r.nick)
+	logrus.Debugln("[aichat] 回复内容:", t)
+	if !fastfailnorecord && r.record {
+		record := r.ctx.GetAIRecord(r.recCfg.ModelID, r.recCfg.Customgid, t)
+		if record != "" {
+			r.ctx.SendChain(message.Record(record))
+			return
+		}
+		fastfailnorecord = true
+	}
+	if r.id != nil {
+		r.id = r.ctx.SendChain(message.Reply(r.id), message.Text(t))
+	} else {
+		r.id = r.ctx.SendChain(message.Text(t))
+	}
+	process.SleepAbout1sTo2s()
+}
+
+// history 已发送的回复, 以 {segment} 连接
+func (r *replier) history() string {
+	return strings.Join(r.sent, segsep)
+}
@@ -0,0 +1,211 @@
+// Package tool 供 aichat Agent 调用的插件功能注册表
+package tool
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	goba "github.com/fumiama/go-onebot-agent"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix 工具在 Agent 中的 action 前缀, 用于与 OneBot action 区分
+const Prefix = "tool_"
+
+// basetable go-onebot-agent 内置 actions.yaml 的副本, 工具将追加在其后.
+// 该库没有导出内置权限表, 升级依赖时需同步此文件
+//
+//go:embed actions.yaml
+var basetable []byte
+
+var (
+	// ErrPermissionDenied 调用者的权限不足以使用该工具
+	ErrPermissionDenied = errors.New("权限不足")
+	// ErrDisabled 提供该工具的插件在本群被禁用
+	ErrDisabled = errors.New("该功能在本群未启用")
+)
+
+// Handler 执行工具, args 为 Agent 传入的 JSON 参数, 返回值会序列化后交给 Agent
+type Handler func(ctx *zero.Ctx, args json.RawMessage) (any, error)
+
+// Enabler 一般为插件的 *control.Engine
+type Enabler interface {
+	IsEnabledIn(id int64) bool
+}
+
+// Tool 一个可被 Agent 调用的插件功能
+type Tool struct {
+	// Name 工具名, 实际 action 为 Prefix+Name
+	Name string
+	// Desc 功能描述
+	Desc string
+	// Schema 参数的 JSON Schema
+	Schema string
+	// Data 返回值描述
+	Data string
+	// Role 可调用的最低权限, 为空时为 goba.PermRoleUser
+	Role goba.PermRole
+	// Handle 执行函数
+	Handle Handler
+
+	en Enabler
+}
+
+var (
+	mu    sync.RWMutex
+	tools = map[string]*Tool{}
+	// table 生成的权限表缓存, 注册新工具后清空
+	table []byte
+)
+
+// rolelevel 权限高低
+func rolelevel(r goba.PermRole) int {
+	switch r {
+	case goba.PermRoleOwner:
+		return 2
+	case goba.PermRoleAdmin:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Register 注册工具, en 为提供该工具的插件, 用于检查插件在本群是否启用
+func Register(en Enabler, t Tool) {
+	if t.Name == "" || t.Handle == nil {
+		panic("tool: empty name or handler")
+	}
+	if t.Role == "" {
+		t.Role = goba.PermRoleUser
+	}
+	if t.Schema == "" {
+		t.Schema = `{"type":"object","properties":{}}`
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(t.Schema)); err != nil {
+		panic("tool: invalid schema of " + t.Name + ": " + err.Error())
+	}
+	t.Schema = buf.String()
+	t.en = en
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := tools[t.Name]; ok {
+		panic("tool: duplicated name " + t.Name)
+	}
+	tools[t.Name] = &t
+	table = nil
+}
+
+// Len 已注册的工具数
+func Len() int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(tools)
+}
+
+// Lookup 根据 action 查找工具
+func Lookup(action string) (*Tool, bool) {
+	name, ok := strings.CutPrefix(action, Prefix)
+	if !ok {
+		return nil, false
+	}
+	mu.RLock()
+	t, ok := tools[name]
+	mu.RUnlock()
+	return t, ok
+}
+
+// List 列出 role 可用的工具
+func List(role goba.PermRole) []*Tool {
+	mu.RLock()
+	ts := make([]*Tool, 0, len(tools))
+	for _, t := range tools {
+		if rolelevel(role) >= rolelevel(t.Role) {
+			ts = append(ts, t)
+		}
+	}
+	mu.RUnlock()
+	sort.Slice(ts, func(i, j int) bool { return ts[i].Name < ts[j].Name })
+	return ts
+}
+
+// PermTable 生成包含全部工具的 Agent 权限表
+func PermTable() ([]byte, error) {
+	mu.RLock()
+	data := table
+	mu.RUnlock()
+	if data != nil {
+		return data, nil
+	}
+	var p goba.Perm
+	err := yaml.Unmarshal(basetable, &p)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range []goba.PermRole{goba.PermRoleOwner, goba.PermRoleAdmin, goba.PermRoleUser} {
+		for _, t := range List(role) {
+			p.Actions[Prefix+t.Name] = goba.PermAction{
+				Desc:   t.Desc,
+				Params: t.Schema,
+				Data:   t.Data,
+			}
+			p.Config[role] = append(p.Config[role], Prefix+t.Name)
+		}
+	}
+	data, err = yaml.Marshal(&p)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	table = data
+	mu.Unlock()
+	return data, nil
+}
+
+// WritePermTable 将权限表写入 path 以供 goba.Agent.LoadPermTable 读取
+func WritePermTable(path string) error {
+	data, err := PermTable()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Call 以 role 权限执行工具, 并将结果包装为 Agent 可读的响应
+func (t *Tool) Call(ctx *zero.Ctx, role goba.PermRole, params map[string]any) *goba.APIResponse {
+	gid := ctx.Event.GroupID
+	if gid == 0 {
+		gid = -ctx.Event.UserID
+	}
+	var (
+		ret any
+		err error
+	)
+	switch {
+	case rolelevel(role) < rolelevel(t.Role):
+		err = ErrPermissionDenied
+	case t.en != nil && !t.en.IsEnabledIn(gid):
+		err = ErrDisabled
+	default:
+		var args []byte
+		args, err = json.Marshal(params)
+		if err == nil {
+			ret, err = t.Handle(ctx, args)
+		}
+	}
+	if err != nil {
+		return &goba.APIResponse{Status: "failed", Data: []byte(`null`), Message: err.Error(), RetCode: 100}
+	}
+	data, err := json.Marshal(ret)
+	if err != nil {
+		return &goba.APIResponse{Status: "failed", Data: []byte(`null`), Message: err.Error(), RetCode: 100}
+	}
+	return &goba.APIResponse{Status: "ok", Data: data}
+}
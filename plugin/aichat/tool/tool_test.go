@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"encoding/json"
+	"testing"
+
+	goba "github.com/fumiama/go-onebot-agent"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"gopkg.in/yaml.v3"
+)
+
+type enabler bool
+
+func (e enabler) IsEnabledIn(int64) bool { return bool(e) }
+
+func TestPermTable(t *testing.T) {
+	Register(enabler(true), Tool{
+		Name: "echo",
+		Desc: "复读",
+		Schema: `{
+			"type": "object",
+			"properties": {"text": {"type": "string"}}
+		}`,
+		Handle: func(_ *zero.Ctx, args json.RawMessage) (any, error) {
+			var p struct {
+				Text string `json:"text"`
+			}
+			err := json.Unmarshal(args, &p)
+			return p.Text, err
+		},
+	})
+	Register(enabler(false), Tool{
+		Name:   "kick",
+		Role:   goba.PermRoleAdmin,
+		Handle: func(*zero.Ctx, json.RawMessage) (any, error) { return nil, nil },
+	})
+	data, err := PermTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p goba.Perm
+	err = yaml.Unmarshal(data, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Actions[Prefix+"echo"].Params != `{"type":"object","properties":{"text":{"type":"string"}}}` {
+		t.Fatal("unexpected params", p.Actions[Prefix+"echo"].Params)
+	}
+	has := func(role goba.PermRole, act string) bool {
+		for _, a := range p.Config[role] {
+			if a == act {
+				return true
+			}
+		}
+		return false
+	}
+	if !has(goba.PermRoleUser, Prefix+"echo") || !has(goba.PermRoleOwner, Prefix+"echo") {
+		t.Fatal("echo should be available to all roles")
+	}
+	if has(goba.PermRoleUser, Prefix+"kick") || !has(goba.PermRoleAdmin, Prefix+"kick") {
+		t.Fatal("kick should only be available to admin and owner")
+	}
+	if !has(goba.PermRoleUser, "send_group_msg") {
+		t.Fatal("builtin actions lost")
+	}
+}
+
+func TestCall(t *testing.T) {
+	ctx := &zero.Ctx{Event: &zero.Event{GroupID: 1, UserID: 2}}
+	echo, ok := Lookup(Prefix + "echo")
+	if !ok {
+		t.Fatal("echo not found")
+	}
+	rsp := echo.Call(ctx, goba.PermRoleUser, map[string]any{"text": "hello"})
+	if rsp.Status != "ok" || string(rsp.Data) != `"hello"` {
+		t.Fatal("unexpected response", rsp)
+	}
+	kick, _ := Lookup(Prefix + "kick")
+	rsp = kick.Call(ctx, goba.PermRoleUser, nil)
+	if rsp.Message != ErrPermissionDenied.Error() {
+		t.Fatal("unexpected response", rsp)
+	}
+	rsp = kick.Call(ctx, goba.PermRoleOwner, nil)
+	if rsp.Message != ErrDisabled.Error() {
+		t.Fatal("unexpected response", rsp)
+	}
+	if _, ok := Lookup("send_group_msg"); ok {
+		t.Fatal("builtin action should not be a tool")
+	}
+}
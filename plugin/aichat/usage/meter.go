@@ -0,0 +1,68 @@
+package usage
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/fumiama/deepinfra"
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// meter 读取模型响应中的用量字段并记录
+type meter struct {
+	base          http.RoundTripper
+	gid, uid      int64
+	plugin, model string
+}
+
+// RoundTrip implements http.RoundTripper
+func (m *meter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := m.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	prompt, completion := Tokens(data)
+	err = Record(m.gid, m.uid, m.plugin, m.model, prompt, completion, 0)
+	if err != nil {
+		logrus.Warnln("[usage] record err:", err)
+	}
+	return resp, nil
+}
+
+// Tokens 从 OpenAI / OLLaMA / GenAI 格式的响应中读取输入输出 token 数
+func Tokens(data []byte) (prompt, completion int64) {
+	r := gjson.ParseBytes(data)
+	if u := r.Get("usage"); u.Exists() {
+		return u.Get("prompt_tokens").Int(), u.Get("completion_tokens").Int()
+	}
+	if u := r.Get("usageMetadata"); u.Exists() {
+		return u.Get("promptTokenCount").Int(), u.Get("candidatesTokenCount").Int()
+	}
+	return r.Get("prompt_eval_count").Int(), r.Get("eval_count").Int()
+}
+
+// Client 返回记录用量的 http.Client
+func Client(gid, uid int64, plugin, model string) *http.Client {
+	return &http.Client{Transport: &meter{
+		base:   http.DefaultTransport,
+		gid:    gid,
+		uid:    uid,
+		plugin: plugin,
+		model:  model,
+	}}
+}
+
+// API 返回记录用量的 deepinfra.API
+func API(api, key string, gid, uid int64, plugin, model string) deepinfra.API {
+	x := deepinfra.NewAPI(api, key)
+	x.SetHTTPClient(Client(gid, uid, plugin, model))
+	return x
+}
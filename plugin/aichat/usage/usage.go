@@ -0,0 +1,281 @@
+// Package usage 大模型调用的用量统计与配额
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FloatTech/floatbox/binary"
+	sql "github.com/FloatTech/sqlite"
+)
+
+const (
+	stattable  = "usage"
+	quotatable = "quota"
+)
+
+// 默认配额的键
+const (
+	// DefaultGroup 默认每群配额
+	DefaultGroup = "g*"
+	// DefaultUser 默认每人配额
+	DefaultUser = "u*"
+)
+
+// stat 某天某群某人在某插件某模型上的用量
+type stat struct {
+	ID         int64  `db:"id"`         // ID day_gid_uid_plugin_model 的 crc64
+	Day        int64  `db:"day"`        // Day 形如 20060102
+	GrpID      int64  `db:"gid"`        // GrpID 群号, 私聊为 -QQ
+	UserID     int64  `db:"uid"`        // UserID 调用者
+	Plugin     string `db:"plugin"`     // Plugin 插件名
+	Model      string `db:"model"`      // Model 模型名
+	Calls      int64  `db:"calls"`      // Calls 调用次数
+	Prompt     int64  `db:"prompt"`     // Prompt 输入 token
+	Completion int64  `db:"completion"` // Completion 输出 token
+	Images     int64  `db:"images"`     // Images 生成图片数
+}
+
+// Quota 用量配额, 各项为 0 时不限
+type Quota struct {
+	Key           string `db:"key"`     // Key g<群号> u<QQ> 或默认配额 g* u*
+	Daily         int64  `db:"daily"`   // Daily 每日 token
+	Monthly       int64  `db:"monthly"` // Monthly 每月 token
+	DailyImages   int64  `db:"dimg"`    // DailyImages 每日图片
+	MonthlyImages int64  `db:"mimg"`    // MonthlyImages 每月图片
+}
+
+// Item 报表中的一项
+type Item struct {
+	Key    string `db:"k"`
+	Calls  int64  `db:"calls"`
+	Tokens int64  `db:"tokens"`
+	Images int64  `db:"images"`
+}
+
+// ExceededError 超出配额
+type ExceededError struct {
+	// Who 本群 | 你
+	Who string
+	// Period 今日 | 本月
+	Period string
+	// What AI额度 | AI画图次数
+	What string
+}
+
+func (e *ExceededError) Error() string {
+	next := "明天"
+	if e.Period == "本月" {
+		next = "下个月"
+	}
+	return e.Who + e.Period + "的" + e.What + "已经用完啦, " + next + "再来吧~"
+}
+
+var (
+	mu     sync.RWMutex
+	db     sql.Sqlite
+	crctab = crc64.MakeTable(crc64.ISO)
+	// ErrNotOpen 数据库未打开
+	ErrNotOpen = errors.New("用量数据库未打开")
+	opened     bool
+)
+
+// Open 打开用量数据库, 由 aichat 在初始化时调用
+func Open(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	db = sql.New(path)
+	err := db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = db.Create(stattable, &stat{})
+	if err != nil {
+		return err
+	}
+	err = db.Create(quotatable, &Quota{})
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_usage_day ON " + stattable + " (day, gid, uid);")
+	if err != nil {
+		return err
+	}
+	opened = true
+	return nil
+}
+
+// dayof 将时间转为 20060102 形式的整数
+func dayof(t time.Time) int64 {
+	d, _ := strconv.ParseInt(t.Format("20060102"), 10, 64)
+	return d
+}
+
+// Record 累加一次调用的用量
+func Record(gid, uid int64, plugin, model string, prompt, completion, images int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return ErrNotOpen
+	}
+	day := dayof(time.Now())
+	id := int64(crc64.Checksum(binary.StringToBytes(fmt.Sprintf("%d_%d_%d_%s_%s", day, gid, uid, plugin, model)), crctab))
+	_, err := db.Exec(
+		"INSERT INTO "+stattable+" (id, day, gid, uid, plugin, model, calls, prompt, completion, images) VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?) "+
+			"ON CONFLICT(id) DO UPDATE SET calls = calls + 1, prompt = prompt + excluded.prompt, "+
+			"completion = completion + excluded.completion, images = images + excluded.images;",
+		id, day, gid, uid, plugin, model, prompt, completion, images,
+	)
+	return err
+}
+
+// Sum 统计 [from, to] 内的 token 与图片数, gid/uid 为 0 时不限
+func Sum(gid, uid int64, from, to time.Time) (tokens, images int64, err error) {
+	q := "SELECT '' AS k, COALESCE(SUM(calls), 0) AS calls, COALESCE(SUM(prompt + completion), 0) AS tokens, " +
+		"COALESCE(SUM(images), 0) AS images FROM " + stattable + " WHERE day >= ? AND day <= ?"
+	args := []any{dayof(from), dayof(to)}
+	if gid != 0 {
+		q += " AND gid = ?"
+		args = append(args, gid)
+	}
+	if uid != 0 {
+		q += " AND uid = ?"
+		args = append(args, uid)
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if !opened {
+		return 0, 0, ErrNotOpen
+	}
+	it, err := sql.Query[Item](&db, q+";", args...)
+	if err != nil {
+		if err == sql.ErrNullResult {
+			err = nil
+		}
+		return
+	}
+	return it.Tokens, it.Images, nil
+}
+
+// Report 按 by (gid | model | plugin) 分组统计 [from, to] 内的用量, 按 token 倒序
+func Report(by string, from, to time.Time) ([]*Item, error) {
+	switch by {
+	case "gid", "model", "plugin":
+	default:
+		return nil, errors.New("invalid report key " + by)
+	}
+	q := "SELECT CAST(" + by + " AS TEXT) AS k, SUM(calls) AS calls, SUM(prompt + completion) AS tokens, SUM(images) AS images FROM " +
+		stattable + " WHERE day >= ? AND day <= ? GROUP BY " + by + " ORDER BY tokens DESC, images DESC;"
+	mu.RLock()
+	defer mu.RUnlock()
+	if !opened {
+		return nil, ErrNotOpen
+	}
+	items, err := sql.QueryAll[Item](&db, q, dayof(from), dayof(to))
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return items, err
+}
+
+// GetQuota 获取配额, 未设置时返回空配额
+func GetQuota(key string) (q Quota) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if opened && db.Find(quotatable, &q, "WHERE key = ?", key) == nil {
+		return
+	}
+	return Quota{Key: key}
+}
+
+// SetQuota 保存配额
+func SetQuota(q *Quota) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return ErrNotOpen
+	}
+	return db.Insert(quotatable, q)
+}
+
+// GroupKey 群配额的键
+func GroupKey(gid int64) string {
+	return "g" + strconv.FormatInt(gid, 10)
+}
+
+// UserKey 个人配额的键
+func UserKey(uid int64) string {
+	return "u" + strconv.FormatInt(uid, 10)
+}
+
+// Effective 获取生效的配额, 未单独设置的项使用默认配额
+func Effective(key, defaultkey string) Quota {
+	q := GetQuota(key)
+	d := GetQuota(defaultkey)
+	if q.Daily == 0 {
+		q.Daily = d.Daily
+	}
+	if q.Monthly == 0 {
+		q.Monthly = d.Monthly
+	}
+	if q.DailyImages == 0 {
+		q.DailyImages = d.DailyImages
+	}
+	if q.MonthlyImages == 0 {
+		q.MonthlyImages = d.MonthlyImages
+	}
+	return q
+}
+
+// Check 检查本次调用是否超出群或个人的配额, image 为真时检查图片配额
+func Check(gid, uid int64, image bool) error {
+	if !opened {
+		return nil
+	}
+	now := time.Now()
+	today := now
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	type scope struct {
+		who      string
+		gid, uid int64
+		q        Quota
+	}
+	scopes := make([]scope, 0, 2)
+	if gid > 0 {
+		scopes = append(scopes, scope{who: "本群", gid: gid, q: Effective(GroupKey(gid), DefaultGroup)})
+	}
+	if uid != 0 {
+		scopes = append(scopes, scope{who: "你", uid: uid, q: Effective(UserKey(uid), DefaultUser)})
+	}
+	for _, s := range scopes {
+		daily, monthly, what := s.q.Daily, s.q.Monthly, "AI额度"
+		if image {
+			daily, monthly, what = s.q.DailyImages, s.q.MonthlyImages, "AI画图次数"
+		}
+		for _, p := range []struct {
+			period string
+			from   time.Time
+			limit  int64
+		}{{"今日", today, daily}, {"本月", month, monthly}} {
+			if p.limit <= 0 {
+				continue
+			}
+			tokens, images, err := Sum(s.gid, s.uid, p.from, now)
+			if err != nil {
+				return nil
+			}
+			used := tokens
+			if image {
+				used = images
+			}
+			if used >= p.limit {
+				return &ExceededError{Who: s.who, Period: p.period, What: what}
+			}
+		}
+	}
+	return nil
+}
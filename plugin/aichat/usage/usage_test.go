@@ -0,0 +1,77 @@
+package usage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	for _, c := range []struct {
+		data       string
+		prompt, cp int64
+	}{
+		{`{"usage":{"prompt_tokens":12,"completion_tokens":34}}`, 12, 34},
+		{`{"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":6}}`, 5, 6},
+		{`{"prompt_eval_count":7,"eval_count":8}`, 7, 8},
+		{`{}`, 0, 0},
+	} {
+		p, cp := Tokens([]byte(c.data))
+		if p != c.prompt || cp != c.cp {
+			t.Fatal("unexpected tokens of", c.data, p, cp)
+		}
+	}
+}
+
+func TestQuota(t *testing.T) {
+	err := Open(t.TempDir() + "/usage.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err = Record(1, 2, "aichat", "m", 10, 20, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = Record(1, 3, "aiimage", "img", 0, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tokens, images, err := Sum(1, 0, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 90 || images != 4 {
+		t.Fatal("unexpected sum", tokens, images)
+	}
+	items, err := Report("model", now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Key != "m" || items[0].Calls != 3 {
+		t.Fatal("unexpected report", items)
+	}
+	if err = Check(1, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	err = SetQuota(&Quota{Key: DefaultUser, Daily: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *ExceededError
+	if err = Check(1, 2, false); !errors.As(err, &e) || e.Who != "你" {
+		t.Fatal("user quota not applied", err)
+	}
+	if err = Check(1, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	err = SetQuota(&Quota{Key: GroupKey(1), DailyImages: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Check(1, 3, true); !errors.As(err, &e) || e.Who != "本群" {
+		t.Fatal("group quota not applied", err)
+	}
+}
@@ -41,7 +41,27 @@ var (
 			"- 设置AI聊天(不)以AI语音输出\n" +
 			"- 查看AI聊天配置\n" +
 			"- 重置AI聊天Agent\n" +
-			"- 重置AI聊天\n",
+			"- 重置AI聊天\n" +
+			"- 设置(本群|默认每群|默认每人|用户123456)AI(每日|每月)(token|画图)配额10000 (0为不限)\n" +
+			"- 查看AI用量\n" +
+			"- AI用量报表 [今日|本月|近7天]\n" +
+			"Tips: aichat、llm 与 aiimage 的调用共用上述配额\n" +
+			"- 保存AI人格 夜猫子 (将当前配置保存为人格)\n" +
+			"- 导入AI人格 {\"name\":\"夜猫子\",\"system_prompt\":\"...\",\"temperature\":80,\"top_p\":0.9,\"voice\":\"lucy-voice-suxinjiejie\",\"rate\":10}\n" +
+			"- 导出AI人格 [名称...]\n" +
+			"- 删除AI人格 夜猫子\n" +
+			"- AI人格列表\n" +
+			"- 查看AI人格\n" +
+			"- 切换AI人格 [名称|默认]\n" +
+			"- 设置AI人格定时 夜猫子 22:00-06:00\n" +
+			"- 取消AI人格定时 [名称]\n" +
+			"Tips: 人格中未设置的项沿用本群配置, 定时优先于手动切换; Agent模式下不使用人格的系统提示词\n" +
+			"- 添加AI聊天备用接口 OpenAI https://api.deepseek.com/chat/completions sk-xxx deepseek-chat\n" +
+			"- 删除AI聊天备用接口 1\n" +
+			"- 查看AI聊天备用接口\n" +
+			"- 重置AI聊天熔断\n" +
+			"- 设置AI聊天(不)使用流式输出\n" +
+			"Tips: 主接口连续失败3次后熔断并依次改用备用接口\n",
 	})
 )
 
@@ -0,0 +1,166 @@
+package aichatcfg
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/FloatTech/AnimeAPI/airecord"
+	"github.com/FloatTech/zbputils/chat"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/persona"
+)
+
+func init() {
+	en.OnRegex(`^保存AI人格\s*(\S+)$`, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		name := ctx.State["regex_matched"].([]string)[1]
+		stor := ctx.State[zero.StateKeyPrefixKeep+"aichatcfg_stor__"].(chat.Storage)
+		err := persona.Save(&persona.Persona{
+			Name:    name,
+			SystemP: chat.AC.SystemP,
+			Temp:    int64(math.Round(float64(stor.Temp()) * 100)),
+			TopP:    chat.AC.TopP,
+			Voice:   airecord.GetConfig().ModelID,
+			Rate:    int64(stor.Rate()),
+		})
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("已将当前配置保存为人格 ", name))
+	})
+	en.OnPrefix("导入AI人格", zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		names, err := persona.Import([]byte(ctx.State["args"].(string)))
+		if len(names) > 0 {
+			ctx.SendChain(message.Text("已导入人格: ", strings.Join(names, ", ")))
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+		}
+	})
+	en.OnPrefix("导出AI人格", zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		data, err := persona.Export(strings.Fields(ctx.State["args"].(string))...)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text(string(data)))
+	})
+	en.OnRegex(`^删除AI人格\s*(\S+)$`, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		name := ctx.State["regex_matched"].([]string)[1]
+		err := persona.Delete(name)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("已删除人格 ", name))
+	})
+	en.OnFullMatch("AI人格列表").SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		ps, err := persona.List()
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(ps) == 0 {
+			ctx.SendChain(message.Text("还没有保存任何人格"))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("【AI人格列表】")
+		for _, p := range ps {
+			sb.WriteString("\n• ")
+			sb.WriteString(p.Name)
+			if p.SystemP != "" {
+				rs := []rune(p.SystemP)
+				if len(rs) > 20 {
+					rs = append(rs[:20], '…')
+				}
+				sb.WriteString(": ")
+				sb.WriteString(string(rs))
+			}
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+	en.OnRegex(`^切换AI人格\s*(\S+)$`, zero.AdminPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		gid := ctx.Event.GroupID
+		if gid == 0 {
+			gid = -ctx.Event.UserID
+		}
+		name := ctx.State["regex_matched"].([]string)[1]
+		if name == "默认" {
+			name = ""
+		}
+		err := persona.Switch(gid, name)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		chat.ResetChatIn(gid)
+		if name == "" {
+			ctx.SendChain(message.Text("已恢复默认人格"))
+			return
+		}
+		ctx.SendChain(message.Text("已切换到人格 ", name))
+	})
+	en.OnRegex(`^设置AI人格定时\s*(\S+)\s+(\S+)$`, zero.AdminPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		gid := ctx.Event.GroupID
+		if gid == 0 {
+			gid = -ctx.Event.UserID
+		}
+		args := ctx.State["regex_matched"].([]string)
+		from, to, err := persona.ParsePeriod(args[2])
+		if err == nil {
+			err = persona.SetSchedule(gid, args[1], from, to)
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("已设置每天 ", args[2], " 使用人格 ", args[1]))
+	})
+	en.OnRegex(`^取消AI人格定时\s*(\S*)$`, zero.AdminPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		gid := ctx.Event.GroupID
+		if gid == 0 {
+			gid = -ctx.Event.UserID
+		}
+		err := persona.DelSchedule(gid, ctx.State["regex_matched"].([]string)[1])
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("成功"))
+	})
+	en.OnFullMatch("查看AI人格").SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		gid := ctx.Event.GroupID
+		if gid == 0 {
+			gid = -ctx.Event.UserID
+		}
+		var sb strings.Builder
+		sb.WriteString("【当前AI人格】\n• 生效中: ")
+		if p := persona.Of(gid, time.Now()); p != nil {
+			sb.WriteString(p.Name)
+		} else {
+			sb.WriteString("默认")
+		}
+		sb.WriteString("\n• 手动选择: ")
+		if name := persona.Active(gid); name != "" {
+			sb.WriteString(name)
+		} else {
+			sb.WriteString("默认")
+		}
+		ss, err := persona.Schedules(gid)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		for _, s := range ss {
+			sb.WriteString("\n• 定时: ")
+			sb.WriteString(s.Period())
+			sb.WriteByte(' ')
+			sb.WriteString(s.Name)
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+}
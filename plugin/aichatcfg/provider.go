@@ -0,0 +1,106 @@
+package aichatcfg
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/provider"
+)
+
+func init() {
+	en.OnRegex(`^添加AI聊天备用接口\s*(\S+)\s+(\S+)\s+(\S+)\s+(\S+)$`, zero.OnlyPrivate, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		args := ctx.State["regex_matched"].([]string)
+		err := provider.Add(args[1], args[2], args[3], args[4])
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("成功"))
+	})
+	en.OnRegex(`^删除AI聊天备用接口\s*(\d+)$`, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		i, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+		err := provider.Del(i)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("成功"))
+	})
+	en.OnFullMatch("查看AI聊天备用接口", zero.OnlyPrivate, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		hs := make(map[string]provider.Health, 8)
+		for _, h := range provider.Status() {
+			hs[h.Name] = h
+		}
+		now := time.Now()
+		var sb strings.Builder
+		sb.WriteString("【AI聊天接口】\n流式输出: ")
+		sb.WriteString(strconv.FormatBool(provider.Streaming()))
+		for i, e := range provider.Endpoints() {
+			sb.WriteString("\n")
+			if i == 0 {
+				sb.WriteString("主接口")
+			} else {
+				sb.WriteString("备用")
+				sb.WriteString(strconv.Itoa(i))
+			}
+			sb.WriteString(": [")
+			sb.WriteString(e.Type)
+			sb.WriteString("] ")
+			sb.WriteString(e.Model)
+			sb.WriteString(" ")
+			sb.WriteString(e.API)
+			sb.WriteString(" 密钥")
+			sb.WriteString(maskkey(e.Key))
+			h, ok := hs[e.Name()]
+			if !ok {
+				sb.WriteString("\n  尚未调用")
+				continue
+			}
+			sb.WriteString("\n  ")
+			if h.Open(now) {
+				sb.WriteString("熔断中, ")
+				sb.WriteString(h.OpenUntil.Sub(now).Round(time.Second).String())
+				sb.WriteString("后重试")
+			} else {
+				sb.WriteString("正常")
+			}
+			sb.WriteString(" 成功")
+			sb.WriteString(strconv.FormatInt(h.OK, 10))
+			sb.WriteString("/失败")
+			sb.WriteString(strconv.FormatInt(h.Failed, 10))
+			if h.Latency > 0 {
+				sb.WriteString(" 耗时")
+				sb.WriteString(h.Latency.Round(time.Millisecond).String())
+			}
+			if h.Fails > 0 && h.LastErr != "" {
+				sb.WriteString("\n  最近错误: ")
+				sb.WriteString(h.LastErr)
+			}
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+	en.OnFullMatch("重置AI聊天熔断", zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		provider.Reset()
+		ctx.SendChain(message.Text("成功"))
+	})
+	en.OnRegex(`^设置AI聊天(不)?使用流式输出$`, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		err := provider.SetStreaming(ctx.State["regex_matched"].([]string)[1] == "")
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("成功"))
+	})
+}
+
+// maskkey 只显示密钥首尾
+func maskkey(k string) string {
+	if len(k) <= 8 {
+		return strings.Repeat("*", len(k))
+	}
+	return k[:4] + "****" + k[len(k)-4:]
+}
@@ -0,0 +1,270 @@
+package aichatcfg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg"
+	"github.com/FloatTech/gg/factory"
+	"github.com/FloatTech/zbputils/control"
+	"github.com/FloatTech/zbputils/img/text"
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"github.com/wcharczuk/go-chart/v2"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
+)
+
+// reportmaxbars 报表每张图最多的柱数
+const reportmaxbars = 10
+
+var errNoUsage = errors.New("该时段没有AI用量记录")
+
+func init() {
+	en.OnRegex(`^设置(本群|默认每群|默认每人|用户(\d+))AI(每日|每月)(token|画图)配额\s*(\d+)$`, zero.SuperUserPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			args := ctx.State["regex_matched"].([]string)
+			var key string
+			switch args[1] {
+			case "本群":
+				if ctx.Event.GroupID == 0 {
+					ctx.SendChain(message.Text("ERROR: 请在群内设置本群配额"))
+					return
+				}
+				key = usage.GroupKey(ctx.Event.GroupID)
+			case "默认每群":
+				key = usage.DefaultGroup
+			case "默认每人":
+				key = usage.DefaultUser
+			default:
+				uid, _ := strconv.ParseInt(args[2], 10, 64)
+				key = usage.UserKey(uid)
+			}
+			n, _ := strconv.ParseInt(args[5], 10, 64)
+			q := usage.GetQuota(key)
+			switch args[3] + args[4] {
+			case "每日token":
+				q.Daily = n
+			case "每月token":
+				q.Monthly = n
+			case "每日画图":
+				q.DailyImages = n
+			case "每月画图":
+				q.MonthlyImages = n
+			}
+			err := usage.SetQuota(&q)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if n == 0 {
+				ctx.SendChain(message.Text("已取消", args[1], args[3], args[4], "配额"))
+				return
+			}
+			ctx.SendChain(message.Text("已设置", args[1], args[3], args[4], "配额为", n))
+		})
+	en.OnFullMatch("查看AI用量").SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		now := time.Now()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		uid := ctx.Event.UserID
+		var sb strings.Builder
+		sb.WriteString("【你的AI用量】\n")
+		err := writeusage(&sb, 0, uid, usage.Effective(usage.UserKey(uid), usage.DefaultUser), now, month)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if gid := ctx.Event.GroupID; gid != 0 {
+			sb.WriteString("\n【本群AI用量】\n")
+			err = writeusage(&sb, gid, 0, usage.Effective(usage.GroupKey(gid), usage.DefaultGroup), now, month)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(strings.TrimSpace(sb.String())))
+	})
+	en.OnRegex(`^AI用量报表\s*(今日|本月|近(\d+)天)?$`, zero.SuperUserPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			args := ctx.State["regex_matched"].([]string)
+			now := time.Now()
+			from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+			title := "本月"
+			switch {
+			case args[1] == "今日":
+				from, title = now, "今日"
+			case args[2] != "":
+				n, _ := strconv.Atoi(args[2])
+				if n <= 0 {
+					n = 1
+				}
+				from, title = now.AddDate(0, 0, 1-n), "近"+args[2]+"天"
+			}
+			data, err := drawreport(title, from, now)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.ImageBytes(data))
+		})
+}
+
+// writeusage 写入今日与本月的用量及配额
+func writeusage(sb *strings.Builder, gid, uid int64, q usage.Quota, now, month time.Time) error {
+	for _, p := range []struct {
+		name          string
+		from          time.Time
+		tokens, image int64
+	}{{"今日", now, q.Daily, q.DailyImages}, {"本月", month, q.Monthly, q.MonthlyImages}} {
+		tokens, images, err := usage.Sum(gid, uid, p.from, now)
+		if err != nil {
+			return err
+		}
+		sb.WriteString("• ")
+		sb.WriteString(p.name)
+		sb.WriteString(": ")
+		sb.WriteString(strconv.FormatInt(tokens, 10))
+		sb.WriteString(limitstr(p.tokens))
+		sb.WriteString(" token, ")
+		sb.WriteString(strconv.FormatInt(images, 10))
+		sb.WriteString(limitstr(p.image))
+		sb.WriteString(" 张图\n")
+	}
+	return nil
+}
+
+func limitstr(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	return "/" + strconv.FormatInt(n, 10)
+}
+
+// groupname 报表中群的标签
+func groupname(k string) string {
+	gid, _ := strconv.ParseInt(k, 10, 64)
+	switch {
+	case gid > 0:
+		return "群" + k
+	case gid < 0:
+		return "私聊" + k[1:]
+	default:
+		return "其它"
+	}
+}
+
+// drawreport 绘制 [from, to] 内按群和按模型的用量柱状图
+func drawreport(title string, from, to time.Time) ([]byte, error) {
+	bygrp, err := usage.Report("gid", from, to)
+	if err != nil {
+		return nil, err
+	}
+	bymod, err := usage.Report("model", from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(bygrp) == 0 {
+		return nil, errNoUsage
+	}
+	_, err = file.GetLazyData(text.FontFile, control.Md5File, true)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(text.FontFile)
+	if err != nil {
+		return nil, err
+	}
+	font, err := freetype.ParseFont(b)
+	if err != nil {
+		return nil, err
+	}
+	var total, images int64
+	for _, it := range bygrp {
+		total += it.Tokens
+		images += it.Images
+	}
+	grpimg, err := drawbars(font, "按群统计 (token)", bygrp, groupname)
+	if err != nil {
+		return nil, err
+	}
+	modimg, err := drawbars(font, "按模型统计 (token)", bymod, func(k string) string { return k })
+	if err != nil {
+		return nil, err
+	}
+	const header = 100
+	w := max(grpimg.Bounds().Dx(), modimg.Bounds().Dx())
+	h := header + grpimg.Bounds().Dy() + modimg.Bounds().Dy()
+	canvas := gg.NewContext(w, h)
+	canvas.SetColor(color.White)
+	canvas.Clear()
+	canvas.SetColor(color.Black)
+	err = canvas.ParseFontFace(b, 32)
+	if err != nil {
+		return nil, err
+	}
+	canvas.DrawStringAnchored(title+"AI用量报表", float64(w)/2, 35, 0.5, 0.5)
+	err = canvas.ParseFontFace(b, 20)
+	if err != nil {
+		return nil, err
+	}
+	canvas.DrawStringAnchored(
+		from.Format("2006-01-02")+" ~ "+to.Format("2006-01-02")+"  共 "+
+			strconv.FormatInt(total, 10)+" token, "+strconv.FormatInt(images, 10)+" 张图",
+		float64(w)/2, 75, 0.5, 0.5,
+	)
+	canvas.DrawImage(grpimg, 0, header)
+	canvas.DrawImage(modimg, 0, header+grpimg.Bounds().Dy())
+	return factory.ToBytes(canvas.Image())
+}
+
+// drawbars 将报表项绘制为柱状图, 图片数附加在标签后
+func drawbars(font *truetype.Font, title string, items []*usage.Item, label func(string) string) (image.Image, error) {
+	if len(items) > reportmaxbars {
+		items = items[:reportmaxbars]
+	}
+	bars := make([]chart.Value, 0, len(items))
+	top := 1.0
+	for _, it := range items {
+		l := label(it.Key)
+		if it.Images > 0 {
+			l += "(" + strconv.FormatInt(it.Images, 10) + "图)"
+		}
+		bars = append(bars, chart.Value{Label: l, Value: float64(it.Tokens)})
+		top = math.Max(top, float64(it.Tokens))
+	}
+	var buf bytes.Buffer
+	err := chart.BarChart{
+		Font:  font,
+		Title: title,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top: 40,
+			},
+		},
+		YAxis: chart.YAxis{
+			Range: &chart.ContinuousRange{
+				Min: 0,
+				Max: math.Ceil(top/10) * 10,
+			},
+		},
+		Width:    max(600, 120*len(bars)),
+		Height:   500,
+		BarWidth: 60,
+		Bars:     bars,
+	}.Render(chart.PNG, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(&buf)
+}
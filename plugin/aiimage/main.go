@@ -11,6 +11,7 @@ import (
 	fcext "github.com/FloatTech/floatbox/ctxext"
 	"github.com/FloatTech/floatbox/web"
 	sql "github.com/FloatTech/sqlite"
+	"github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/message"
@@ -18,6 +19,8 @@ import (
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
 )
 
 func init() {
@@ -94,6 +97,14 @@ func init() {
 
 	en.OnPrefix("AI画图", getdb).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			if gid == 0 {
+				gid = -ctx.Event.UserID
+			}
+			if err := usage.Check(gid, ctx.Event.UserID, true); err != nil {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(err))
+				return
+			}
 			ctx.SendChain(message.Text("少女思考中..."))
 			prompt := strings.TrimSpace(ctx.State["args"].(string))
 			if prompt == "" {
@@ -156,13 +167,19 @@ func init() {
 				"种子: ", seed)))
 
 			// 添加所有图片
+			n := int64(0)
 			images.ForEach(func(_, value gjson.Result) bool {
 				url := value.Get("url").String()
 				if url != "" {
 					msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Image(url)))
+					n++
 				}
 				return true
 			})
+			err = usage.Record(gid, ctx.Event.UserID, "aiimage", cfg.ModelName, 0, 0, n)
+			if err != nil {
+				logrus.Warnln("[aiimage] 记录用量失败:", err)
+			}
 
 			if len(msg) > 0 {
 				ctx.Send(msg)
@@ -33,11 +33,17 @@ var (
 	config = newconfig() // 插件配置
 )
 
+// 审核后端
+const (
+	backendbaidu = "百度"
+	backendlocal = "本地"
+)
+
 func init() {
 	engine := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
 		DisableOnDefault: false,
 		Brief:            "百度内容审核",
-		Help: "##该功能来自百度内容审核, 需购买相关服务, 并创建app##\n" +
+		Help: "##该功能来自百度内容审核, 需购买相关服务, 并创建app, 未配置Key时使用本地规则审核##\n" +
 			"- 获取BDAKey\n" +
 			"- 配置BDAKey [API key] [Secret Key]\n" +
 			"- 开启/关闭内容审核\n" +
@@ -56,6 +62,15 @@ func init() {
 			"- 设置不检测类型[类型编号]\n" +
 			"- 开启/关闭文本检测\n" +
 			"- 开启/关闭图像检测\n" +
+			"##本地审核## 未配置Key或设置为本地后端时, 按下列规则审核, 图片按感知哈希比对\n" +
+			"- 设置审核后端[百度|本地]\n" +
+			"- 添加本地审核词[类型编号] [关键词1] [关键词2]...\n" +
+			"- 删除本地审核词[类型编号] [关键词]\n" +
+			"- 添加本地审核正则[类型编号] [正则表达式]\n" +
+			"- 删除本地审核正则[类型编号] [正则表达式]\n" +
+			"- 添加本地审核图片[类型编号][图片]\n" +
+			"- 删除本地审核图片[序号]\n" +
+			"- 查看本地审核规则\n" +
 			"##测试功能##\n" +
 			"- ^文本检测[文本内容]\n" +
 			"- ^图像检测[图片]\n",
@@ -69,6 +84,11 @@ func init() {
 	} else if config.Key1 != "" && config.Key2 != "" {
 		bdcli = censor.NewClient(config.Key1, config.Key2)
 	}
+	localpath := engine.DataFolder() + "local.json"
+	err = local.load(localpath)
+	if err != nil {
+		logrus.Warnln("[baiduaudit] 加载本地规则错误:", err)
+	}
 
 	engine.OnFullMatch("获取BDAKey", zero.SuperUserPermission).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
@@ -214,7 +234,8 @@ func init() {
 		if !bool(group.Enable) {
 			return
 		}
-		var bdres baiduRes
+		a := group.auditor()
+		var bdres *baiduRes
 		var err error
 		for _, elem := range ctx.Event.Message {
 			switch elem.Type {
@@ -222,36 +243,44 @@ func init() {
 				if !group.ImageAudit || elem.Data["url"] == "" {
 					continue
 				}
-				res := bdcli.ImgCensorUrl(elem.Data["url"], nil)
-				bdres, err = parse2BaiduRes(res)
-				if err != nil {
-					continue
-				}
+				bdres, err = a.imgcensor(elem.Data["url"])
 			case "text":
 				if !group.TextAudit || elem.Data["text"] == "" {
 					continue
 				}
-				bdres, err = parse2BaiduRes(bdcli.TextCensor(elem.Data["text"]))
-				if err != nil {
-					continue
-				}
+				bdres, err = a.textcensor(elem.Data["text"])
 			default:
 				continue
 			}
+			if err != nil {
+				logrus.Debugln("[baiduaudit] 审核错误:", err)
+				bdres = nil
+				continue
+			}
+			// 任一内容不合规即处理
+			if bdres.ConclusionType == 2 {
+				break
+			}
+		}
+		if bdres == nil {
+			return
 		}
 		bdres.audit(ctx, configpath)
 	})
 
 	engine.OnPrefix("^文本检测", hasinit).SetBlock(false).
 		Handle(func(ctx *zero.Ctx) {
-			args := ctx.ExtractPlainText()
-			res := bdcli.TextCensor(args)
-			bdres, err := parse2BaiduRes(res)
+			group := config.groupof(ctx.Event.GroupID)
+			bdres, err := group.auditor().textcensor(ctx.ExtractPlainText())
 			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if bdres.ErrorCode != 0 {
 				ctx.SendChain(message.Text("ERROR: ", bdres.ErrorMsg, "(", bdres.ErrorCode, ")"))
 				return
 			}
-			ctx.Send(config.groupof(ctx.Event.GroupID).reply(&bdres))
+			ctx.Send(group.reply(bdres))
 		})
 
 	engine.OnPrefix("^图像检测", hasinit).SetBlock(false).
@@ -267,26 +296,112 @@ func init() {
 			if len(urls) == 0 {
 				return
 			}
-			res := bdcli.ImgCensorUrl(urls[0], nil)
-			bdres, err := parse2BaiduRes(res)
+			group := config.groupof(ctx.Event.GroupID)
+			bdres, err := group.auditor().imgcensor(urls[0])
 			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if bdres.ErrorCode != 0 {
 				ctx.SendChain(message.Text("ERROR: ", bdres.ErrorMsg, "(", bdres.ErrorCode, ")"))
 				return
 			}
-			ctx.Send(config.groupof(ctx.Event.GroupID).reply(&bdres))
+			ctx.Send(group.reply(bdres))
+		})
+
+	engine.OnRegex("^设置审核后端(百度|本地)$", zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			k1 := ctx.State["regex_matched"].([]string)[1]
+			if k1 == backendbaidu && bdcli == nil {
+				ctx.SendChain(message.Text("Key未配置"))
+				return
+			}
+			config.groupof(ctx.Event.GroupID).set(func(g *group) {
+				g.Backend = k1
+			})
+			err := config.saveto(configpath)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.At(ctx.Event.UserID), message.Text("本群审核后端已设置为", k1))
+		})
+
+	engine.OnRegex(`^(添加|删除)本地审核(词|正则)([1-7])\s+(.+)$`, zero.SuperUserPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			k1 := ctx.State["regex_matched"].([]string)[1]
+			k2 := ctx.State["regex_matched"].([]string)[2]
+			typ, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[3])
+			k4 := strings.TrimSpace(ctx.State["regex_matched"].([]string)[4])
+			var err error
+			switch {
+			case k1 == "删除":
+				err = local.del(k2 == "正则", typ, k4)
+			case k2 == "正则":
+				err = local.addregexp(typ, k4)
+			default:
+				local.addword(typ, strings.Fields(k4)...)
+			}
+			if err == nil {
+				err = local.saveto(localpath)
+			}
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("已", k1, txttyp[typ], "类型的本地审核", k2))
+		})
+
+	engine.OnRegex(`^添加本地审核图片([1-7])`, zero.SuperUserPermission, zero.MustProvidePicture).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			typ, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+			_, err := local.addimage(typ, ctx.State["image_url"].([]string)[0])
+			if err == nil {
+				err = local.saveto(localpath)
+			}
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("已添加", txttyp[typ], "类型的本地审核图片"))
+		})
+
+	engine.OnRegex(`^删除本地审核图片(\d+)$`, zero.SuperUserPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			i, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+			err := local.delimage(i)
+			if err == nil {
+				err = local.saveto(localpath)
+			}
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("已删除"))
+		})
+
+	engine.OnFullMatch("查看本地审核规则", zero.SuperUserPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			b, err := text.RenderToBase64(local.String(), text.FontFile, 400, 20)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Image("base64://" + binary.BytesToString(b)))
 		})
 }
 
-// 客户端是否初始化检测
+// 群所用的审核后端是否可用, 百度后端需配置Key
 func hasinit(ctx *zero.Ctx) bool {
-	if bdcli == nil {
+	if _, ok := config.groupof(ctx.Event.GroupID).auditor().(baiduauditor); ok && bdcli == nil {
 		ctx.SendChain(message.Text("Key未配置"))
 		return false
 	}
 	return true
 }
 
-func parse2BaiduRes(resjson string) (bdres baiduRes, err error) {
-	err = json.Unmarshal(binary.StringToBytes(resjson), &bdres)
-	return
+func parse2BaiduRes(resjson string) (*baiduRes, error) {
+	bdres := &baiduRes{}
+	err := json.Unmarshal(binary.StringToBytes(resjson), bdres)
+	return bdres, err
 }
@@ -0,0 +1,302 @@
+package baiduaudit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	_ "image/gif"  // import gif decoding
+	_ "image/jpeg" // import jpeg decoding
+	_ "image/png"  // import png decoding
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/corona10/goimagehash"
+	_ "golang.org/x/image/webp" // import webp decoding
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/floatbox/web"
+)
+
+// maxhashdist 感知哈希距离不超过该值视为同一张图
+const maxhashdist = 8
+
+var (
+	errNoSuchRule = errors.New("没有这条规则")
+	errNilClient  = errors.New("Key未配置")
+)
+
+// auditor 审核后端
+type auditor interface {
+	textcensor(s string) (*baiduRes, error)
+	imgcensor(url string) (*baiduRes, error)
+}
+
+// baiduauditor 百度云审核
+type baiduauditor struct{}
+
+func (baiduauditor) textcensor(s string) (*baiduRes, error) {
+	if bdcli == nil {
+		return nil, errNilClient
+	}
+	return parse2BaiduRes(bdcli.TextCensor(s))
+}
+
+func (baiduauditor) imgcensor(url string) (*baiduRes, error) {
+	if bdcli == nil {
+		return nil, errNilClient
+	}
+	return parse2BaiduRes(bdcli.ImgCensorUrl(url, nil))
+}
+
+// imgrule 图片黑名单项
+type imgrule struct {
+	Hash uint64 `json:"hash"` // 感知哈希
+	Type int    `json:"type"` // 违规类型
+}
+
+// localRules 本地审核规则, 按违规类型存放关键词、正则与图片哈希
+type localRules struct {
+	mu      sync.RWMutex
+	Words   map[int][]string `json:"words"`
+	Regexps map[int][]string `json:"regexps"`
+	Images  []imgrule        `json:"images"`
+	res     map[int][]*regexp.Regexp
+}
+
+var local = newlocal() // 本地审核规则
+
+func newlocal() *localRules {
+	return &localRules{
+		Words:   make(map[int][]string, 8),
+		Regexps: make(map[int][]string, 8),
+		res:     make(map[int][]*regexp.Regexp, 8),
+	}
+}
+
+// 加载本地规则
+func (l *localRules) load(filename string) error {
+	if file.IsNotExist(filename) {
+		return nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err = json.NewDecoder(f).Decode(l)
+	if err != nil {
+		return err
+	}
+	for typ, exps := range l.Regexps {
+		for _, exp := range exps {
+			re, err := regexp.Compile(exp)
+			if err != nil {
+				return err
+			}
+			l.res[typ] = append(l.res[typ], re)
+		}
+	}
+	return nil
+}
+
+// 保存本地规则
+func (l *localRules) saveto(filename string) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(l)
+}
+
+func (l *localRules) addword(typ int, words ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, w := range words {
+		if !slices.Contains(l.Words[typ], w) {
+			l.Words[typ] = append(l.Words[typ], w)
+		}
+	}
+}
+
+func (l *localRules) addregexp(typ int, exp string) error {
+	re, err := regexp.Compile(exp)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if slices.Contains(l.Regexps[typ], exp) {
+		return nil
+	}
+	l.Regexps[typ] = append(l.Regexps[typ], exp)
+	l.res[typ] = append(l.res[typ], re)
+	return nil
+}
+
+// 删除关键词或正则
+func (l *localRules) del(isregexp bool, typ int, s string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m := l.Words
+	if isregexp {
+		m = l.Regexps
+	}
+	for i, v := range m[typ] {
+		if v != s {
+			continue
+		}
+		m[typ] = append(m[typ][:i], m[typ][i+1:]...)
+		if isregexp {
+			l.res[typ] = append(l.res[typ][:i], l.res[typ][i+1:]...)
+		}
+		return nil
+	}
+	return errNoSuchRule
+}
+
+// 添加图片到黑名单, 返回其感知哈希
+func (l *localRules) addimage(typ int, url string) (uint64, error) {
+	h, err := imghash(url)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.Images {
+		if l.Images[i].Hash == h {
+			l.Images[i].Type = typ
+			return h, nil
+		}
+	}
+	l.Images = append(l.Images, imgrule{Hash: h, Type: typ})
+	return h, nil
+}
+
+// 删除第 i 张黑名单图片, 从 1 开始
+func (l *localRules) delimage(i int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i <= 0 || i > len(l.Images) {
+		return errNoSuchRule
+	}
+	l.Images = append(l.Images[:i-1], l.Images[i:]...)
+	return nil
+}
+
+// 生成规则列表文本
+func (l *localRules) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	sb := strings.Builder{}
+	sb.WriteString("本地审核规则:")
+	for typ := 1; typ < len(txttyp); typ++ {
+		if len(l.Words[typ]) == 0 && len(l.Regexps[typ]) == 0 {
+			continue
+		}
+		sb.WriteString("\n[")
+		sb.WriteString(txttyp[typ])
+		sb.WriteString("]")
+		if len(l.Words[typ]) > 0 {
+			sb.WriteString("\n-关键词: ")
+			sb.WriteString(strings.Join(l.Words[typ], ", "))
+		}
+		for _, exp := range l.Regexps[typ] {
+			sb.WriteString("\n-正则: ")
+			sb.WriteString(exp)
+		}
+	}
+	for i, img := range l.Images {
+		sb.WriteString("\n图片")
+		sb.WriteString(strconv.Itoa(i + 1))
+		sb.WriteString(": ")
+		sb.WriteString(txttyp[img.Type])
+	}
+	return sb.String()
+}
+
+func (l *localRules) textcensor(s string) (*baiduRes, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := &baiduRes{Conclusion: "合规", ConclusionType: 1}
+	for typ := 1; typ < len(txttyp); typ++ {
+		var words []string
+		for _, w := range l.Words[typ] {
+			if strings.Contains(s, w) {
+				words = append(words, w)
+			}
+		}
+		for _, re := range l.res[typ] {
+			if w := re.FindString(s); w != "" {
+				words = append(words, w)
+			}
+		}
+		if len(words) > 0 {
+			res.Data = append(res.Data, &auditData{
+				SubType: typ,
+				Msg:     "存在" + txttyp[typ] + "不合规",
+				Hits:    []*hit{{Words: words}},
+			})
+		}
+	}
+	if len(res.Data) > 0 {
+		res.Conclusion, res.ConclusionType = "不合规", 2
+	}
+	return res, nil
+}
+
+func (l *localRules) imgcensor(url string) (*baiduRes, error) {
+	res := &baiduRes{Conclusion: "合规", ConclusionType: 1}
+	l.mu.RLock()
+	n := len(l.Images)
+	l.mu.RUnlock()
+	if n == 0 {
+		return res, nil
+	}
+	h, err := imghash(url)
+	if err != nil {
+		return nil, err
+	}
+	target := goimagehash.NewImageHash(h, goimagehash.PHash)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, img := range l.Images {
+		d, err := target.Distance(goimagehash.NewImageHash(img.Hash, goimagehash.PHash))
+		if err != nil || d > maxhashdist {
+			continue
+		}
+		res.Conclusion, res.ConclusionType = "不合规", 2
+		res.Data = append(res.Data, &auditData{
+			SubType: img.Type,
+			Msg:     "存在" + txttyp[img.Type] + "不合规图片",
+		})
+		break
+	}
+	return res, nil
+}
+
+// imghash 下载图片并计算感知哈希
+func imghash(url string) (uint64, error) {
+	data, err := web.GetData(url)
+	if err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	h, err := goimagehash.PerceptionHash(img)
+	if err != nil {
+		return 0, err
+	}
+	return h.GetHash(), nil
+}
@@ -33,12 +33,18 @@ func (bdres *baiduRes) audit(ctx *zero.Ctx, configpath string) {
 	}
 	// 获取群配置
 	group := config.groupof(ctx.Event.GroupID)
-	// 检测群配置里的不检测类型白名单, 忽略掉不检测的违规类型
-	for i, b := range group.copyWhiteListType() {
-		if i == bdres.Data[0].SubType && b {
-			return
+	// 检测群配置里的不检测类型白名单, 全部违规类型都不检测时忽略
+	wl := group.copyWhiteListType()
+	ignored := true
+	for _, d := range bdres.Data {
+		if d.SubType < 0 || d.SubType >= len(wl) || !wl[d.SubType] {
+			ignored = false
+			break
 		}
 	}
+	if ignored {
+		return
+	}
 	// 生成回复文本
 	res := group.reply(bdres)
 	// 撤回消息
@@ -182,6 +188,7 @@ type group struct {
 	BANTimeAddTime     int64                   // 禁言累加时间, 该值是开启禁累加功能后, 再次触发时, 根据被禁次数X该值计算出的禁言时间
 	WhiteListType      [8]bool                 // 类型白名单, 处于白名单类型的违规, 不会被触发 0:含多种类型, 具体看官方链接, 1:违禁违规、2:文本色情、3:敏感信息、4:恶意推广、5:低俗辱骂 6:恶意推广-联系方式、7:恶意推广-软文推广
 	AuditHistory       map[int64]*auditHistory // 被封禁用户列表
+	Backend            string                  // 审核后端, 百度或本地, 留空时配置了Key用百度, 否则用本地
 }
 
 func (g *group) set(f func(g *group)) {
@@ -190,6 +197,17 @@ func (g *group) set(f func(g *group)) {
 	g.mu.Unlock()
 }
 
+// 获取群使用的审核后端
+func (g *group) auditor() auditor {
+	g.mu.Lock()
+	b := g.Backend
+	g.mu.Unlock()
+	if b == backendlocal || (b == "" && bdcli == nil) {
+		return local
+	}
+	return baiduauditor{}
+}
+
 func (g *group) setWhiteListType(typ int, ok bool) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
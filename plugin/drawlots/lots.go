@@ -0,0 +1,147 @@
+package drawlots
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+)
+
+// manifestName 图包文件夹内的签表, gif 签的签表为同名 json
+const manifestName = "lots.json"
+
+// lot 签表中的一支签
+type lot struct {
+	File   string  `json:"file,omitempty"`  // 图包中的文件名
+	Frame  int     `json:"frame,omitempty"` // gif 中的帧序号, 从 0 开始
+	Weight float64 `json:"weight"`          // 权重, 未列出的签为 1
+	Text   string  `json:"text,omitempty"`  // 抽到时附带的签文
+}
+
+// manifestPath 签表位置
+func manifestPath(name string, fileInfo info) string {
+	if fileInfo.lotsType == "folder" {
+		return datapath + name + "/" + manifestName
+	}
+	return datapath + name + ".json"
+}
+
+// loadManifest 读取签表, 没有签表时返回 nil
+func loadManifest(name string, fileInfo info) ([]lot, error) {
+	data, err := os.ReadFile(manifestPath(name, fileInfo))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lots []lot
+	return lots, json.Unmarshal(data, &lots)
+}
+
+// weightedPick 按权重从 keys 中抽取一个, 签表中未列出的签权重为 1
+func weightedPick(keys []string, manifest []lot, key func(lot) string) (string, *lot) {
+	byKey := make(map[string]*lot, len(manifest))
+	for i := range manifest {
+		byKey[key(manifest[i])] = &manifest[i]
+	}
+	total := 0.0
+	weights := make([]float64, len(keys))
+	for i, k := range keys {
+		weights[i] = 1
+		if l, ok := byKey[k]; ok {
+			weights[i] = max(l.Weight, 0)
+		}
+		total += weights[i]
+	}
+	if total <= 0 {
+		k := keys[rand.Intn(len(keys))]
+		return k, byKey[k]
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return keys[i], byKey[keys[i]]
+		}
+		r -= w
+	}
+	k := keys[len(keys)-1]
+	return k, byKey[k]
+}
+
+func frameKey(l lot) string {
+	return strconv.Itoa(l.Frame)
+}
+
+func fileKey(l lot) string {
+	return l.File
+}
+
+// record 一次抽签记录
+type record struct {
+	ID     int64  `db:"id"`   // unix 纳秒
+	UserID int64  `db:"uid"`  // 用户
+	GrpID  int64  `db:"gid"`  // 群号, 私聊为负的 QQ 号
+	Lots   string `db:"lots"` // 签名
+	Result string `db:"res"`  // 图包文件名或 gif 帧序号
+	Text   string `db:"text"` // 签文
+	Time   int64  `db:"time"` // 抽签时间
+}
+
+var (
+	dbmu sync.Mutex
+	db   sql.Sqlite
+)
+
+func opendb() error {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	db = sql.New(datapath + "history.db")
+	err := db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	return db.Create("record", &record{})
+}
+
+func addRecord(r *record) error {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	return db.Insert("record", r)
+}
+
+// todayRecord 某人今天在某签的记录, 没有时返回 nil
+func todayRecord(uid int64, lots string) (*record, error) {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	r, err := sql.Find[record](&db, "record", "WHERE uid = ? AND lots = ? AND time >= ? ORDER BY id DESC LIMIT 1", uid, lots, today)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return &r, err
+}
+
+// getRecords 某人最近的抽签记录, lots 为空时不限签名
+func getRecords(uid int64, lots string, limit int) ([]*record, error) {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	var (
+		rs  []*record
+		err error
+	)
+	if lots == "" {
+		rs, err = sql.FindAll[record](&db, "record", "WHERE uid = ? ORDER BY id DESC LIMIT ?", uid, limit)
+	} else {
+		rs, err = sql.FindAll[record](&db, "record", "WHERE uid = ? AND lots = ? ORDER BY id DESC LIMIT ?", uid, lots, limit)
+	}
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return rs, err
+}
@@ -8,10 +8,10 @@ import (
 	"image/draw"
 	"image/gif"
 	"io"
-	"math/rand"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	fcext "github.com/FloatTech/floatbox/ctxext"
 	"github.com/FloatTech/floatbox/file"
@@ -47,7 +47,12 @@ var (
 		Brief:            "多功能抽签",
 		Help: "支持图包文件夹和gif抽签\n" +
 			"-------------\n" +
-			"- (刷新)抽签列表\n- 抽[签名]签\n- 看[gif签名]签\n- 加[签名]签[gif图片]\n- 删[gif签名]签",
+			"- (刷新)抽签列表\n- 抽[签名]签\n- 看[gif签名]签\n- 加[签名]签[gif图片]\n- 删[gif签名]签\n" +
+			"- 抽签记录[签名]\n- [开启|关闭]每日抽签\n" +
+			"-------------\n" +
+			"开启每日抽签后, 每人每天每种签只能抽一次, 再抽会返回当天的结果\n" +
+			"图包文件夹内的 lots.json 或 gif 同名的 json 为签表, 可设置每支签的权重与签文, 如\n" +
+			"[{\"file\":\"1.jpg\",\"weight\":2,\"text\":\"大吉\"}] 或 [{\"frame\":0,\"weight\":0.5,\"text\":\"凶\"}]",
 		PrivateDataFolder: "drawlots",
 	}).ApplySingle(ctxext.DefaultSingle)
 	datapath = file.BOTPATH + "/" + en.DataFolder()
@@ -75,34 +80,123 @@ func init() {
 		}
 		ctx.SendChain(message.Image("base64://" + helper.BytesToString(textPic)))
 	})
-	en.OnRegex(`^抽(.+)签$`).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+	getdb := fcext.DoOnceOnSuccess(func(ctx *zero.Ctx) bool {
+		err := opendb()
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return false
+		}
+		return true
+	})
+	en.OnRegex(`^抽(.+)签$`, getdb).SetBlock(true).Handle(func(ctx *zero.Ctx) {
 		lotsType := ctx.State["regex_matched"].([]string)[1]
 		fileInfo, ok := lotsList[lotsType]
 		if !ok {
 			ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text("才...才没有", lotsType, "签这种东西啦")))
 			return
 		}
+		uid := ctx.Event.UserID
+		if isDaily(ctx) {
+			r, err := todayRecord(uid, lotsType)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if r != nil {
+				sendLot(ctx, lotsType, fileInfo, r, true)
+				return
+			}
+		}
+		manifest, err := loadManifest(lotsType, fileInfo)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		var (
+			res string
+			l   *lot
+		)
 		if fileInfo.lotsType == "folder" {
-			picPath, err := randFile(lotsType, 3)
+			res, l, err = randFile(lotsType, manifest)
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
 				return
 			}
-			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Image("file:///"+picPath))
+		} else {
+			frames := make([]string, fileInfo.quantity)
+			for i := range frames {
+				frames[i] = strconv.Itoa(i)
+			}
+			if manifest == nil {
+				res = frames[fcext.RandSenderPerDayN(uid, len(frames))]
+			} else {
+				res, l = weightedPick(frames, manifest, frameKey)
+			}
+		}
+		r := &record{
+			ID:     time.Now().UnixNano(),
+			UserID: uid,
+			GrpID:  ctx.Event.GroupID,
+			Lots:   lotsType,
+			Result: res,
+			Time:   time.Now().Unix(),
+		}
+		if r.GrpID == 0 {
+			r.GrpID = -uid
+		}
+		if l != nil {
+			r.Text = l.Text
+		}
+		err = addRecord(r)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
 			return
 		}
-		lotsImg, err := randGif(lotsType+"."+fileInfo.lotsType, ctx.Event.UserID)
+		sendLot(ctx, lotsType, fileInfo, r, false)
+	})
+	en.OnRegex(`^抽签记录\s*(.*)$`, getdb).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		lotsName := strings.TrimSpace(ctx.State["regex_matched"].([]string)[1])
+		rs, err := getRecords(ctx.Event.UserID, lotsName, 10)
 		if err != nil {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return
 		}
-		// 生成图片
-		data, err := factory.ToBytes(lotsImg)
+		if len(rs) == 0 {
+			ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text("你还没有抽过签哦~")))
+			return
+		}
+		msg := &strings.Builder{}
+		msg.WriteString("最近的抽签记录:")
+		for _, r := range rs {
+			msg.WriteString("\n")
+			msg.WriteString(time.Unix(r.Time, 0).Format("01-02 15:04"))
+			msg.WriteString(" " + r.Lots + "签: ")
+			msg.WriteString(resultName(r))
+		}
+		ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text(msg.String())))
+	})
+	en.OnRegex(`^(开启|关闭)每日抽签$`, zero.UserOrGrpAdmin).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+		if !ok {
+			ctx.SendChain(message.Text("ERROR: 找不到插件"))
+			return
+		}
+		gid := ctx.Event.GroupID
+		if gid == 0 {
+			gid = -ctx.Event.UserID
+		}
+		data := c.GetData(gid)
+		if ctx.State["regex_matched"].([]string)[1] == "开启" {
+			data |= 1
+		} else {
+			data &^= 1
+		}
+		err := c.SetData(gid, data)
 		if err != nil {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return
 		}
-		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.ImageBytes(data))
+		ctx.SendChain(message.Text("已", ctx.State["regex_matched"].([]string)[1], "每日抽签"))
 	})
 	en.OnRegex(`^看(.+)签$`, zero.UserOrGrpAdmin).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
 		id := ctx.Event.MessageID
@@ -164,6 +258,7 @@ func init() {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return
 		}
+		_ = os.Remove(manifestPath(lotsName, fileInfo))
 		delete(lotsList, lotsName)
 		ctx.Send(message.ReplyWithMessage(id, message.Text("成功！")))
 	})
@@ -182,14 +277,21 @@ func getList() (list map[string]info, err error) {
 	for _, lots := range files {
 		if lots.IsDir() {
 			files, _ := os.ReadDir(datapath + "/" + lots.Name())
+			n := 0
+			for _, f := range files {
+				if !f.IsDir() && f.Name() != manifestName {
+					n++
+				}
+			}
 			list[lots.Name()] = info{
 				lotsType: "folder",
-				quantity: len(files),
+				quantity: n,
 			}
 			continue
 		}
 		before, after, ok := strings.Cut(lots.Name(), ".")
-		if !ok || before == "" {
+		// 跳过签表与抽签记录
+		if !ok || before == "" || after == "json" || strings.HasPrefix(after, "db") {
 			continue
 		}
 		file, err := os.Open(datapath + "/" + lots.Name())
@@ -209,28 +311,27 @@ func getList() (list map[string]info, err error) {
 	return
 }
 
-func randFile(path string, indexMax int) (string, error) {
-	picPath := datapath + path
-	files, err := os.ReadDir(picPath)
+// randFile 按签表的权重从图包中抽取一张图片, 返回文件名
+func randFile(path string, manifest []lot) (string, *lot, error) {
+	files, err := os.ReadDir(datapath + path)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	if len(files) > 0 {
-		drawFile := files[rand.Intn(len(files))]
-		// 如果是文件夹就递归
-		if drawFile.IsDir() {
-			indexMax--
-			if indexMax <= 0 {
-				return "", errors.New("图包[" + path + "]存在太多非图片文件,请清理~")
-			}
-			return randFile(path, indexMax)
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && f.Name() != manifestName {
+			names = append(names, f.Name())
 		}
-		return picPath + "/" + drawFile.Name(), err
 	}
-	return "", errors.New("图包[" + path + "]不存在签内容！")
+	if len(names) == 0 {
+		return "", nil, errors.New("图包[" + path + "]不存在签内容！")
+	}
+	name, l := weightedPick(names, manifest, fileKey)
+	return name, l, nil
 }
 
-func randGif(gifName string, uid int64) (image.Image, error) {
+// randGif 取出 gif 的第 frame 帧
+func randGif(gifName string, frame int) (image.Image, error) {
 	name := datapath + gifName
 	file, err := os.Open(name)
 	if err != nil {
@@ -265,7 +366,10 @@ func randGif(gifName string, uid int64) (image.Image, error) {
 		rect.Max = maxP
 	}
 	img := image.NewRGBA(rect)
-	b := fcext.RandSenderPerDayN(uid, len(im.Image)) + 1
+	if frame < 0 || frame >= len(im.Image) {
+		return nil, errors.New("签[" + gifName + "]没有第" + strconv.Itoa(frame+1) + "支签")
+	}
+	b := frame + 1
 	a := 0
 	if b > 8 {
 		a = b - 8
@@ -275,3 +379,61 @@ func randGif(gifName string, uid int64) (image.Image, error) {
 	}
 	return img, err
 }
+
+// isDaily 是否开启了每日抽签
+func isDaily(ctx *zero.Ctx) bool {
+	c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+	if !ok {
+		return false
+	}
+	gid := ctx.Event.GroupID
+	if gid == 0 {
+		gid = -ctx.Event.UserID
+	}
+	return c.GetData(gid)&1 == 1
+}
+
+// resultName 记录中抽到的签, 有签文时显示签文
+func resultName(r *record) string {
+	if r.Text != "" {
+		return r.Text
+	}
+	// gif 的帧号从 0 开始记录
+	if frame, err := strconv.Atoi(r.Result); err == nil {
+		return "第" + strconv.Itoa(frame+1) + "支"
+	}
+	return r.Result
+}
+
+// sendLot 发送抽签结果, again 表示是当天已抽过的结果
+func sendLot(ctx *zero.Ctx, name string, fileInfo info, r *record, again bool) {
+	msg := message.Message{message.Reply(ctx.Event.MessageID)}
+	if again {
+		msg = append(msg, message.Text("今天已经抽过", name, "签啦, 结果是:\n"))
+	}
+	if fileInfo.lotsType == "folder" {
+		msg = append(msg, message.Image("file:///"+datapath+name+"/"+r.Result))
+	} else {
+		frame, err := strconv.Atoi(r.Result)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		lotsImg, err := randGif(name+"."+fileInfo.lotsType, frame)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		// 生成图片
+		data, err := factory.ToBytes(lotsImg)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		msg = append(msg, message.ImageBytes(data))
+	}
+	if r.Text != "" {
+		msg = append(msg, message.Text("\n", r.Text))
+	}
+	ctx.Send(msg)
+}
@@ -4,27 +4,35 @@ package driftbottle
 import (
 	"fmt"
 	"hash/crc64"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
 
 	"github.com/FloatTech/floatbox/binary"
+	"github.com/FloatTech/floatbox/file"
 	sql "github.com/FloatTech/sqlite"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
+	"github.com/sirupsen/logrus"
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/message"
 )
 
 type sea struct {
-	ID   int64  `db:"id"`   // ID qq_grp_name_msg 的 crc64 hashCheck.
-	QQ   int64  `db:"qq"`   // Get current user(Who sends this)
-	Name string `db:"Name"` //  his or her name at that time:P
-	Msg  string `db:"msg"`  // What he or she sent to bot?
-	Grp  int64  `db:"grp"`  // which group sends this msg?
-	Time string `db:"time"` // we need to know the current time,master>
+	ID       int64  `db:"id"`       // ID qq_grp_name_msg 的 crc64 hashCheck.
+	QQ       int64  `db:"qq"`       // Get current user(Who sends this)
+	Name     string `db:"Name"`     //  his or her name at that time:P
+	Msg      string `db:"msg"`      // What he or she sent to bot?
+	Grp      int64  `db:"grp"`      // which group sends this msg?
+	Time     string `db:"time"`     // we need to know the current time,master>
+	Sea      int64  `db:"sea"`      // 所在海域, 0 为公共海域, 否则为群号
+	Anon     bool   `db:"anon"`     // 匿名投递
+	Hidden   bool   `db:"hidden"`   // 被举报, 等待审核
+	Reporter int64  `db:"reporter"` // 举报人
 }
 
 var seaSide sql.Sqlite
@@ -34,9 +42,16 @@ var seaLocker sync.RWMutex
 
 func init() {
 	en := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
-		DisableOnDefault:  false,
-		Brief:             "漂流瓶",
-		Help:              "- @bot pick" + "- @bot throw xxx (xxx为投递内容)",
+		DisableOnDefault: false,
+		Brief:            "漂流瓶",
+		Help: "- @bot pick\n" +
+			"- @bot throw xxx (xxx为投递内容, 可带图片与语音)\n" +
+			"- @bot 匿名throw xxx (不显示投递人与群号)\n" +
+			"- 回复漂流瓶[ID] xxx (回复会私聊或在原群转达给投递人)\n" +
+			"- 举报漂流瓶[ID] [理由]\n" +
+			"- [开启|关闭]私有海域 (开启后本群只捞本群投递的瓶子)\n" +
+			"- [超级用户]漂流瓶审核\n" +
+			"- [超级用户][恢复|销毁]漂流瓶[ID]",
 		PrivateDataFolder: "driftbottle",
 	})
 	seaSide = sql.New(en.DataFolder() + "sea.db")
@@ -44,44 +59,220 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	mediaFolder = file.BOTPATH + "/" + en.DataFolder() + "media/"
+	err = os.MkdirAll(mediaFolder, 0755)
+	if err != nil {
+		panic(err)
+	}
 
-	_ = createChannel(&seaSide)
+	err = createChannel(&seaSide)
+	if err != nil {
+		panic(err)
+	}
 	en.OnFullMatch("pick", zero.OnlyToMe, zero.OnlyGroup).Limit(ctxext.LimitByGroup).SetBlock(true).Handle(func(ctx *zero.Ctx) {
-		be, err := fetchBottle(&seaSide)
+		be, err := fetchBottle(&seaSide, seaOf(ctx))
+		if err == sql.ErrNullResult {
+			ctx.SendChain(message.Text("海里空空如也, 什么也没捞到~"))
+			return
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERR:", err))
+			return
+		}
+		idstr := strconv.FormatInt(be.ID, 10)
+		sender := be.Name + "(" + strconv.FormatInt(be.QQ, 10) + ")" + "\n群号: " + strconv.FormatInt(be.Grp, 10)
+		if be.Anon {
+			sender = "匿名"
+		}
+		ms, err := getMedia(&seaSide, be.ID)
 		if err != nil {
 			ctx.SendChain(message.Text("ERR:", err))
+			return
 		}
-		idstr := strconv.Itoa(int(be.ID))
-		qqstr := strconv.Itoa(int(be.QQ))
-		grpstr := strconv.Itoa(int(be.Grp))
 		botname := zero.BotConfig.NickName[0]
-		msg := message.Message{message.CustomNode(botname, ctx.Event.SelfID, botname+"试着帮你捞出来了这个~\nID:"+idstr+"\n投递人: "+be.Name+"("+qqstr+")"+"\n群号: "+grpstr+"\n时间: "+be.Time+"\n内容: \n"+be.Msg)}
-		ctx.Send(msg)
+		content := message.Message{message.Text(botname + "试着帮你捞出来了这个~\nID:" + idstr + "\n投递人: " + sender + "\n时间: " + be.Time + "\n内容: \n" + be.Msg)}
+		var records message.Message
+		for _, m := range ms {
+			// 语音无法放在合并转发中, 单独发送
+			if m.Type == "record" {
+				records = append(records, m.segment())
+				continue
+			}
+			content = append(content, m.segment())
+		}
+		content = append(content, message.Text("\n\n发送 回复漂流瓶"+idstr+" xxx 回复投递人, 发送 举报漂流瓶"+idstr+" 举报"))
+		ctx.Send(message.Message{message.CustomNode(botname, ctx.Event.SelfID, content)})
+		for _, r := range records {
+			ctx.SendChain(r)
+		}
 	})
 
-	en.OnRegex(`throw.*?(.*)`, zero.OnlyToMe, zero.OnlyGroup).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+	en.OnRegex(`(匿名)?throw.*?(.*)`, zero.OnlyToMe, zero.OnlyGroup).SetBlock(true).Handle(func(ctx *zero.Ctx) {
 		senderFormatTime := time.Unix(ctx.Event.Time, 0).Format("2006-01-02 15:04:05")
-		rawSenderMessage := ctx.State["regex_matched"].([]string)[1]
-		rawMessageCallBack := message.UnescapeCQCodeText(rawSenderMessage)
-		keyWordsNum := utf8.RuneCountInString(rawMessageCallBack)
-		if keyWordsNum < 10 {
+		regex := ctx.State["regex_matched"].([]string)
+		text, urls, types, err := parseBottle(ctx.Event.Message)
+		if err != nil {
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: ", err))
+			return
+		}
+		keyWordsNum := utf8.RuneCountInString(text)
+		if keyWordsNum < 10 && len(urls) == 0 {
 			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("需要投递的内容过少( "))
 			return
 		}
 		// check current needs and prepare to throw drift_bottle.
-		err = globalbottle(
+		be := globalbottle(
 			ctx.Event.UserID,
 			ctx.Event.GroupID,
 			senderFormatTime,
 			ctx.CardOrNickName(ctx.Event.UserID),
-			rawMessageCallBack,
-		).throw(&seaSide)
+			text,
+		)
+		be.Sea = seaOf(ctx)
+		be.Anon = regex[1] != ""
+		if len(urls) == 0 {
+			err = be.throw(&seaSide)
+		} else {
+			var ms []*media
+			ms, err = saveMedia(be.ID, urls, types)
+			if err == nil {
+				err = be.throwWithMedia(&seaSide, ms)
+			}
+		}
 		if err != nil {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return
 		}
+		if len(urls) > 0 {
+			err = cleanMedia(&seaSide)
+			if err != nil {
+				logrus.Warnln("[driftbottle] 清理漂流瓶媒体失败:", err)
+			}
+		}
 		ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text("已经帮你丢出去了哦~")))
 	})
+
+	en.OnRegex(`^回复漂流瓶\s*(-?\d+)\s+([\s\S]+)$`).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
+		regex := ctx.State["regex_matched"].([]string)
+		id, _ := strconv.ParseInt(regex[1], 10, 64)
+		be, err := getBottle(&seaSide, id)
+		if err == sql.ErrNullResult || err == nil && be.Hidden {
+			ctx.SendChain(message.Text("找不到这个漂流瓶哦~"))
+			return
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		reply := message.UnescapeCQCodeText(strings.TrimSpace(regex[2]))
+		text := "你的漂流瓶(ID:" + regex[1] + ")收到了" + ctx.CardOrNickName(ctx.Event.UserID) + "的回复:\n" + reply + "\n\n原内容: \n" + be.Msg
+		if ctx.SendPrivateMessage(be.QQ, message.Text(text)) == 0 {
+			if be.Grp == 0 || ctx.SendGroupMessage(be.Grp, message.Message{message.At(be.QQ), message.Text("\n", text)}) == 0 {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("投递人已经联系不上了..."))
+				return
+			}
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("已经帮你把回复送到投递人手上了~"))
+	})
+
+	en.OnRegex(`^举报漂流瓶\s*(-?\d+)\s*(.*)$`).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
+		regex := ctx.State["regex_matched"].([]string)
+		id, _ := strconv.ParseInt(regex[1], 10, 64)
+		be, err := reportBottle(&seaSide, id, ctx.Event.UserID)
+		if err == sql.ErrNullResult {
+			ctx.SendChain(message.Text("找不到这个漂流瓶哦~"))
+			return
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(zero.BotConfig.SuperUsers) > 0 {
+			ctx.SendPrivateMessage(zero.BotConfig.SuperUsers[0], message.Text(
+				"漂流瓶(ID:", be.ID, ")被", ctx.Event.UserID, "举报, 理由: ", regex[2],
+				"\n投递人: ", be.Name, "(", be.QQ, ")\n群号: ", be.Grp, "\n内容: \n", be.Msg,
+				"\n\n发送 恢复漂流瓶", be.ID, " 或 销毁漂流瓶", be.ID, " 处理",
+			))
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("已举报, 这个瓶子在审核前不会再被捞到了~"))
+	})
+
+	en.OnRegex(`^(开启|关闭)私有海域$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+		if !ok {
+			ctx.SendChain(message.Text("ERROR: 找不到插件"))
+			return
+		}
+		data := c.GetData(ctx.Event.GroupID)
+		if ctx.State["regex_matched"].([]string)[1] == "开启" {
+			data |= 1
+		} else {
+			data &^= 1
+		}
+		err := c.SetData(ctx.Event.GroupID, data)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("已", ctx.State["regex_matched"].([]string)[1], "私有海域"))
+	})
+
+	en.OnFullMatch("漂流瓶审核", zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		bs, err := hiddenBottles(&seaSide, 10)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(bs) == 0 {
+			ctx.SendChain(message.Text("没有待审核的漂流瓶~"))
+			return
+		}
+		botname := zero.BotConfig.NickName[0]
+		msg := make(message.Message, 0, len(bs))
+		for _, be := range bs {
+			content := message.Message{message.Text(fmt.Sprint(
+				"ID:", be.ID, "\n举报人: ", be.Reporter, "\n投递人: ", be.Name, "(", be.QQ, ")\n群号: ", be.Grp, "\n时间: ", be.Time, "\n内容: \n", be.Msg,
+			))}
+			ms, err := getMedia(&seaSide, be.ID)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			for _, m := range ms {
+				if m.Type == "record" {
+					content = append(content, message.Text("\n[语音]"))
+					continue
+				}
+				content = append(content, m.segment())
+			}
+			msg = append(msg, message.CustomNode(botname, ctx.Event.SelfID, content))
+		}
+		ctx.Send(msg)
+	})
+
+	en.OnRegex(`^(恢复|销毁)漂流瓶\s*(-?\d+)$`, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		regex := ctx.State["regex_matched"].([]string)
+		id, _ := strconv.ParseInt(regex[2], 10, 64)
+		err := reviewBottle(&seaSide, id, regex[1] == "销毁")
+		if err == sql.ErrNullResult {
+			ctx.SendChain(message.Text("找不到这个漂流瓶哦~"))
+			return
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("已", regex[1], "漂流瓶", id))
+	})
+}
+
+// seaOf 群所在的海域, 开启私有海域时为群号
+func seaOf(ctx *zero.Ctx) int64 {
+	c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+	if ok && c.GetData(ctx.Event.GroupID)&1 == 1 {
+		return ctx.Event.GroupID
+	}
+	return 0
 }
 
 func globalbottle(qq, grp int64, time, name, msg string) *sea { // Check as if the User is available and collect information to store.
@@ -92,18 +283,89 @@ func globalbottle(qq, grp int64, time, name, msg string) *sea { // Check as if t
 func (be *sea) throw(db *sql.Sqlite) error {
 	seaLocker.Lock()
 	defer seaLocker.Unlock()
-	return db.Insert("global", be)
+	return db.Insert("bottle", be)
 }
 
-func fetchBottle(db *sql.Sqlite) (*sea, error) {
+func fetchBottle(db *sql.Sqlite, area int64) (*sea, error) {
 	seaLocker.Lock()
 	defer seaLocker.Unlock()
 	be := new(sea)
-	return be, db.Pick("global", be)
+	return be, db.Find("bottle", be, "WHERE sea = ? AND hidden = 0 ORDER BY RANDOM() limit 1", area)
+}
+
+func getBottle(db *sql.Sqlite, id int64) (*sea, error) {
+	seaLocker.RLock()
+	defer seaLocker.RUnlock()
+	be := new(sea)
+	return be, db.Find("bottle", be, "WHERE id = ?", id)
+}
+
+// reportBottle 举报后隐藏瓶子, 等待审核
+func reportBottle(db *sql.Sqlite, id, reporter int64) (*sea, error) {
+	seaLocker.Lock()
+	defer seaLocker.Unlock()
+	be := new(sea)
+	err := db.Find("bottle", be, "WHERE id = ? AND hidden = 0", id)
+	if err != nil {
+		return nil, err
+	}
+	be.Hidden = true
+	be.Reporter = reporter
+	return be, db.Insert("bottle", be)
+}
+
+func hiddenBottles(db *sql.Sqlite, n int) ([]*sea, error) {
+	seaLocker.RLock()
+	defer seaLocker.RUnlock()
+	bs, err := sql.FindAll[sea](db, "bottle", "WHERE hidden = 1 limit "+strconv.Itoa(n))
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return bs, err
+}
+
+// reviewBottle 审核被举报的瓶子, remove 为 true 时销毁, 否则恢复
+func reviewBottle(db *sql.Sqlite, id int64, remove bool) error {
+	seaLocker.Lock()
+	defer seaLocker.Unlock()
+	be := new(sea)
+	err := db.Find("bottle", be, "WHERE id = ? AND hidden = 1", id)
+	if err != nil {
+		return err
+	}
+	if remove {
+		return removeBottle(db, id)
+	}
+	be.Hidden = false
+	be.Reporter = 0
+	return db.Insert("bottle", be)
 }
 
 func createChannel(db *sql.Sqlite) error {
 	seaLocker.Lock()
 	defer seaLocker.Unlock()
-	return db.Create("global", &sea{})
+	err := db.Create("bottle", &sea{})
+	if err != nil {
+		return err
+	}
+	err = db.Create("media", &media{})
+	if err != nil {
+		return err
+	}
+	// 迁移旧版的公共海域
+	tables, err := db.ListTables()
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if t != "global" {
+			continue
+		}
+		_, err = db.Exec("INSERT OR IGNORE INTO bottle SELECT id, qq, Name, msg, grp, time, 0, 0, 0, 0 FROM global;")
+		if err != nil {
+			return err
+		}
+		return db.Drop("global")
+	}
+	return nil
 }
@@ -0,0 +1,183 @@
+package driftbottle
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FloatTech/floatbox/web"
+	sql "github.com/FloatTech/sqlite"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+const (
+	maxMediaNum   = 4                   // 每个瓶子最多的图片与语音数
+	maxMediaSize  = 5 << 20             // 单个文件大小上限
+	maxMediaTotal = 512 << 20           // 媒体文件总大小上限, 超出时清理最早的瓶子
+	mediaExpire   = 90 * 24 * time.Hour // 带媒体的瓶子的保存时间
+)
+
+// mediaFolder 媒体文件位置, 在 init 中设置
+var mediaFolder string
+
+// media 瓶子中的图片或语音
+type media struct {
+	ID     int64  `db:"id"`     // unix 纳秒
+	Bottle int64  `db:"bottle"` // 所属瓶子
+	Type   string `db:"type"`   // image 或 record
+	File   string `db:"file"`   // 内容的 md5, 同样的内容只存一份
+	Size   int64  `db:"size"`   // 文件大小
+}
+
+func (m *media) segment() message.Segment {
+	if m.Type == "record" {
+		return message.Record("file:///" + mediaFolder + m.File)
+	}
+	return message.Image("file:///" + mediaFolder + m.File)
+}
+
+// parseBottle 从投递的消息中取出文字与媒体, 文字为 throw 之后的部分
+func parseBottle(msg message.Message) (text string, urls []string, types []string, err error) {
+	sb := strings.Builder{}
+	for _, seg := range msg {
+		switch seg.Type {
+		case "text":
+			sb.WriteString(seg.Data["text"])
+		case "image", "record":
+			url := seg.Data["url"]
+			if url == "" && strings.HasPrefix(seg.Data["file"], "http") {
+				url = seg.Data["file"]
+			}
+			if url == "" {
+				return "", nil, nil, errors.New("无法获取" + mediaName(seg.Type) + "地址")
+			}
+			urls = append(urls, url)
+			types = append(types, seg.Type)
+		}
+	}
+	if len(urls) > maxMediaNum {
+		return "", nil, nil, errors.New("每个瓶子最多只能装" + strconv.Itoa(maxMediaNum) + "张图片或语音")
+	}
+	_, text, _ = strings.Cut(sb.String(), "throw")
+	return strings.TrimSpace(text), urls, types, nil
+}
+
+func mediaName(typ string) string {
+	if typ == "record" {
+		return "语音"
+	}
+	return "图片"
+}
+
+// saveMedia 下载媒体到本地, 瓶子在原链接失效后也能被捞到
+func saveMedia(bottle int64, urls, types []string) ([]*media, error) {
+	ms := make([]*media, len(urls))
+	now := time.Now().UnixNano()
+	for i, url := range urls {
+		data, err := web.GetData(url)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxMediaSize {
+			return nil, errors.New(mediaName(types[i]) + "太大了, 最多" + strconv.Itoa(maxMediaSize>>20) + "MB")
+		}
+		sum := md5.Sum(data)
+		name := hex.EncodeToString(sum[:])
+		err = os.WriteFile(mediaFolder+name, data, 0644)
+		if err != nil {
+			return nil, err
+		}
+		ms[i] = &media{ID: now + int64(i), Bottle: bottle, Type: types[i], File: name, Size: int64(len(data))}
+	}
+	return ms, nil
+}
+
+// throwWithMedia 投递瓶子并记录其中的媒体
+func (be *sea) throwWithMedia(db *sql.Sqlite, ms []*media) error {
+	seaLocker.Lock()
+	defer seaLocker.Unlock()
+	for _, m := range ms {
+		err := db.Insert("media", m)
+		if err != nil {
+			return err
+		}
+	}
+	return db.Insert("bottle", be)
+}
+
+func getMedia(db *sql.Sqlite, bottle int64) ([]*media, error) {
+	seaLocker.RLock()
+	defer seaLocker.RUnlock()
+	ms, err := sql.FindAll[media](db, "media", "WHERE bottle = ? ORDER BY id ASC", bottle)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return ms, err
+}
+
+// removeBottle 删除瓶子及其媒体, 不再被引用的文件一并删除. 调用时需持有锁
+func removeBottle(db *sql.Sqlite, id int64) error {
+	ms, err := sql.FindAll[media](db, "media", "WHERE bottle = ?", id)
+	if err != nil && err != sql.ErrNullResult {
+		return err
+	}
+	err = db.Del("media", "WHERE bottle = ?", id)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if !db.CanFind("media", "WHERE file = ?", m.File) {
+			_ = os.Remove(mediaFolder + m.File)
+		}
+	}
+	return db.Del("bottle", "WHERE id = ?", id)
+}
+
+// cleanMedia 清理过期的带媒体的瓶子, 总大小超出上限时从最早的瓶子开始清理
+func cleanMedia(db *sql.Sqlite) error {
+	seaLocker.Lock()
+	defer seaLocker.Unlock()
+	type bottleID struct {
+		ID int64 `db:"id"`
+	}
+	expire := time.Now().Add(-mediaExpire).Format("2006-01-02 15:04:05")
+	for {
+		expired, err := sql.Query[bottleID](db, "SELECT bottle.id AS id FROM bottle JOIN media ON bottle.id = media.bottle WHERE bottle.time < ? ORDER BY bottle.time ASC LIMIT 1;", expire)
+		if err == sql.ErrNullResult {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		err = removeBottle(db, expired.ID)
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		total, err := sql.Query[struct {
+			N int64 `db:"n"`
+		}](db, "SELECT IFNULL(SUM(size), 0) AS n FROM (SELECT DISTINCT file, size FROM media);")
+		if err != nil {
+			return err
+		}
+		if total.N <= maxMediaTotal {
+			return nil
+		}
+		oldest, err := sql.Query[bottleID](db, "SELECT bottle.id AS id FROM bottle JOIN media ON bottle.id = media.bottle ORDER BY bottle.time ASC LIMIT 1;")
+		if err == sql.ErrNullResult {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = removeBottle(db, oldest.ID)
+		if err != nil {
+			return err
+		}
+	}
+}
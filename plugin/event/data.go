@@ -1,5 +1,10 @@
 package event
 
+import "time"
+
+// defaultexpire 请求默认有效期(小时)
+const defaultexpire = 72
+
 type storage int64
 
 // 申请
@@ -7,7 +12,7 @@ func (s *storage) setapply(on bool) {
 	if on {
 		*s |= 0b001
 	} else {
-		*s &= 0b110
+		*s &^= 0b001
 	}
 }
 
@@ -16,7 +21,7 @@ func (s *storage) setinvite(on bool) {
 	if on {
 		*s |= 0b010
 	} else {
-		*s &= 0b101
+		*s &^= 0b010
 	}
 }
 
@@ -25,7 +30,7 @@ func (s *storage) setmaster(on bool) {
 	if on {
 		*s |= 0b100
 	} else {
-		*s &= 0b011
+		*s &^= 0b100
 	}
 }
 
@@ -43,3 +48,17 @@ func (s *storage) isinviteon() bool {
 func (s *storage) ismasteroff() bool {
 	return *s&0b100 > 0
 }
+
+// 有效期, 存于第 8 位及以上, 单位为小时
+func (s *storage) setexpire(hours int64) {
+	*s = *s&0xff | storage(hours<<8)
+}
+
+// 有效期
+func (s *storage) expire() time.Duration {
+	h := int64(*s) >> 8
+	if h <= 0 {
+		h = defaultexpire
+	}
+	return time.Duration(h) * time.Hour
+}
@@ -4,6 +4,7 @@ package event
 import (
 	"encoding/binary"
 	"strconv"
+	"strings"
 	"time"
 
 	ctrl "github.com/FloatTech/zbpctrl"
@@ -20,22 +21,32 @@ func init() {
 		Brief:            "好友申请和群聊邀请事件处理",
 		Help: "- [开启|关闭]自动同意[申请|邀请|主人]\n" +
 			"- [同意|拒绝][申请|邀请][flag]\n" +
-			"Tips: 信息默认发送给主人列表第一位, 默认同意所有主人的事件, flag跟随事件一起发送",
+			"- 待处理请求\n" +
+			"- [同意|拒绝]请求[编号] [备注/理由]\n" +
+			"- 回复请求通知: [同意|拒绝] [备注/理由]\n" +
+			"- [同意|拒绝]全部[申请|邀请|请求]\n" +
+			"- 设置请求有效期[N][小时|天]\n" +
+			"Tips: 信息发送给所有主人, 默认同意所有主人的事件, 请求默认" + strconv.Itoa(defaultexpire) + "小时后过期",
+		PrivateDataFolder: "event",
 	})
+	go func() {
+		err := q.open(engine.DataFolder() + "request.db")
+		if err != nil {
+			panic(err)
+		}
+		q.clean()
+	}()
 	engine.On("request/group/invite").SetBlock(false).
 		Handle(func(ctx *zero.Ctx) {
 			c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
 			if ok {
 				su := zero.BotConfig.SuperUsers[0]
 				now := time.Unix(ctx.Event.Time, 0).Format("2006-01-02 15:04:05")
-				flag, err := strconv.ParseInt(ctx.Event.Flag, 10, 64)
+				es, err := encodeflag(ctx.Event.Flag)
 				if err != nil {
 					ctx.SendChain(message.Text("ERROR: ", err))
 					return
 				}
-				var buf [8]byte
-				binary.BigEndian.PutUint64(buf[:], uint64(flag))
-				es := base14.EncodeToString(buf[1:])
 				userid := ctx.Event.UserID
 				username := ctx.CardOrNickName(userid)
 				data := (storage)(c.GetData(-su))
@@ -44,21 +55,34 @@ func init() {
 				logrus.Info("[event]收到来自[", username, "](", userid, ")的群聊邀请，群:[", groupname, "](", groupid, ")")
 				if data.isinviteon() || (!data.ismasteroff() && zero.SuperUserPermission(ctx)) {
 					ctx.SetGroupAddRequest(ctx.Event.Flag, "invite", true, "")
-					ctx.SendPrivateForwardMessage(su, message.Message{message.CustomNode(username, userid,
-						"已自动同意在"+now+"收到来自"+
-							"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")的群聊邀请"+
-							"\n群聊:["+groupname+"]("+strconv.FormatInt(groupid, 10)+")"+
-							"\nflag:"+es)})
+					notify(ctx, username, userid, "", "已自动同意在"+now+"收到来自"+
+						"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")的群聊邀请"+
+						"\n群聊:["+groupname+"]("+strconv.FormatInt(groupid, 10)+")"+
+						"\nflag:"+es)
 					return
 				}
-				ctx.SendPrivateForwardMessage(su,
-					message.Message{message.CustomNode(username, userid,
-						"在"+now+"收到来自"+
-							"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")的群聊邀请"+
-							"\n群聊:["+groupname+"]("+strconv.FormatInt(groupid, 10)+")"+
-							"\n请在下方复制flag并在前面加上:"+
-							"\n同意/拒绝邀请，来决定同意还是拒绝"),
-						message.CustomNode(username, userid, es)})
+				r := &request{
+					SelfID:   ctx.Event.SelfID,
+					Flag:     ctx.Event.Flag,
+					Kind:     kindinvite,
+					UserID:   userid,
+					UserName: username,
+					GrpID:    groupid,
+					GrpName:  groupname,
+					Time:     ctx.Event.Time,
+				}
+				q.setexpire(data.expire())
+				if err := q.add(r); err != nil {
+					logrus.Warnln("[event]保存请求失败:", err)
+				}
+				notify(ctx, username, userid, r.Flag,
+					"在"+now+"收到来自"+
+						"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")的群聊邀请"+
+						"\n群聊:["+groupname+"]("+strconv.FormatInt(groupid, 10)+")"+
+						"\n请求编号: "+strconv.FormatInt(r.ID, 10)+
+						"\n回复本消息 同意/拒绝, 或发送 同意/拒绝请求"+strconv.FormatInt(r.ID, 10)+
+						"\n也可以在下方复制flag并在前面加上:"+
+						"\n同意/拒绝邀请，来决定同意还是拒绝", es)
 			}
 		})
 	engine.On("request/friend").SetBlock(false).
@@ -67,14 +91,11 @@ func init() {
 			if ok {
 				su := zero.BotConfig.SuperUsers[0]
 				now := time.Unix(ctx.Event.Time, 0).Format("2006-01-02 15:04:05")
-				flag, err := strconv.ParseInt(ctx.Event.Flag, 10, 64)
+				es, err := encodeflag(ctx.Event.Flag)
 				if err != nil {
 					ctx.SendChain(message.Text("ERROR: ", err))
 					return
 				}
-				var buf [8]byte
-				binary.BigEndian.PutUint64(buf[:], uint64(flag))
-				es := base14.EncodeToString(buf[1:])
 				comment := ctx.Event.Comment
 				userid := ctx.Event.UserID
 				username := ctx.CardOrNickName(userid)
@@ -82,42 +103,144 @@ func init() {
 				logrus.Info("[event]收到来自[", username, "](", userid, ")的好友申请")
 				if data.isapplyon() || (!data.ismasteroff() && zero.SuperUserPermission(ctx)) {
 					ctx.SetFriendAddRequest(ctx.Event.Flag, true, "")
-					ctx.SendPrivateForwardMessage(su, message.Message{message.CustomNode(username, userid,
-						"已自动同意在"+now+"收到来自"+
-							"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")"+
-							"\n的好友请求:"+comment+
-							"\nflag:"+es)})
+					notify(ctx, username, userid, "", "已自动同意在"+now+"收到来自"+
+						"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")"+
+						"\n的好友请求:"+comment+
+						"\nflag:"+es)
 					return
 				}
-				ctx.SendPrivateForwardMessage(su,
-					message.Message{message.CustomNode(username, userid,
-						"在"+now+"收到来自"+
-							"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")"+
-							"\n的好友请求:"+comment+
-							"\n请在下方复制flag并在前面加上:"+
-							"\n同意/拒绝申请，来决定同意还是拒绝"),
-						message.CustomNode(username, userid, es)})
+				r := &request{
+					SelfID:   ctx.Event.SelfID,
+					Flag:     ctx.Event.Flag,
+					Kind:     kindapply,
+					UserID:   userid,
+					UserName: username,
+					Comment:  comment,
+					Time:     ctx.Event.Time,
+				}
+				q.setexpire(data.expire())
+				if err := q.add(r); err != nil {
+					logrus.Warnln("[event]保存请求失败:", err)
+				}
+				notify(ctx, username, userid, r.Flag,
+					"在"+now+"收到来自"+
+						"\n用户:["+username+"]("+strconv.FormatInt(userid, 10)+")"+
+						"\n的好友请求:"+comment+
+						"\n请求编号: "+strconv.FormatInt(r.ID, 10)+
+						"\n回复本消息 同意/拒绝, 或发送 同意/拒绝请求"+strconv.FormatInt(r.ID, 10)+
+						"\n也可以在下方复制flag并在前面加上:"+
+						"\n同意/拒绝申请，来决定同意还是拒绝", es)
 			}
 		})
 	engine.OnRegex(`^(同意|拒绝)(申请|邀请)\s*([一-踀]{4})\s*(.*)$`, zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
-			su := zero.BotConfig.SuperUsers[0]
 			cmd := ctx.State["regex_matched"].([]string)[1]
 			org := ctx.State["regex_matched"].([]string)[2]
 			es := ctx.State["regex_matched"].([]string)[3]
 			other := ctx.State["regex_matched"].([]string)[4]
-			var buf [8]byte
-			copy(buf[1:], base14.DecodeFromString(es))
-			flag := strconv.FormatInt(int64(binary.BigEndian.Uint64(buf[:])), 10)
+			flag := decodeflag(es)
 			ok := cmd == "同意"
 			switch org {
-			case "申请":
+			case kindapply:
 				ctx.SetFriendAddRequest(flag, ok, other)
-				ctx.SendPrivateMessage(su, message.Text("已", cmd, org))
-			case "邀请":
+			case kindinvite:
 				ctx.SetGroupAddRequest(flag, "invite", ok, other)
-				ctx.SendPrivateMessage(su, message.Text("已", cmd, org))
 			}
+			q.remove(flag)
+			ctx.SendChain(message.Text("已", cmd, org))
+		})
+	engine.OnFullMatch("待处理请求", zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			rs, err := pending(ctx)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if len(rs) == 0 {
+				ctx.SendChain(message.Text("没有待处理的请求~"))
+				return
+			}
+			msg := make([]string, 0, len(rs)+1)
+			msg = append(msg, "待处理请求(共"+strconv.Itoa(len(rs))+"条):")
+			for _, r := range rs {
+				msg = append(msg, r.String())
+			}
+			ctx.SendChain(message.Text(strings.Join(msg, "\n\n")))
+		})
+	engine.OnRegex(`^(同意|拒绝)请求\s*(\d+)\s*(.*)$`, zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			cmd := ctx.State["regex_matched"].([]string)[1]
+			id, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[2], 10, 64)
+			other := ctx.State["regex_matched"].([]string)[3]
+			syncexpire(ctx)
+			r, err := q.get(id)
+			if err != nil {
+				ctx.SendChain(message.Text("没有找到编号为", id, "的请求, 它可能已被处理或过期了"))
+				return
+			}
+			r.resolve(ctx, cmd == "同意", other)
+			ctx.SendChain(message.Text("已", cmd, "请求", id, ":\n", r.String()))
+		})
+	engine.OnRegex(`^\[CQ:reply,id=(-?\d+)\]\s*(同意|拒绝)\s*(.*)$`, zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			msgid, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[1], 10, 64)
+			cmd := ctx.State["regex_matched"].([]string)[2]
+			other := ctx.State["regex_matched"].([]string)[3]
+			syncexpire(ctx)
+			r, err := q.bymsg(msgid)
+			if err != nil {
+				ctx.SendChain(message.Text("这条消息没有对应的待处理请求, 它可能已被处理或过期了"))
+				return
+			}
+			r.resolve(ctx, cmd == "同意", other)
+			ctx.SendChain(message.Text("已", cmd, "请求", r.ID, ":\n", r.String()))
+		})
+	engine.OnRegex(`^(同意|拒绝)全部(申请|邀请|请求)$`, zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			cmd := ctx.State["regex_matched"].([]string)[1]
+			kind := ctx.State["regex_matched"].([]string)[2]
+			rs, err := pending(ctx)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			n := 0
+			for _, r := range rs {
+				if kind != "请求" && r.Kind != kind {
+					continue
+				}
+				r.resolve(ctx, cmd == "同意", "")
+				n++
+			}
+			if n == 0 {
+				ctx.SendChain(message.Text("没有待处理的", kind, "~"))
+				return
+			}
+			ctx.SendChain(message.Text("已", cmd, n, "条", kind))
+		})
+	engine.OnRegex(`^设置请求有效期\s*(\d+)\s*(小时|天)$`, zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			c := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+			su := zero.BotConfig.SuperUsers[0]
+			n, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[1], 10, 64)
+			unit := ctx.State["regex_matched"].([]string)[2]
+			hours := n
+			if unit == "天" {
+				hours *= 24
+			}
+			if hours <= 0 {
+				ctx.SendChain(message.Text("ERROR: 有效期至少为1小时"))
+				return
+			}
+			data := (storage)(c.GetData(-su))
+			data.setexpire(hours)
+			err := c.SetData(-su, int64(data))
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			q.setexpire(data.expire())
+			ctx.SendChain(message.Text("已设置请求有效期为", n, unit))
 		})
 	engine.OnRegex(`^(开启|关闭)自动同意(申请|邀请|主人)$`, zero.SuperUserPermission, zero.OnlyPrivate).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
@@ -142,3 +265,48 @@ func init() {
 			ctx.SendChain(message.Text("已设置自动同意" + from + "为" + option))
 		})
 }
+
+// encodeflag 将数字 flag 编码为便于复制的 base16384
+func encodeflag(flag string) (string, error) {
+	n, err := strconv.ParseInt(flag, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n))
+	return base14.EncodeToString(buf[1:]), nil
+}
+
+func decodeflag(es string) string {
+	var buf [8]byte
+	copy(buf[1:], base14.DecodeFromString(es))
+	return strconv.FormatInt(int64(binary.BigEndian.Uint64(buf[:])), 10)
+}
+
+// notify 将事件发送给所有主人, flag 非空时记录通知以便回复处理
+func notify(ctx *zero.Ctx, username string, userid int64, flag string, texts ...string) {
+	msg := make(message.Message, 0, len(texts))
+	for _, t := range texts {
+		msg = append(msg, message.CustomNode(username, userid, t))
+	}
+	for _, su := range zero.BotConfig.SuperUsers {
+		id := ctx.SendPrivateForwardMessage(su, msg).Get("message_id").Int()
+		if flag != "" {
+			q.bind(id, flag)
+		}
+	}
+}
+
+// pending 清理过期请求后列出待处理请求
+func pending(ctx *zero.Ctx) ([]*request, error) {
+	syncexpire(ctx)
+	return q.list()
+}
+
+// syncexpire 将设置的有效期同步到队列
+func syncexpire(ctx *zero.Ctx) {
+	if c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx]); ok {
+		data := (storage)(c.GetData(-zero.BotConfig.SuperUsers[0]))
+		q.setexpire(data.expire())
+	}
+}
@@ -0,0 +1,231 @@
+package event
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+	"github.com/sirupsen/logrus"
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+const (
+	kindapply  = "申请"
+	kindinvite = "邀请"
+)
+
+// request 待处理的好友申请或群聊邀请
+type request struct {
+	ID       int64  `db:"id"`      // ID 短编号, 递增且不会重复使用
+	SelfID   int64  `db:"sid"`     // SelfID 收到请求的 bot
+	Flag     string `db:"flag"`    // Flag 处理请求所需的 flag
+	Kind     string `db:"kind"`    // Kind 申请 | 邀请
+	UserID   int64  `db:"uid"`     // UserID 发起者
+	UserName string `db:"uname"`   // UserName 发起者昵称
+	GrpID    int64  `db:"gid"`     // GrpID 邀请加入的群
+	GrpName  string `db:"gname"`   // GrpName 邀请加入的群名
+	Comment  string `db:"comment"` // Comment 验证消息
+	Time     int64  `db:"time"`    // Time 收到请求的 unix 时间
+}
+
+// notice 发给主人的通知消息, 用于回复处理
+type notice struct {
+	MsgID int64  `db:"mid"`
+	Flag  string `db:"flag"`
+}
+
+// seq 已分配的最大编号
+type seq struct {
+	Name string `db:"name"`
+	N    int64  `db:"n"`
+}
+
+// queue 待处理请求队列
+type queue struct {
+	sync.Mutex
+	db     sql.Sqlite
+	expire time.Duration // expire 请求有效期
+	synced bool          // synced 是否已读取设置的有效期
+}
+
+var q = queue{expire: defaultexpire * time.Hour}
+
+func (q *queue) open(path string) error {
+	q.db = sql.New(path)
+	err := q.db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = q.db.Create("request", &request{})
+	if err != nil {
+		return err
+	}
+	err = q.db.Create("seq", &seq{})
+	if err != nil {
+		return err
+	}
+	return q.db.Create("notice", &notice{})
+}
+
+// setexpire 设置请求有效期
+func (q *queue) setexpire(expire time.Duration) {
+	q.Lock()
+	q.expire = expire
+	q.synced = true
+	q.Unlock()
+}
+
+// nextid 分配新的短编号, 已处理请求的编号不会被再次使用. 调用时需持有锁
+func (q *queue) nextid() (int64, error) {
+	s, err := sql.Find[seq](&q.db, "seq", "WHERE name = 'request'")
+	if err != nil {
+		// 旧数据没有记录, 从现有的最大编号继续
+		s = seq{Name: "request"}
+		if last, err := sql.Find[request](&q.db, "request", "ORDER BY id DESC"); err == nil {
+			s.N = last.ID
+		}
+	}
+	s.N++
+	return s.N, q.db.Insert("seq", &s)
+}
+
+// purge 删除已过期的请求, 返回被删除的请求. 调用时需持有锁
+func (q *queue) purge() []*request {
+	rs, err := sql.FindAll[request](&q.db, "request", "WHERE time < ?", time.Now().Add(-q.expire).Unix())
+	if err != nil {
+		return nil
+	}
+	for _, r := range rs {
+		logrus.Infoln("[event]请求", r.ID, "已过期:", r.Kind, r.UserName, "(", r.UserID, ")")
+		q.removelocked(r.Flag)
+	}
+	return rs
+}
+
+// clean 定时清理过期请求, 在读取到设置的有效期之前不清理
+func (q *queue) clean() {
+	for range time.Tick(10 * time.Minute) {
+		q.Lock()
+		if q.synced {
+			_ = q.purge()
+		}
+		q.Unlock()
+	}
+}
+
+// add 加入队列并分配短编号
+func (q *queue) add(r *request) error {
+	q.Lock()
+	defer q.Unlock()
+	_ = q.purge()
+	_ = q.db.Del("request", "WHERE flag = ?", r.Flag)
+	id, err := q.nextid()
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	return q.db.Insert("request", r)
+}
+
+// bind 记录通知消息对应的请求
+func (q *queue) bind(msgid int64, flag string) {
+	if msgid == 0 {
+		return
+	}
+	q.Lock()
+	_ = q.db.Insert("notice", &notice{MsgID: msgid, Flag: flag})
+	q.Unlock()
+}
+
+// list 清理过期请求后按编号列出
+func (q *queue) list() (rs []*request, err error) {
+	q.Lock()
+	defer q.Unlock()
+	_ = q.purge()
+	rs, err = sql.FindAll[request](&q.db, "request", "ORDER BY id ASC")
+	if err == sql.ErrNullResult {
+		err = nil
+	}
+	return
+}
+
+// get 按短编号获取, 不会返回已过期的请求
+func (q *queue) get(id int64) (*request, error) {
+	q.Lock()
+	defer q.Unlock()
+	_ = q.purge()
+	r, err := sql.Find[request](&q.db, "request", "WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// bymsg 按通知消息获取, 不会返回已过期的请求
+func (q *queue) bymsg(msgid int64) (*request, error) {
+	q.Lock()
+	defer q.Unlock()
+	_ = q.purge()
+	n, err := sql.Find[notice](&q.db, "notice", "WHERE mid = ?", msgid)
+	if err != nil {
+		return nil, err
+	}
+	r, err := sql.Find[request](&q.db, "request", "WHERE flag = ?", n.Flag)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// remove 请求处理完毕后移出队列
+func (q *queue) remove(flag string) {
+	q.Lock()
+	q.removelocked(flag)
+	q.Unlock()
+}
+
+func (q *queue) removelocked(flag string) {
+	_ = q.db.Del("request", "WHERE flag = ?", flag)
+	_ = q.db.Del("notice", "WHERE flag = ?", flag)
+}
+
+// resolve 同意或拒绝请求, 由收到请求的 bot 执行
+func (r *request) resolve(ctx *zero.Ctx, approve bool, reason string) {
+	if r.SelfID != 0 && r.SelfID != ctx.Event.SelfID {
+		if bot := zero.GetBot(r.SelfID); bot != nil {
+			ctx = bot
+		}
+	}
+	switch r.Kind {
+	case kindapply:
+		ctx.SetFriendAddRequest(r.Flag, approve, reason)
+	case kindinvite:
+		ctx.SetGroupAddRequest(r.Flag, "invite", approve, reason)
+	}
+	q.remove(r.Flag)
+}
+
+// String 请求的可读描述
+func (r *request) String() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	sb.WriteString(strconv.FormatInt(r.ID, 10))
+	sb.WriteString("] ")
+	if r.Kind == kindapply {
+		sb.WriteString("好友申请")
+	} else {
+		sb.WriteString("群聊邀请")
+	}
+	sb.WriteString(" ")
+	sb.WriteString(time.Unix(r.Time, 0).Format("01-02 15:04"))
+	sb.WriteString("\n用户:[" + r.UserName + "](" + strconv.FormatInt(r.UserID, 10) + ")")
+	if r.Kind == kindinvite {
+		sb.WriteString("\n群聊:[" + r.GrpName + "](" + strconv.FormatInt(r.GrpID, 10) + ")")
+	}
+	if r.Comment != "" {
+		sb.WriteString("\n验证消息:" + r.Comment)
+	}
+	return sb.String()
+}
@@ -0,0 +1,197 @@
+package fortune
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg/factory"
+)
+
+const (
+	// 各群自定义底图包位置, 每个底图包为 custom/群号/包名/ 下的图片与可选的 text.json
+	customdir = images + "custom/"
+	// 群与个人的底图选择
+	settingjson = customdir + "setting.json"
+	// 自定义底图包的签文
+	customtext = "text.json"
+	// 自定义底图统一缩放到内置底图的尺寸
+	backsize = 480
+)
+
+// setting 群选择的自定义底图包与个人偏好的底图包
+type setting struct {
+	sync.Mutex `json:"-"`
+	Group      map[string]string `json:"group"`
+	User       map[string]string `json:"user"`
+}
+
+var settings = setting{Group: make(map[string]string), User: make(map[string]string)}
+
+func (s *setting) load() error {
+	s.Lock()
+	defer s.Unlock()
+	data, err := os.ReadFile(settingjson)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
+
+// save 调用时需持有锁
+func (s *setting) save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(settingjson, data, 0644)
+}
+
+func (s *setting) get(m map[string]string, id int64) string {
+	s.Lock()
+	defer s.Unlock()
+	return m[strconv.FormatInt(id, 10)]
+}
+
+// set kind 为空时删除
+func (s *setting) set(m map[string]string, id int64, kind string) error {
+	s.Lock()
+	defer s.Unlock()
+	if kind == "" {
+		delete(m, strconv.FormatInt(id, 10))
+	} else {
+		m[strconv.FormatInt(id, 10)] = kind
+	}
+	return s.save()
+}
+
+func groupdir(gid int64) string {
+	return customdir + strconv.FormatInt(gid, 10) + "/"
+}
+
+// checkname 底图包名不能与内置的重复, 也不能含有路径
+func checkname(name string) error {
+	switch {
+	case name == "":
+		return errors.New("底图名不能为空")
+	case strings.ContainsAny(name, `/\.`):
+		return errors.New("底图名不能含有 / \\ .")
+	}
+	if _, ok := index[name]; ok {
+		return errors.New("不能与内置底图重名")
+	}
+	return nil
+}
+
+// custompacks 群内的自定义底图包
+func custompacks(gid int64) []string {
+	entries, err := os.ReadDir(groupdir(gid))
+	if err != nil {
+		return nil
+	}
+	packs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			packs = append(packs, e.Name())
+		}
+	}
+	return packs
+}
+
+func hascustom(gid int64, name string) bool {
+	return name != "" && slices.Contains(custompacks(gid), name)
+}
+
+// customimages 自定义底图包中的图片, 按文件名排序
+func customimages(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	imgs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && e.Name() != customtext {
+			imgs = append(imgs, dir+e.Name())
+		}
+	}
+	return imgs
+}
+
+// addimage 保存一张底图到自定义底图包, 返回包内的图片数
+func addimage(gid int64, name string, img image.Image) (int, error) {
+	dir := groupdir(gid) + name + "/"
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return 0, err
+	}
+	n := len(customimages(dir))
+	f, err := os.Create(dir + strconv.Itoa(n) + ".png")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return n + 1, png.Encode(f, factory.Size(img, backsize, backsize).Image())
+}
+
+// settext 保存自定义底图包的签文
+func settext(gid int64, name string, data []byte) (int, error) {
+	var texts []map[string]string
+	err := json.Unmarshal(data, &texts)
+	if err != nil {
+		return 0, err
+	}
+	if len(texts) == 0 {
+		return 0, errors.New("签文不能为空")
+	}
+	for i, t := range texts {
+		if t["title"] == "" || t["content"] == "" {
+			return 0, errors.New("第" + strconv.Itoa(i+1) + "条签文缺少 title 或 content")
+		}
+	}
+	dir := groupdir(gid) + name + "/"
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return 0, err
+	}
+	data, err = json.Marshal(texts)
+	if err != nil {
+		return 0, err
+	}
+	return len(texts), os.WriteFile(dir+customtext, data, 0644)
+}
+
+// customtexts 读取自定义底图包的签文, 没有时返回 nil
+func customtexts(dir string) ([]map[string]string, error) {
+	if file.IsNotExist(dir + customtext) {
+		return nil, nil
+	}
+	data, err := os.ReadFile(dir + customtext)
+	if err != nil {
+		return nil, err
+	}
+	var texts []map[string]string
+	return texts, json.Unmarshal(data, &texts)
+}
+
+// delpack 删除自定义底图包, 并清除指向它的群设置
+func delpack(gid int64, name string) error {
+	err := os.RemoveAll(filepath.Clean(groupdir(gid) + name))
+	if err != nil {
+		return err
+	}
+	if settings.get(settings.Group, gid) == name {
+		return settings.set(settings.Group, gid, "")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package fortune
 
 import (
 	"archive/zip"
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -10,6 +11,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/FloatTech/gg" // 注册了 jpg png gif
 	"github.com/FloatTech/gg/factory"
@@ -21,6 +23,7 @@ import (
 	fcext "github.com/FloatTech/floatbox/ctxext"
 	"github.com/FloatTech/floatbox/file"
 	"github.com/FloatTech/floatbox/math"
+	"github.com/FloatTech/floatbox/web"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
@@ -52,7 +55,12 @@ func init() {
 		DisableOnDefault: false,
 		Brief:            "每日运势",
 		Help: "- 运势 | 抽签\n" +
-			"- 设置底图[车万 | DC4 | 爱因斯坦 | 星空列车 | 樱云之恋 | 富婆妹 | 李清歌 | 公主连结 | 原神 | 明日方舟 | 碧蓝航线 | 碧蓝幻想 | 战双 | 阴阳师 | 赛马娘 | 东方归言录 | 奇异恩典 | 夏日口袋 | ASoul | Hololive]",
+			"- 设置底图[车万 | DC4 | 爱因斯坦 | 星空列车 | 樱云之恋 | 富婆妹 | 李清歌 | 公主连结 | 原神 | 明日方舟 | 碧蓝航线 | 碧蓝幻想 | 战双 | 阴阳师 | 赛马娘 | 东方归言录 | 奇异恩典 | 夏日口袋 | ASoul | Hololive | 自定义底图名]\n" +
+			"- 设置底图 (列出内置与本群自定义底图)\n" +
+			"- 设置我的底图[底图名] (不填则清除, 个人偏好优先于群设置)\n" +
+			"- [群管]上传底图[底图名][图片] (可一次多张, 统一缩放为480x480)\n" +
+			"- [群管]上传签文[底图名] [{\"title\":\"大吉\",\"content\":\"签文\"},...]\n" +
+			"- [群管]删除底图[底图名]",
 		PublicDataFolder: "Fortune",
 	})
 	_ = os.RemoveAll(cache)
@@ -60,9 +68,17 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	err = os.MkdirAll(customdir, 0755)
+	if err != nil {
+		panic(err)
+	}
 	for i, s := range table {
 		index[s] = uint8(i)
 	}
+	err = settings.load()
+	if err != nil {
+		panic(err)
+	}
 	en.OnRegex(`^设置底图\s?(.*)`).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
 			gid := ctx.Event.GroupID
@@ -70,7 +86,21 @@ func init() {
 				// 个人用户设为负数
 				gid = -ctx.Event.UserID
 			}
-			i, ok := index[ctx.State["regex_matched"].([]string)[1]]
+			kind := strings.TrimSpace(ctx.State["regex_matched"].([]string)[1])
+			if kind == "" {
+				ctx.SendChain(message.Text(packlist(gid)))
+				return
+			}
+			if hascustom(gid, kind) {
+				err := settings.set(settings.Group, gid, kind)
+				if err != nil {
+					ctx.SendChain(message.Text("设置失败:", err))
+					return
+				}
+				ctx.SendChain(message.Text("设置成功~"))
+				return
+			}
+			i, ok := index[kind]
 			if ok {
 				c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
 				if ok {
@@ -79,6 +109,11 @@ func init() {
 						ctx.SendChain(message.Text("设置失败:", err))
 						return
 					}
+					err = settings.set(settings.Group, gid, "")
+					if err != nil {
+						ctx.SendChain(message.Text("设置失败:", err))
+						return
+					}
 					ctx.SendChain(message.Text("设置成功~"))
 					return
 				}
@@ -87,6 +122,99 @@ func init() {
 			}
 			ctx.SendChain(message.Text("没有这个底图哦～"))
 		})
+	en.OnRegex(`^设置我的底图\s?(.*)`).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			if gid <= 0 {
+				gid = -ctx.Event.UserID
+			}
+			kind := strings.TrimSpace(ctx.State["regex_matched"].([]string)[1])
+			if kind != "" && !hascustom(gid, kind) {
+				if _, ok := index[kind]; !ok {
+					ctx.SendChain(message.Text("没有这个底图哦～"))
+					return
+				}
+			}
+			err := settings.set(settings.User, ctx.Event.UserID, kind)
+			if err != nil {
+				ctx.SendChain(message.Text("设置失败:", err))
+				return
+			}
+			if kind == "" {
+				ctx.SendChain(message.Text("已清除你的底图偏好~"))
+				return
+			}
+			ctx.SendChain(message.Text("设置成功~"))
+		})
+	en.OnRegex(`^上传底图\s?(.*)`, zero.UserOrGrpAdmin, zero.MustProvidePicture).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			if gid <= 0 {
+				gid = -ctx.Event.UserID
+			}
+			kind := strings.TrimSpace(ctx.State["regex_matched"].([]string)[1])
+			err := checkname(kind)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			n := 0
+			for _, url := range ctx.State["image_url"].([]string) {
+				data, err := web.GetData(url)
+				if err != nil {
+					ctx.SendChain(message.Text("ERROR: ", err))
+					return
+				}
+				img, _, err := image.Decode(bytes.NewReader(data))
+				if err != nil {
+					ctx.SendChain(message.Text("ERROR: ", err))
+					return
+				}
+				n, err = addimage(gid, kind, img)
+				if err != nil {
+					ctx.SendChain(message.Text("ERROR: ", err))
+					return
+				}
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("成功! 底图[", kind, "]现有", n, "张图片"))
+		})
+	en.OnRegex(`^上传签文\s?(\S+)\s+([\s\S]+)$`, zero.UserOrGrpAdmin).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			if gid <= 0 {
+				gid = -ctx.Event.UserID
+			}
+			regex := ctx.State["regex_matched"].([]string)
+			err := checkname(regex[1])
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			n, err := settext(gid, regex[1], helper.StringToBytes(message.UnescapeCQText(regex[2])))
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("成功! 底图[", regex[1], "]现有", n, "条签文"))
+		})
+	en.OnRegex(`^删除底图\s?(.*)`, zero.UserOrGrpAdmin).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			if gid <= 0 {
+				gid = -ctx.Event.UserID
+			}
+			kind := strings.TrimSpace(ctx.State["regex_matched"].([]string)[1])
+			if !hascustom(gid, kind) {
+				ctx.SendChain(message.Text("没有这个自定义底图哦～"))
+				return
+			}
+			err := delpack(gid, kind)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("已删除底图[", kind, "]"))
+		})
 	en.OnFullMatchGroup([]string{"运势", "抽签"}, fcext.DoOnceOnSuccess(
 		func(ctx *zero.Ctx) bool {
 			data, err := file.GetLazyData(omikujson, control.Md5File, false)
@@ -125,25 +253,59 @@ func init() {
 					kind = table[v]
 				}
 			}
-			// 检查背景图片是否存在
-			zipfile := images + kind + ".zip"
-			_, err := file.GetLazyData(zipfile, control.Md5File, false)
-			if err != nil {
-				ctx.SendChain(message.Text("ERROR: ", err))
-				return
+			// 个人偏好优先于群设置, 自定义底图优先于内置底图
+			custom := settings.get(settings.Group, gid)
+			if fav := settings.get(settings.User, ctx.Event.UserID); fav != "" {
+				if _, ok := index[fav]; ok {
+					kind, custom = fav, ""
+				} else if hascustom(gid, fav) {
+					custom = fav
+				}
+			}
+			texts := omikujis
+			var imgs []string
+			if hascustom(gid, custom) {
+				dir := groupdir(gid) + custom + "/"
+				imgs = customimages(dir)
+				t, err := customtexts(dir)
+				if err != nil {
+					ctx.SendChain(message.Text("ERROR: ", err))
+					return
+				}
+				if len(t) > 0 {
+					texts = t
+				}
 			}
 
-			// 随机获取背景
-			background, index, err := randimage(zipfile, ctx)
+			var (
+				background image.Image
+				source     string
+				index      int
+				err        error
+			)
+			if len(imgs) > 0 {
+				// 随机获取自定义背景
+				background, source, err = randcustom(imgs, ctx)
+			} else {
+				// 检查背景图片是否存在
+				source = images + kind + ".zip"
+				_, err = file.GetLazyData(source, control.Md5File, false)
+				if err != nil {
+					ctx.SendChain(message.Text("ERROR: ", err))
+					return
+				}
+				// 随机获取背景
+				background, index, err = randimage(source, ctx)
+			}
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
 				return
 			}
 
 			// 随机获取签文
-			randtextindex := fcext.RandSenderPerDayN(ctx.Event.UserID, len(omikujis))
-			title, text := omikujis[randtextindex]["title"], omikujis[randtextindex]["content"]
-			digest := md5.Sum(helper.StringToBytes(zipfile + strconv.Itoa(index) + title + text))
+			randtextindex := fcext.RandSenderPerDayN(ctx.Event.UserID, len(texts))
+			title, text := texts[randtextindex]["title"], texts[randtextindex]["content"]
+			digest := md5.Sum(helper.StringToBytes(source + strconv.Itoa(index) + title + text))
 			cachefile := cache + hex.EncodeToString(digest[:])
 
 			err = pool.SendImageFromPool(cachefile, func(cachefile string) error {
@@ -162,6 +324,41 @@ func init() {
 		})
 }
 
+// packlist 列出内置与本群自定义的底图
+func packlist(gid int64) string {
+	var sb strings.Builder
+	sb.WriteString("内置底图:\n")
+	sb.WriteString(strings.Join(table[:], " | "))
+	packs := custompacks(gid)
+	if len(packs) > 0 {
+		sb.WriteString("\n自定义底图:\n")
+		sb.WriteString(strings.Join(packs, " | "))
+	}
+	if kind := settings.get(settings.Group, gid); hascustom(gid, kind) {
+		sb.WriteString("\n当前使用: ")
+		sb.WriteString(kind)
+	}
+	return sb.String()
+}
+
+// randcustom 随机选取自定义底图包内的图片, 返回图片与用于缓存的标识
+func randcustom(imgs []string, ctx *zero.Ctx) (im image.Image, source string, err error) {
+	source = imgs[fcext.RandSenderPerDayN(ctx.Event.UserID, len(imgs))]
+	info, err := os.Stat(source)
+	if err != nil {
+		return
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	im, _, err = image.Decode(f)
+	// 同名底图包被删除重建后不使用旧缓存
+	source += strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	return
+}
+
 // @function randimage 随机选取zip内的文件
 // @param path zip路径
 // @param ctx *zero.Ctx
@@ -0,0 +1,286 @@
+// Package gamerank 群小游戏排行与月度赛季
+package gamerank
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FloatTech/AnimeAPI/wallet"
+	ctrl "github.com/FloatTech/zbpctrl"
+	"github.com/FloatTech/zbputils/control"
+	"github.com/fumiama/cron"
+	"github.com/sirupsen/logrus"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/gamerank/stats"
+)
+
+// minseasondays 手动结束赛季前赛季至少进行的天数
+const minseasondays = 7
+
+func init() {
+	en := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
+		DisableOnDefault: false,
+		Brief:            "小游戏排行",
+		Help: "记录猜歌、猜成语、猜单词的战绩, 每月一个赛季\n" +
+			"- 游戏排行 [猜歌|猜成语|猜单词] [第N赛季]\n" +
+			"- 我的游戏战绩\n" +
+			"- 游戏赛季列表\n" +
+			"- 结束本赛季 (管理员提前结算并开启新赛季, 赛季需已进行" + strconv.Itoa(minseasondays) + "天, 提前结算不发放奖励)\n" +
+			"- 设置赛季奖励 100 50 20 (赛季结算时各游戏前三名获得的钱包奖励)\n" +
+			"Tips: 按胜场排名, 胜场相同时平均猜测次数少者在前",
+		PrivateDataFolder: "gamerank",
+	})
+	err := stats.Open(en.DataFolder() + "stats.db")
+	if err != nil {
+		panic(err)
+	}
+	c := cron.New()
+	_, err = c.AddFunc("5 * * * *", settleexpired)
+	if err != nil {
+		panic(err)
+	}
+	c.Start()
+
+	en.OnRegex(`^游戏排行\s*(猜歌|猜成语|猜单词)?\s*(?:第(\d+)赛季)?$`, zero.OnlyGroup).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		args := ctx.State["regex_matched"].([]string)
+		gid := ctx.Event.GroupID
+		s, err := season(gid, args[2])
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		games := stats.Games[:]
+		n := 5
+		if args[1] != "" {
+			games = []string{args[1]}
+			n = 10
+		}
+		var sb strings.Builder
+		sb.WriteString("第")
+		sb.WriteString(strconv.FormatInt(s.No, 10))
+		sb.WriteString("赛季 ")
+		sb.WriteString(period(s))
+		for _, game := range games {
+			top, err := stats.Top(gid, s.No, game, n)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			sb.WriteString("\n【")
+			sb.WriteString(game)
+			sb.WriteString("】")
+			if len(top) == 0 {
+				sb.WriteString("\n暂无获胜记录")
+				continue
+			}
+			for i, st := range top {
+				sb.WriteString("\n")
+				sb.WriteString(strconv.Itoa(i + 1))
+				sb.WriteString(". ")
+				sb.WriteString(nameof(ctx, gid, st.UserID))
+				sb.WriteString(" ")
+				sb.WriteString(brief(st))
+			}
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+	en.OnFullMatch("我的游戏战绩", zero.OnlyGroup).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		gid := ctx.Event.GroupID
+		s, err := stats.Current(gid)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		sts, err := stats.Of(gid, s.No, ctx.Event.UserID)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(sts) == 0 {
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("本赛季还没有玩过小游戏哦"))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("第")
+		sb.WriteString(strconv.FormatInt(s.No, 10))
+		sb.WriteString("赛季战绩")
+		for _, st := range sts {
+			sb.WriteString("\n【")
+			sb.WriteString(st.Game)
+			sb.WriteString("】参与")
+			sb.WriteString(strconv.FormatInt(st.Played, 10))
+			sb.WriteString("局 ")
+			sb.WriteString(brief(st))
+			sb.WriteString(" 当前连胜")
+			sb.WriteString(strconv.FormatInt(st.Streak, 10))
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(sb.String()))
+	})
+	en.OnFullMatch("游戏赛季列表", zero.OnlyGroup).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		_, err := stats.Current(ctx.Event.GroupID)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ss, err := stats.Seasons(ctx.Event.GroupID)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("本群赛季:")
+		for _, s := range ss {
+			sb.WriteString("\n第")
+			sb.WriteString(strconv.FormatInt(s.No, 10))
+			sb.WriteString("赛季 ")
+			sb.WriteString(period(s))
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+	en.OnFullMatch("结束本赛季", zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		cur, err := stats.Current(ctx.Event.GroupID)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if time.Since(time.Unix(cur.Start, 0)) < minseasondays*24*time.Hour {
+			ctx.SendChain(message.Text("本赛季开始还不到", minseasondays, "天, 不能结束哦"))
+			return
+		}
+		// 提前结算不发放奖励, 以免反复结算刷钱
+		msg, err := settle(ctx, ctx.Event.GroupID, false)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text(msg))
+	})
+	en.OnRegex(`^设置赛季奖励\s*(\d+)\s+(\d+)\s+(\d+)$`, zero.OnlyGroup, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		args := ctx.State["regex_matched"].([]string)
+		r := stats.Reward{GrpID: ctx.Event.GroupID}
+		r.First, _ = strconv.Atoi(args[1])
+		r.Second, _ = strconv.Atoi(args[2])
+		r.Third, _ = strconv.Atoi(args[3])
+		err := stats.SetReward(&r)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("赛季结算时各游戏前三名将分别获得", r.First, "、", r.Second, "、", r.Third, wallet.GetWalletName()))
+	})
+}
+
+// season 解析赛季序号, 留空为当前赛季
+func season(gid int64, no string) (*stats.Season, error) {
+	if no == "" {
+		return stats.Current(gid)
+	}
+	n, _ := strconv.ParseInt(no, 10, 64)
+	return stats.SeasonOf(gid, n)
+}
+
+func period(s *stats.Season) string {
+	p := time.Unix(s.Start, 0).Format("2006.01.02") + "~"
+	if s.Stop != 0 {
+		p += time.Unix(s.Stop, 0).Format("2006.01.02")
+	}
+	return p
+}
+
+func brief(st *stats.Stat) string {
+	return "胜" + strconv.FormatInt(st.Wins, 10) +
+		" 平均" + strconv.FormatFloat(st.Avg(), 'f', 1, 64) + "次猜中" +
+		" 最高连胜" + strconv.FormatInt(st.Best, 10)
+}
+
+// nameof 群名片或昵称
+func nameof(ctx *zero.Ctx, gid, uid int64) string {
+	info := ctx.GetGroupMemberInfo(gid, uid, false)
+	if name := info.Get("card").String(); name != "" {
+		return name
+	}
+	if name := info.Get("nickname").String(); name != "" {
+		return name
+	}
+	return strconv.FormatInt(uid, 10)
+}
+
+// settle 结算群的当前赛季, reward 时发放奖励, 返回结算公告
+func settle(ctx *zero.Ctx, gid int64, reward bool) (string, error) {
+	s, err := stats.Settle(gid)
+	if err != nil {
+		return "", err
+	}
+	r := stats.GetReward(gid)
+	prizes := [...]int{r.First, r.Second, r.Third}
+	var sb strings.Builder
+	sb.WriteString("第")
+	sb.WriteString(strconv.FormatInt(s.No, 10))
+	sb.WriteString("赛季已结束! ")
+	sb.WriteString(period(s))
+	for _, game := range stats.Games {
+		top, err := stats.Top(gid, s.No, game, len(prizes))
+		if err != nil {
+			return "", err
+		}
+		if len(top) == 0 {
+			continue
+		}
+		sb.WriteString("\n【")
+		sb.WriteString(game)
+		sb.WriteString("】")
+		for i, st := range top {
+			sb.WriteString("\n")
+			sb.WriteString(strconv.Itoa(i + 1))
+			sb.WriteString(". ")
+			sb.WriteString(nameof(ctx, gid, st.UserID))
+			sb.WriteString(" 胜")
+			sb.WriteString(strconv.FormatInt(st.Wins, 10))
+			if !reward || prizes[i] <= 0 {
+				continue
+			}
+			err = wallet.InsertWalletOf(st.UserID, prizes[i])
+			if err != nil {
+				logrus.Warnln("[gamerank] 发放赛季奖励失败:", err)
+				continue
+			}
+			sb.WriteString(" 奖励")
+			sb.WriteString(strconv.Itoa(prizes[i]))
+			sb.WriteString(wallet.GetWalletName())
+		}
+	}
+	sb.WriteString("\n新赛季开始啦!")
+	return sb.String(), nil
+}
+
+// settleexpired 结算已跨月的赛季
+func settleexpired() {
+	ss, err := stats.Active()
+	if err != nil {
+		logrus.Warnln("[gamerank] 获取赛季失败:", err)
+		return
+	}
+	now := time.Now()
+	for _, s := range ss {
+		if !s.Expired(now) {
+			continue
+		}
+		var ctx *zero.Ctx
+		zero.RangeBot(func(_ int64, c *zero.Ctx) bool {
+			ctx = c
+			return false
+		})
+		if ctx == nil {
+			return
+		}
+		msg, err := settle(ctx, s.GrpID, true)
+		if err != nil {
+			logrus.Warnln("[gamerank] 结算群", s.GrpID, "的赛季失败:", err)
+			continue
+		}
+		ctx.SendGroupMessage(s.GrpID, message.Text(msg))
+	}
+}
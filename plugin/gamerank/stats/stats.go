@@ -0,0 +1,313 @@
+// Package stats 群小游戏的战绩与月度赛季
+package stats
+
+import (
+	"errors"
+	"hash/crc64"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FloatTech/floatbox/binary"
+	sql "github.com/FloatTech/sqlite"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	stattable   = "stat"
+	seasontable = "season"
+	rewardtable = "reward"
+)
+
+// 支持的游戏
+const (
+	GuessMusic = "猜歌"
+	Handou     = "猜成语"
+	Wordle     = "猜单词"
+)
+
+// Games 全部游戏
+var Games = [...]string{GuessMusic, Handou, Wordle}
+
+// Stat 某群某赛季某人在某游戏中的战绩
+type Stat struct {
+	ID      int64  `db:"id"`      // ID gid_season_game_uid 的 crc64
+	GrpID   int64  `db:"gid"`     // GrpID 群号
+	Season  int64  `db:"season"`  // Season 赛季序号
+	Game    string `db:"game"`    // Game 游戏名
+	UserID  int64  `db:"uid"`     // UserID 玩家
+	Played  int64  `db:"played"`  // Played 参与局数
+	Wins    int64  `db:"wins"`    // Wins 获胜局数
+	Guesses int64  `db:"guesses"` // Guesses 获胜局中本人的猜测次数
+	Streak  int64  `db:"streak"`  // Streak 当前连胜
+	Best    int64  `db:"best"`    // Best 最高连胜
+}
+
+// Avg 获胜局的平均猜测次数
+func (s *Stat) Avg() float64 {
+	if s.Wins == 0 {
+		return 0
+	}
+	return float64(s.Guesses) / float64(s.Wins)
+}
+
+// Season 群的赛季, 每月一个
+type Season struct {
+	ID    int64 `db:"id"`    // ID gid*10000+no
+	GrpID int64 `db:"gid"`   // GrpID 群号
+	No    int64 `db:"no"`    // No 第几赛季
+	Start int64 `db:"start"` // Start 开始的 unix 时间
+	Stop  int64 `db:"stop"`  // Stop 结束的 unix 时间, 0 为进行中
+}
+
+// Expired 赛季是否已跨月
+func (s *Season) Expired(now time.Time) bool {
+	st := time.Unix(s.Start, 0)
+	return st.Year() != now.Year() || st.Month() != now.Month()
+}
+
+// Reward 赛季结算时各游戏前三名的钱包奖励
+type Reward struct {
+	GrpID  int64 `db:"gid"`
+	First  int   `db:"first"`
+	Second int   `db:"second"`
+	Third  int   `db:"third"`
+}
+
+var (
+	mu     sync.RWMutex
+	db     sql.Sqlite
+	crctab = crc64.MakeTable(crc64.ISO)
+	opened bool
+	// ErrNoSuchSeason 没有这个赛季
+	ErrNoSuchSeason = errors.New("没有这个赛季")
+)
+
+// Open 打开战绩数据库, 由 gamerank 在初始化时调用
+func Open(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	db = sql.New(path)
+	err := db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = db.Create(stattable, &Stat{})
+	if err != nil {
+		return err
+	}
+	err = db.Create(seasontable, &Season{})
+	if err != nil {
+		return err
+	}
+	err = db.Create(rewardtable, &Reward{})
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_stat_board ON " + stattable + " (gid, season, game);")
+	if err != nil {
+		return err
+	}
+	opened = true
+	return nil
+}
+
+func statid(gid, season int64, game string, uid int64) int64 {
+	b := binary.NewWriterF(func(w *binary.Writer) {
+		w.WriteString(strconv.FormatInt(gid, 10))
+		w.WriteByte('_')
+		w.WriteString(strconv.FormatInt(season, 10))
+		w.WriteByte('_')
+		w.WriteString(game)
+		w.WriteByte('_')
+		w.WriteString(strconv.FormatInt(uid, 10))
+	})
+	return int64(crc64.Checksum(b, crctab))
+}
+
+// current 进行中的赛季, 没有则开启第一个赛季, 需持有写锁
+func current(gid int64) (*Season, error) {
+	s, err := sql.Find[Season](&db, seasontable, "WHERE gid = ? AND stop = 0", gid)
+	if err == nil {
+		return &s, nil
+	}
+	if err != sql.ErrNullResult {
+		return nil, err
+	}
+	s = Season{ID: gid*10000 + 1, GrpID: gid, No: 1, Start: time.Now().Unix()}
+	return &s, db.Insert(seasontable, &s)
+}
+
+// Current 群当前的赛季
+func Current(gid int64) (*Season, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return current(gid)
+}
+
+// Seasons 群的全部赛季, 按序号排列
+func Seasons(gid int64) ([]*Season, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sql.FindAll[Season](&db, seasontable, "WHERE gid = ? ORDER BY no ASC", gid)
+}
+
+// SeasonOf 群的第 no 个赛季
+func SeasonOf(gid, no int64) (*Season, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, err := sql.Find[Season](&db, seasontable, "WHERE gid = ? AND no = ?", gid, no)
+	if err == sql.ErrNullResult {
+		return nil, ErrNoSuchSeason
+	}
+	return &s, err
+}
+
+// Active 有进行中赛季的群
+func Active() ([]*Season, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !opened {
+		return nil, nil
+	}
+	ss, err := sql.FindAll[Season](&db, seasontable, "WHERE stop = 0")
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return ss, err
+}
+
+// Settle 结束群当前的赛季并开启下一个, 返回结束的赛季
+func Settle(gid int64) (*Season, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, err := current(gid)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	s.Stop = now
+	err = db.Insert(seasontable, s)
+	if err != nil {
+		return nil, err
+	}
+	next := &Season{ID: gid*10000 + s.No + 1, GrpID: gid, No: s.No + 1, Start: now}
+	return s, db.Insert(seasontable, next)
+}
+
+// Round 一局游戏中各玩家的猜测次数
+type Round struct {
+	game    string
+	gid     int64
+	mu      sync.Mutex
+	guesses map[int64]int64
+}
+
+// NewRound 开始记录一局游戏
+func NewRound(game string, gid int64) *Round {
+	return &Round{game: game, gid: gid, guesses: make(map[int64]int64, 4)}
+}
+
+// Guess 记录一次猜测
+func (r *Round) Guess(uid int64) {
+	r.mu.Lock()
+	r.guesses[uid]++
+	r.mu.Unlock()
+}
+
+// End 结束本局并记录战绩, 出错时只写日志. winner 为 0 表示无人猜中
+func (r *Round) End(winner int64) {
+	if err := r.Finish(winner); err != nil {
+		logrus.Warnln("[gamerank] 记录", r.game, "战绩失败:", err)
+	}
+}
+
+// Finish 结束本局, winner 为 0 表示无人猜中. 团队模式下胜者只计本人的猜测次数, 私聊的游戏不记录
+func (r *Round) Finish(winner int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if winner != 0 {
+		if _, ok := r.guesses[winner]; !ok {
+			r.guesses[winner] = 1
+		}
+	}
+	// 私聊中的游戏不计入排行
+	if len(r.guesses) == 0 || r.gid == 0 {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return nil
+	}
+	s, err := current(r.gid)
+	if err != nil {
+		return err
+	}
+	for uid := range r.guesses {
+		id := statid(r.gid, s.No, r.game, uid)
+		st, err := sql.Find[Stat](&db, stattable, "WHERE id = ?", id)
+		if err == sql.ErrNullResult {
+			st, err = Stat{ID: id, GrpID: r.gid, Season: s.No, Game: r.game, UserID: uid}, nil
+		}
+		if err != nil {
+			return err
+		}
+		st.Played++
+		if uid == winner {
+			st.Wins++
+			st.Guesses += r.guesses[uid]
+			st.Streak++
+			st.Best = max(st.Best, st.Streak)
+		} else {
+			st.Streak = 0
+		}
+		err = db.Insert(stattable, &st)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Top 某赛季某游戏的排行, 按胜场多、平均猜测次数少排列
+func Top(gid, season int64, game string, n int) ([]*Stat, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	sts, err := sql.FindAll[Stat](&db, stattable,
+		"WHERE gid = ? AND season = ? AND game = ? AND wins > 0 ORDER BY wins DESC, CAST(guesses AS REAL) / wins ASC, best DESC LIMIT ?",
+		gid, season, game, n)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return sts, err
+}
+
+// Of 某人在某赛季各游戏的战绩
+func Of(gid, season, uid int64) ([]*Stat, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	sts, err := sql.FindAll[Stat](&db, stattable, "WHERE gid = ? AND season = ? AND uid = ?", gid, season, uid)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return sts, err
+}
+
+// GetReward 群的赛季奖励
+func GetReward(gid int64) Reward {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, err := sql.Find[Reward](&db, rewardtable, "WHERE gid = ?", gid)
+	if err != nil {
+		return Reward{GrpID: gid}
+	}
+	return r
+}
+
+// SetReward 设置群的赛季奖励
+func SetReward(r *Reward) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return db.Insert(rewardtable, r)
+}
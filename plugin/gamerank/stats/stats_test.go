@@ -0,0 +1,52 @@
+package stats
+
+import "testing"
+
+func TestRound(t *testing.T) {
+	err := Open(t.TempDir() + "/stats.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		r := NewRound(Wordle, 1)
+		r.Guess(2)
+		r.Guess(3)
+		r.Guess(2)
+		err = r.Finish(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewRound(Wordle, 1)
+	r.Guess(2)
+	r.Guess(3)
+	err = r.Finish(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := Top(1, 1, Wordle, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].UserID != 2 || top[0].Wins != 2 || top[0].Avg() != 2 || top[0].Best != 2 || top[0].Streak != 0 {
+		t.Fatalf("unexpected top %+v", top)
+	}
+	if top[1].UserID != 3 || top[1].Played != 3 || top[1].Streak != 1 {
+		t.Fatalf("unexpected second %+v", top[1])
+	}
+	old, err := Settle(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := Current(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.No != 1 || old.Stop == 0 || cur.No != 2 || cur.Stop != 0 {
+		t.Fatal("unexpected seasons", old, cur)
+	}
+	top, err = Top(1, 2, Wordle, 10)
+	if err != nil || len(top) != 0 {
+		t.Fatal("new season should be empty", top, err)
+	}
+}
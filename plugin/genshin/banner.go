@@ -0,0 +1,141 @@
+package genshin
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"slices"
+	"time"
+)
+
+// banner 卡池, 可在 Genshin.zip 或数据目录的 banners.json 中配置限定卡池
+type banner struct {
+	Name  string   `json:"名称"`
+	Kind  string   `json:"类型"`           // 角色 武器
+	Five  []string `json:"五星"`           // UP五星
+	Four  []string `json:"四星"`           // UP四星
+	Start string   `json:"开始,omitempty"` // 2006-01-02, 为空则不限
+	Stop  string   `json:"结束,omitempty"` // 2006-01-02, 当天包含在内
+}
+
+// banners 第 0 个为常驻卡池
+var banners = []*banner{{Name: "常驻", Kind: kindnames[kindStandard]}}
+
+func (b *banner) kind() int {
+	switch b.Kind {
+	case kindnames[kindCharacter]:
+		return kindCharacter
+	case kindnames[kindWeapon]:
+		return kindWeapon
+	default:
+		return kindStandard
+	}
+}
+
+// active 卡池是否在开放时间内
+func (b *banner) active(now time.Time) bool {
+	day := now.Format("2006-01-02")
+	return (b.Start == "" || day >= b.Start) && (b.Stop == "" || day <= b.Stop)
+}
+
+// loadbanners 先读取 zip 内的配置, 数据目录中的 banners.json 优先
+func loadbanners(local string) error {
+	var list []*banner
+	if fs, ok := filetree["banners.json"]; ok && len(fs) > 0 {
+		f, err := fs[0].Open()
+		if err != nil {
+			return err
+		}
+		err = json.NewDecoder(f).Decode(&list)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if f, err := os.Open(local); err == nil {
+		list = nil
+		err = json.NewDecoder(f).Decode(&list)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	banners = append(banners[:1], list...)
+	return nil
+}
+
+// currentbanner 群当前的卡池, 已结束的限定卡池回落到常驻
+func currentbanner(store storage) *banner {
+	i := store.banner()
+	if i <= 0 || i >= len(banners) || !banners[i].active(time.Now()) {
+		return banners[0]
+	}
+	return banners[i]
+}
+
+// filename 从文件名中取出角色武器名
+func filename(f *zip.File) string {
+	m := namereg.FindStringSubmatch(f.Name)
+	if len(m) < 2 {
+		return ""
+	}
+	return m[1]
+}
+
+// pickfile 从 folders 中选出一个文件. names 非空时只选这些名字, 否则排除 exclude 中的名字
+func pickfile(folders []string, names, exclude []string) *zip.File {
+	var fs []*zip.File
+	for _, folder := range folders {
+		for _, f := range filetree[folder] {
+			n := filename(f)
+			if len(names) > 0 && slices.Contains(names, n) || len(names) == 0 && !slices.Contains(exclude, n) {
+				fs = append(fs, f)
+			}
+		}
+	}
+	if len(fs) == 0 {
+		if len(names) > 0 {
+			// UP 不在资源包中, 按非UP处理
+			return pickfile(folders, nil, exclude)
+		}
+		for _, folder := range folders {
+			fs = append(fs, filetree[folder]...)
+		}
+	}
+	return fs[rand.Intn(len(fs))]
+}
+
+// pick 按卡池为一抽的结果选出角色或武器
+func (b *banner) pick(res result) (f *zip.File, weapon bool) {
+	switch res.star {
+	case 5:
+		switch b.kind() {
+		case kindCharacter:
+			if res.featured {
+				return pickfile([]string{"five"}, b.Five, nil), false
+			}
+			return pickfile([]string{"five"}, nil, b.Five), false
+		case kindWeapon:
+			if res.featured {
+				return pickfile([]string{"five2"}, b.Five, nil), true
+			}
+			return pickfile([]string{"five2"}, nil, b.Five), true
+		}
+		if rand.Intn(2) == 0 {
+			return pickfile([]string{"five"}, nil, nil), false
+		}
+		return pickfile([]string{"five2"}, nil, nil), true
+	case 4:
+		if res.featured {
+			f = pickfile([]string{"four", "four2"}, b.Four, nil)
+		} else if rand.Intn(2) == 0 {
+			f = pickfile([]string{"four"}, nil, b.Four)
+		} else {
+			f = pickfile([]string{"four2"}, nil, b.Four)
+		}
+		return f, slices.Contains(filetree["four2"], f)
+	default:
+		return pickfile([]string{"Three"}, nil, nil), true
+	}
+}
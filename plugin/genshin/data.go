@@ -14,3 +14,12 @@ func (s *storage) setmode(is5stars bool) bool {
 	}
 	return is5stars
 }
+
+// banner 选择的卡池序号, 0 为常驻
+func (s *storage) banner() int {
+	return int(*s >> 8 & 0xff)
+}
+
+func (s *storage) setbanner(i int) {
+	*s = *s&^(0xff<<8) | storage(i&0xff)<<8
+}
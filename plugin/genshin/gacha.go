@@ -0,0 +1,200 @@
+package genshin
+
+import (
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+)
+
+// 卡池类型
+const (
+	kindStandard  = iota // 常驻
+	kindCharacter        // 角色活动
+	kindWeapon           // 武器活动
+)
+
+var kindnames = [...]string{"常驻", "角色", "武器"}
+
+// 各类卡池的概率规则
+type rule struct {
+	base5, step5 float64 // 五星基础概率, 软保底后每抽提升
+	soft5, hard5 int     // 五星软保底起始抽数, 硬保底抽数
+	base4, step4 float64 // 四星基础概率, 软保底后每抽提升
+	soft4        int     // 四星软保底起始抽数
+	up5, up4     float64 // 出五星/四星时为UP的概率
+}
+
+var rules = [...]rule{
+	kindStandard:  {base5: 0.006, step5: 0.06, soft5: 74, hard5: 90, base4: 0.051, step4: 0.51, soft4: 9},
+	kindCharacter: {base5: 0.006, step5: 0.06, soft5: 74, hard5: 90, base4: 0.051, step4: 0.51, soft4: 9, up5: 0.5, up4: 0.5},
+	kindWeapon:    {base5: 0.007, step5: 0.07, soft5: 63, hard5: 80, base4: 0.06, step4: 0.6, soft4: 8, up5: 0.75, up4: 0.75},
+}
+
+// 第 n 抽出五星的概率
+func (r *rule) rate5(n int) float64 {
+	if n >= r.hard5 {
+		return 1
+	}
+	if n < r.soft5 {
+		return r.base5
+	}
+	return r.base5 + r.step5*float64(n-r.soft5+1)
+}
+
+// 第 n 抽出四星的概率
+func (r *rule) rate4(n int) float64 {
+	if n < r.soft4 {
+		return r.base4
+	}
+	return r.base4 + r.step4*float64(n-r.soft4+1)
+}
+
+// pity 某人在某类卡池的保底进度
+type pity struct {
+	ID     int64 `db:"id"`    // uid*10+kind
+	UserID int64 `db:"uid"`   // 用户
+	Kind   int   `db:"kind"`  // 卡池类型
+	Five   int   `db:"five"`  // 距上次五星的抽数
+	Four   int   `db:"four"`  // 距上次四星的抽数
+	Lose5  bool  `db:"lose5"` // 上个五星歪了, 下个五星必为UP
+	Lose4  bool  `db:"lose4"` // 上个四星歪了, 下个四星必为UP
+	Total  int   `db:"total"` // 累计抽数
+}
+
+// record 四星及以上的出货记录
+type record struct {
+	ID         int64  `db:"id"`         // unix 纳秒
+	UserID     int64  `db:"uid"`        // 用户
+	Kind       int    `db:"kind"`       // 卡池类型
+	Banner     string `db:"banner"`     // 卡池名
+	Name       string `db:"name"`       // 角色或武器名
+	Star       int    `db:"star"`       // 星级
+	Weapon     bool   `db:"weapon"`     // 是否为武器
+	Featured   bool   `db:"featured"`   // 是否为UP
+	Guaranteed bool   `db:"guaranteed"` // 是否由大保底获得
+	Pity       int    `db:"pity"`       // 出货时的抽数
+	Time       int64  `db:"time"`       // 抽取时间
+}
+
+// result 单抽结果
+type result struct {
+	star       int
+	featured   bool
+	guaranteed bool
+	pity       int
+}
+
+// roll 按保底规则抽一次, random 返回 [0,1) 的随机数
+func (p *pity) roll(random func() float64) (res result) {
+	r := &rules[p.Kind]
+	p.Five++
+	p.Four++
+	p.Total++
+	switch {
+	case random() < r.rate5(p.Five):
+		res = result{star: 5, pity: p.Five}
+		p.Five = 0
+		if r.up5 > 0 {
+			res.guaranteed = p.Lose5
+			res.featured = p.Lose5 || random() < r.up5
+			p.Lose5 = !res.featured
+		}
+	case random() < r.rate4(p.Four):
+		res = result{star: 4, pity: p.Four}
+		p.Four = 0
+		if r.up4 > 0 {
+			res.guaranteed = p.Lose4
+			res.featured = p.Lose4 || random() < r.up4
+			p.Lose4 = !res.featured
+		}
+	default:
+		res.star = 3
+	}
+	return
+}
+
+var (
+	dbmu sync.Mutex
+	db   sql.Sqlite
+)
+
+func opendb(path string) error {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	db = sql.New(path)
+	err := db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = db.Create("pity", &pity{})
+	if err != nil {
+		return err
+	}
+	return db.Create("record", &record{})
+}
+
+// wish 读取保底进度, 抽 n 次后保存, onpull 为每抽结果选取角色或武器并返回名称
+func wish(uid int64, b *banner, n int, random func() float64, onpull func(result) (name string, weapon bool)) ([]result, error) {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	id := uid*10 + int64(b.kind())
+	p, err := sql.Find[pity](&db, "pity", "WHERE id = ?", id)
+	if err == sql.ErrNullResult {
+		p, err = pity{ID: id, UserID: uid, Kind: b.kind()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	results := make([]result, n)
+	for i := range results {
+		res := p.roll(random)
+		results[i] = res
+		name, weapon := onpull(res)
+		if res.star < 4 {
+			continue
+		}
+		err = db.Insert("record", &record{
+			ID:         now.UnixNano() + int64(i),
+			UserID:     uid,
+			Kind:       p.Kind,
+			Banner:     b.Name,
+			Name:       name,
+			Star:       res.star,
+			Weapon:     weapon,
+			Featured:   res.featured,
+			Guaranteed: res.guaranteed,
+			Pity:       res.pity,
+			Time:       now.Unix(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, db.Insert("pity", &p)
+}
+
+// history 某人的保底进度与五星记录
+func history(uid int64) (ps []*pity, fives []*record, fours int, err error) {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+	ps, err = sql.FindAll[pity](&db, "pity", "WHERE uid = ? ORDER BY kind ASC", uid)
+	if err == sql.ErrNullResult {
+		return nil, nil, 0, nil
+	}
+	if err != nil {
+		return
+	}
+	fives, err = sql.FindAll[record](&db, "record", "WHERE uid = ? AND star = 5 ORDER BY id DESC", uid)
+	if err == sql.ErrNullResult {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	q, err := sql.Query[struct {
+		N int `db:"n"`
+	}](&db, "SELECT COUNT(*) AS n FROM record WHERE uid = ? AND star = 4;", uid)
+	return ps, fives, q.N, err
+}
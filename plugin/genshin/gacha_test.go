@@ -0,0 +1,41 @@
+package genshin
+
+import "testing"
+
+func TestRollPity(t *testing.T) {
+	never := func() float64 { return 0.999 }
+	p := pity{Kind: kindCharacter}
+	fives, fours := 0, 0
+	for i := 1; i <= rules[kindCharacter].hard5; i++ {
+		res := p.roll(never)
+		switch res.star {
+		case 5:
+			fives++
+			if i != rules[kindCharacter].hard5 || res.pity != i {
+				t.Fatalf("5 star at %d, pity %d", i, res.pity)
+			}
+			if res.featured || !p.Lose5 {
+				t.Fatal("should lose 50/50 with high random")
+			}
+		case 4:
+			fours++
+		}
+	}
+	if fives != 1 || fours == 0 {
+		t.Fatalf("fives %d fours %d", fives, fours)
+	}
+	for p.Five < rules[kindCharacter].hard5-1 {
+		p.roll(never)
+	}
+	res := p.roll(never)
+	if res.star != 5 || !res.featured || !res.guaranteed || p.Lose5 {
+		t.Fatalf("guarantee not applied: %+v", res)
+	}
+}
+
+func TestRate5(t *testing.T) {
+	r := &rules[kindWeapon]
+	if r.rate5(1) != r.base5 || r.rate5(r.hard5) != 1 || r.rate5(r.soft5) <= r.base5 {
+		t.Fatal("unexpected weapon rates")
+	}
+}
@@ -0,0 +1,130 @@
+package genshin
+
+import (
+	"image"
+	"strconv"
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg"
+	"github.com/FloatTech/zbputils/control"
+	"github.com/FloatTech/zbputils/img/text"
+)
+
+// 图中最多列出的五星数
+const maxfives = 20
+
+// stat 某类卡池的出金统计
+type stat struct {
+	fives, pulls  int // 五星数, 五星共用抽数
+	wins, fifties int // 小保底不歪数, 小保底数
+}
+
+func (s *stat) avg() string {
+	if s.fives == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(s.pulls)/float64(s.fives), 'f', 1, 64)
+}
+
+func stats(fives []*record) (sts [len(kindnames)]stat) {
+	for _, r := range fives {
+		s := &sts[r.Kind]
+		s.fives++
+		s.pulls += r.Pity
+		if r.Kind != kindStandard && !r.Guaranteed {
+			s.fifties++
+			if r.Featured {
+				s.wins++
+			}
+		}
+	}
+	return
+}
+
+// drawhistory 绘制抽卡记录
+func drawhistory(name string, ps []*pity, fives []*record, fours int) (image.Image, error) {
+	fontdata, err := file.GetLazyData(text.BoldFontFile, control.Md5File, true)
+	if err != nil {
+		return nil, err
+	}
+	const w, lh = 1000.0, 52.0
+	shown := fives
+	if len(shown) > maxfives {
+		shown = shown[:maxfives]
+	}
+	h := 40 + lh*1.6 + lh*float64(len(ps)*2+2+len(shown)) + 40
+	canvas := gg.NewContext(int(w), int(h))
+	canvas.SetRGB255(250, 247, 240)
+	canvas.Clear()
+
+	if err = canvas.ParseFontFace(fontdata, 48); err != nil {
+		return nil, err
+	}
+	y := 40 + lh
+	canvas.SetRGB255(60, 50, 40)
+	canvas.DrawString(name+" 的抽卡记录", 40, y)
+	y += lh * 0.6
+	if err = canvas.ParseFontFace(fontdata, 30); err != nil {
+		return nil, err
+	}
+	sts := stats(fives)
+	for _, p := range ps {
+		s := &sts[p.Kind]
+		r := &rules[p.Kind]
+		y += lh
+		canvas.SetRGB255(60, 50, 40)
+		line := "【" + kindnames[p.Kind] + "】共" + strconv.Itoa(p.Total) + "抽  已垫" + strconv.Itoa(p.Five) + "/" + strconv.Itoa(r.hard5)
+		if r.up5 > 0 {
+			if p.Lose5 {
+				line += "  大保底"
+			} else {
+				line += "  小保底"
+			}
+		}
+		canvas.DrawString(line, 40, y)
+		y += lh
+		canvas.SetRGB255(120, 110, 100)
+		line = "    五星" + strconv.Itoa(s.fives) + "个  平均" + s.avg() + "抽出金"
+		if s.fifties > 0 {
+			line += "  小保底不歪" + strconv.Itoa(s.wins) + "/" + strconv.Itoa(s.fifties)
+		}
+		canvas.DrawString(line, 40, y)
+	}
+	y += lh
+	canvas.SetRGB255(60, 50, 40)
+	canvas.DrawString("四星共"+strconv.Itoa(fours)+"个, 最近的五星:", 40, y)
+	y += lh
+	if len(shown) == 0 {
+		canvas.SetRGB255(120, 110, 100)
+		canvas.DrawString("还没有抽到过五星", 40, y)
+	}
+	const barx, barw = 330.0, 460.0
+	for _, r := range shown {
+		canvas.SetRGB255(60, 50, 40)
+		canvas.DrawString(r.Name, 40, y)
+		frac := float64(r.Pity) / float64(rules[r.Kind].hard5)
+		switch {
+		case frac < 0.6:
+			canvas.SetRGB255(90, 170, 90)
+		case frac < 0.85:
+			canvas.SetRGB255(230, 170, 60)
+		default:
+			canvas.SetRGB255(210, 80, 70)
+		}
+		canvas.DrawRoundedRectangle(barx, y-lh*0.55, barw*frac, lh*0.6, 8)
+		canvas.Fill()
+		canvas.SetRGB255(60, 50, 40)
+		canvas.DrawString(strconv.Itoa(r.Pity), barx+barw*frac+10, y)
+		if r.Kind != kindStandard {
+			if r.Featured {
+				canvas.SetRGB255(200, 140, 30)
+				canvas.DrawString("UP", w-120, y)
+			} else {
+				canvas.SetRGB255(210, 80, 70)
+				canvas.DrawString("歪", w-120, y)
+			}
+		}
+		y += lh
+	}
+	return canvas.Image(), nil
+}
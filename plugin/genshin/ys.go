@@ -10,8 +10,10 @@ import (
 	"image/png"
 	"math/rand"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-	"sync/atomic"
+	"time"
 
 	fcext "github.com/FloatTech/floatbox/ctxext"
 	"github.com/FloatTech/floatbox/process"
@@ -28,7 +30,6 @@ import (
 type zipfilestructure map[string][]*zip.File
 
 var (
-	totl                   uint64 // 累计抽奖次数
 	filetree               = make(zipfilestructure, 32)
 	starN3, starN4, starN5 *zip.File
 	namereg                = regexp.MustCompile(`_(.*)\.png`)
@@ -38,11 +39,41 @@ func init() {
 	engine := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
 		DisableOnDefault: false,
 		Brief:            "原神模拟抽卡",
-		Help:             "- 原神十连\n- 切换原神卡池",
+		Help: "- 原神十连\n" +
+			"- 切换原神卡池 (切换五星卡池)\n" +
+			"- 切换原神卡池 [卡池名]\n" +
+			"- 原神卡池列表\n" +
+			"- 抽卡记录\n" +
+			"Tips: 保底进度按人记录, 角色活动卡池共享保底; 限定卡池可在数据目录的 banners.json 中配置",
 		PublicDataFolder: "Genshin",
 	}).ApplySingle(ctxext.DefaultSingle)
 
-	engine.OnFullMatch("切换原神卡池").SetBlock(true).Limit(ctxext.LimitByUser).
+	getzip := fcext.DoOnceOnSuccess(func(ctx *zero.Ctx) bool {
+		zipfile := engine.DataFolder() + "Genshin.zip"
+		_, err := engine.GetLazyData("Genshin.zip", false)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return false
+		}
+		err = parsezip(zipfile)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return false
+		}
+		err = loadbanners(engine.DataFolder() + "banners.json")
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return false
+		}
+		err = opendb(engine.DataFolder() + "gacha.db")
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return false
+		}
+		return true
+	})
+
+	engine.OnRegex(`^切换原神卡池\s*(\S*)$`, getzip).SetBlock(true).Limit(ctxext.LimitByUser).
 		Handle(func(ctx *zero.Ctx) {
 			c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
 			if !ok {
@@ -54,12 +85,24 @@ func init() {
 				gid = -ctx.Event.UserID
 			}
 			store := (storage)(c.GetData(gid))
-			if store.setmode(!store.is5starsmode()) {
+			name := ctx.State["regex_matched"].([]string)[1]
+			switch {
+			case name != "":
+				i := slices.IndexFunc(banners, func(b *banner) bool { return b.Name == name })
+				if i < 0 || !banners[i].active(time.Now()) {
+					ctx.SendChain(message.Text("没有开放中的卡池: ", name))
+					return
+				}
+				store.setbanner(i)
+				store.setmode(false)
+				process.SleepAbout1sTo2s()
+				ctx.SendChain(message.Text("切换到", name, "卡池~"))
+			case store.setmode(!store.is5starsmode()):
 				process.SleepAbout1sTo2s()
 				ctx.SendChain(message.Text("切换到五星卡池~"))
-			} else {
+			default:
 				process.SleepAbout1sTo2s()
-				ctx.SendChain(message.Text("切换到普通卡池~"))
+				ctx.SendChain(message.Text("切换到", currentbanner(store).Name, "卡池~"))
 			}
 			err := c.SetData(gid, int64(store))
 			if err != nil {
@@ -68,22 +111,74 @@ func init() {
 			}
 		})
 
-	engine.OnFullMatch("原神十连", fcext.DoOnceOnSuccess(
-		func(ctx *zero.Ctx) bool {
-			zipfile := engine.DataFolder() + "Genshin.zip"
-			_, err := engine.GetLazyData("Genshin.zip", false)
+	engine.OnFullMatch("原神卡池列表", getzip).SetBlock(true).Limit(ctxext.LimitByUser).
+		Handle(func(ctx *zero.Ctx) {
+			c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+			if !ok {
+				ctx.SendChain(message.Text("找不到服务!"))
+				return
+			}
+			gid := ctx.Event.GroupID
+			if gid == 0 {
+				gid = -ctx.Event.UserID
+			}
+			store := (storage)(c.GetData(gid))
+			cur := currentbanner(store)
+			now := time.Now()
+			var sb strings.Builder
+			sb.WriteString("原神卡池:")
+			for _, b := range banners {
+				if !b.active(now) {
+					continue
+				}
+				sb.WriteString("\n")
+				if b == cur && !store.is5starsmode() {
+					sb.WriteString("▶ ")
+				}
+				sb.WriteString(b.Name)
+				sb.WriteString(" [")
+				sb.WriteString(b.Kind)
+				sb.WriteString("]")
+				if len(b.Five) > 0 {
+					sb.WriteString(" UP: ")
+					sb.WriteString(strings.Join(b.Five, "、"))
+				}
+				if b.Stop != "" {
+					sb.WriteString(" 至")
+					sb.WriteString(b.Stop)
+				}
+			}
+			if store.is5starsmode() {
+				sb.WriteString("\n当前为五星卡池")
+			}
+			ctx.SendChain(message.Text(sb.String()))
+		})
+
+	engine.OnFullMatchGroup([]string{"抽卡记录", "原神抽卡记录"}, getzip).SetBlock(true).Limit(ctxext.LimitByUser).
+		Handle(func(ctx *zero.Ctx) {
+			ps, fives, fours, err := history(ctx.Event.UserID)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if len(ps) == 0 {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("你还没有抽过卡哦"))
+				return
+			}
+			img, err := drawhistory(ctx.CardOrNickName(ctx.Event.UserID), ps, fives, fours)
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
-				return false
+				return
 			}
-			err = parsezip(zipfile)
+			b, err := factory.ToBytes(img)
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
-				return false
+				return
 			}
-			return true
-		},
-	)).SetBlock(true).Limit(ctxext.LimitByUser).
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.ImageBytes(b))
+		})
+
+	engine.OnFullMatch("原神十连", getzip).SetBlock(true).Limit(ctxext.LimitByUser).
 		Handle(func(ctx *zero.Ctx) {
 			c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
 			if !ok {
@@ -95,7 +190,7 @@ func init() {
 				gid = -ctx.Event.UserID
 			}
 			store := (storage)(c.GetData(gid))
-			img, str, mode, err := randnums(10, store)
+			img, str, mode, err := randnums(10, store, ctx.Event.UserID)
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
 				return
@@ -110,12 +205,12 @@ func init() {
 					message.Text("恭喜你抽到了: \n", str), message.ImageBytes(b)))
 			} else {
 				ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID,
-					message.Text("十连成功~"), message.ImageBytes(b)))
+					message.Text("十连成功~", str), message.ImageBytes(b)))
 			}
 		})
 }
 
-func randnums(nums int, store storage) (rgba *image.RGBA, str string, replyMode bool, err error) {
+func randnums(nums int, store storage, uid int64) (rgba *image.RGBA, str string, replyMode bool, err error) {
 	var (
 		fours, fives                  = make([]*zip.File, 0, 10), make([]*zip.File, 0, 10)                           // 抽到 四, 五星角色
 		threeArms, fourArms, fiveArms = make([]*zip.File, 0, 10), make([]*zip.File, 0, 10), make([]*zip.File, 0, 10) // 抽到 三 , 四, 五星武器
@@ -128,23 +223,9 @@ func randnums(nums int, store storage) (rgba *image.RGBA, str string, replyMode
 		fivebg, fourbg, threebg = filetree["five_bg.jpg"][0], filetree["four_bg.jpg"][0], filetree["three_bg.jpg"][0] // 背景图片名
 		fivelen                 = len(filetree["five"])
 		five2len                = len(filetree["five2"])
-		threelen                = len(filetree["Three"])
-		fourlen                 = len(filetree["four"])
-		four2len                = len(filetree["four2"])
 	)
 
-	if totl%9 == 0 { // 累计9次加入一个五星
-		switch rand.Intn(2) {
-		case 0:
-			fiveN++
-			fives = append(fives, filetree["five"][rand.Intn(fivelen)])
-		case 1:
-			fiveN2++
-			fiveArms = append(fiveArms, filetree["five2"][rand.Intn(five2len)])
-		}
-		nums--
-	}
-
+	var pityinfo string
 	if store.is5starsmode() { // 5星模式
 		for i := 0; i < nums; i++ {
 			switch rand.Intn(2) {
@@ -156,40 +237,48 @@ func randnums(nums int, store storage) (rgba *image.RGBA, str string, replyMode
 				fiveArms = append(fiveArms, filetree["five2"][rand.Intn(five2len)])
 			}
 		}
-	} else { // 默认模式
-		for i := 0; i < nums; i++ {
-			a := rand.Intn(1000) // 抽卡几率 三星80% 四星17% 五星3%
+	} else { // 按保底规则抽取
+		b := currentbanner(store)
+		results, err := wish(uid, b, nums, rand.Float64, func(res result) (string, bool) {
+			f, weapon := b.pick(res)
 			switch {
-			case a >= 0 && a <= 800:
-				threeN2++
-				threeArms = append(threeArms, filetree["Three"][rand.Intn(threelen)])
-			case a > 800 && a <= 885:
-				fourN++
-				fours = append(fours, filetree["four"][rand.Intn(fourlen)]) // 随机角色
-			case a > 885 && a <= 970:
-				fourN2++
-				fourArms = append(fourArms, filetree["four2"][rand.Intn(four2len)]) // 随机武器
-			case a > 970 && a <= 985:
+			case res.star == 5 && weapon:
+				fiveN2++
+				fiveArms = append(fiveArms, f)
+			case res.star == 5:
 				fiveN++
-				fives = append(fives, filetree["five"][rand.Intn(fivelen)])
+				fives = append(fives, f)
+			case res.star == 4 && weapon:
+				fourN2++
+				fourArms = append(fourArms, f)
+			case res.star == 4:
+				fourN++
+				fours = append(fours, f)
 			default:
-				fiveN2++
-				fiveArms = append(fiveArms, filetree["five2"][rand.Intn(five2len)])
+				threeN2++
+				threeArms = append(threeArms, f)
 			}
+			return filename(f), weapon
+		})
+		if err != nil {
+			return nil, "", false, err
 		}
-		if fourN+fourN2 == 0 && threeN2 > 0 { // 没有四星时自动加入
-			threeN2--
-			threeArms = threeArms[:len(threeArms)-1]
-			switch rand.Intn(2) {
-			case 0:
-				fourN++
-				fours = append(fours, filetree["four"][rand.Intn(fourlen)]) // 随机角色
-			case 1:
-				fourN2++
-				fourArms = append(fourArms, filetree["four2"][rand.Intn(four2len)]) // 随机武器
+		for i, res := range results {
+			if res.star != 5 {
+				continue
+			}
+			pityinfo += "\n第" + strconv.Itoa(i+1) + "抽: " + strconv.Itoa(res.pity) + "抽出金"
+			switch {
+			case b.kind() == kindStandard:
+			case res.guaranteed:
+				pityinfo += "(大保底)"
+			case res.featured:
+				pityinfo += "(小保底不歪)"
+			default:
+				pityinfo += "(歪了)"
 			}
 		}
-		_ = atomic.AddUint64(&totl, 1)
+		pityinfo = "\n[" + b.Name + "]" + pityinfo
 	}
 
 	icon := func(f *zip.File) *zip.File {
@@ -240,6 +329,7 @@ func randnums(nums int, store storage) (rgba *image.RGBA, str string, replyMode
 	if threeN2 > 0 {
 		he(threeN2, 1, starN3, threebg) // 三星武器
 	}
+	str += pityinfo
 
 	var c1, c2, c3 uint8 = 50, 50, 50 // 背景颜色
 
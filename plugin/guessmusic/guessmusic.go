@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,8 @@ import (
 	"github.com/wdvxdr1123/ZeroBot/message"
 
 	"github.com/liuzl/gocc"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/gamerank/stats"
 )
 
 var cuttime = [...]string{"00:00:05", "00:00:30", "00:01:00"} // 音乐切割时间点,可自行调节时间（时：分：秒）
@@ -77,23 +80,20 @@ func init() {
 				ctx.SendChain(message.Text(serviceErr, err))
 				return
 			}
-			// 解析歌曲信息
-			music := strings.Split(musicName, ".")
 			// 获取音乐后缀
-			musictype := music[len(music)-1]
+			musictype := strings.TrimPrefix(path.Ext(musicName), ".")
 			if !strings.Contains(musictypelist, musictype) {
 				ctx.SendChain(message.Text("抽取到了歌曲：\n",
 					musicName, "\n该歌曲不是音乐后缀,请联系bot主人修改"))
 				return
 			}
 			// 获取音乐信息
-			musicInfo := strings.Split(strings.ReplaceAll(musicName, "."+musictype, ""), " - ")
-			infoNum := len(musicInfo)
-			if infoNum == 1 {
-				ctx.SendChain(message.Text("抽取到了歌曲：\n",
-					musicName, "\n该歌曲命名不符合命名规则,请联系bot主人修改"))
+			musicInfo, err := getMusicInfo(pathOfMusic, musicName)
+			if err != nil {
+				ctx.SendChain(message.Text("抽取到了歌曲：\n", musicName, "\n", err))
 				return
 			}
+			infoNum := len(musicInfo)
 			answerString := "歌名:" + musicInfo[0] + "\n歌手:" + musicInfo[1]
 			if infoNum > 2 {
 				musicInfo[2] = strings.ReplaceAll(musicInfo[2], "&", "\n")
@@ -112,7 +112,7 @@ func init() {
 			if err != nil {
 				return
 			}
-			getMusicSelect(ctx, files, musicName)
+			getMusicSelect(ctx, files, pathOfMusic, musicName)
 			// 进行猜歌环节
 			ctx.SendChain(message.Record("file:///" + file.BOTPATH + "/" + outputPath + "0.wav"))
 			var next *zero.FutureEvent
@@ -123,6 +123,7 @@ func init() {
 			}
 			recv, cancel := next.Repeat()
 			defer cancel()
+			round := stats.NewRound(stats.GuessMusic, gid)
 			wait := time.NewTimer(40 * time.Second)
 			tick := time.NewTimer(105 * time.Second)
 			after := time.NewTimer(120 * time.Second)
@@ -138,6 +139,7 @@ func init() {
 				case <-tick.C:
 					ctx.SendChain(message.Text("猜歌游戏,你还有15s作答时间"))
 				case <-after.C:
+					round.End(0)
 					ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID,
 						message.Text("时间超时,猜歌结束,公布答案：\n", answerString)))
 					return
@@ -155,7 +157,7 @@ func init() {
 				case c := <-recv:
 					wg.Add(1)
 					go func() {
-						messageStr, answerCount, tickCount, win = gameMatch(c, ctx.Event.UserID, musicInfo, answerCount, tickCount)
+						messageStr, answerCount, tickCount, win = gameMatch(c, ctx.Event.UserID, musicInfo, answerCount, tickCount, round)
 						if win { // 游戏结束的话
 							wait.Stop()
 							tick.Stop()
@@ -279,10 +281,10 @@ func cutMusic(musicName, pathOfMusic, outputPath string) (err error) {
 }
 
 // 数据匹配（结果信息，答题次数，提示次数，是否结束游戏）
-func gameMatch(c *zero.Ctx, beginner int64, musicInfo []string, answerTimes, tickTimes int) (message.Segment, int, int, bool) {
-	answer := strings.Replace(c.Event.Message.String(), "-", "", 1)
+func gameMatch(c *zero.Ctx, beginner int64, musicInfo []string, answerTimes, tickTimes int, round *stats.Round) (message.Segment, int, int, bool) {
+	raw := strings.TrimSpace(strings.Replace(c.Event.Message.String(), "-", "", 1))
 	// 回答内容转小写，比对时再把标准答案转小写
-	answer = ConvertText(answer)
+	answer := ConvertText(raw)
 
 	switch {
 	case answer == "取消":
@@ -296,19 +298,27 @@ func gameMatch(c *zero.Ctx, beginner int64, musicInfo []string, answerTimes, tic
 			return message.Text("已经没有提示了哦"), answerTimes, tickTimes, false
 		}
 		return message.Text("再听这段音频,要仔细听哦"), answerTimes, tickTimes, false
-	case strings.Contains(ConvertText(musicInfo[0]), answer) || strings.EqualFold(ConvertText(musicInfo[0]), answer):
+	case isMatch(raw, musicInfo[0], false):
+		round.Guess(c.Event.UserID)
+		round.End(c.Event.UserID)
 		return message.Text("太棒了,你猜对歌曲名了！答案是\n", musicInfo[len(musicInfo)-1], "\n\n下面欣赏猜歌的歌曲"), answerTimes, tickTimes, true
-	case strings.Contains(ConvertText(musicInfo[1]), answer) || strings.EqualFold(ConvertText(musicInfo[1]), answer):
+	case isMatch(raw, musicInfo[1], true):
+		round.Guess(c.Event.UserID)
+		round.End(c.Event.UserID)
 		return message.Text("太棒了,你猜对歌手名了！答案是\n", musicInfo[len(musicInfo)-1], "\n\n下面欣赏猜歌的歌曲"), answerTimes, tickTimes, true
-	case len(musicInfo) == 4 && (strings.Contains(ConvertText(musicInfo[2]), answer) || strings.EqualFold(ConvertText(musicInfo[2]), answer)):
+	case len(musicInfo) == 4 && isMatch(raw, musicInfo[2], false):
+		round.Guess(c.Event.UserID)
+		round.End(c.Event.UserID)
 		return message.Text("太棒了,你猜对相关信息了！答案是\n", musicInfo[len(musicInfo)-1], "\n\n下面欣赏猜歌的歌曲"), answerTimes, tickTimes, true
 	default:
+		round.Guess(c.Event.UserID)
 		answerTimes++
 		tickTimes++
 		switch {
 		case tickTimes > 2 && answerTimes < 6:
 			return message.Text("答案不对哦,还有", 6-answerTimes, "次答题,加油啊~"), answerTimes, tickTimes, false
 		case tickTimes > 2:
+			round.End(0)
 			return message.Text("次数到了,没能猜出来。答案是\n", musicInfo[len(musicInfo)-1], "\n\n下面欣赏猜歌的歌曲"), answerTimes, tickTimes, true
 		default:
 			return message.Text("答案不对,再听这段音频,要仔细听哦"), answerTimes, tickTimes, false
@@ -328,7 +338,7 @@ func ConvertText(input string) string {
 	return toLower
 }
 
-func getMusicSelect(ctx *zero.Ctx, files []fs.DirEntry, musicName string) {
+func getMusicSelect(ctx *zero.Ctx, files []fs.DirEntry, pathOfMusic, musicName string) {
 	// 生成音乐选项
 	var musicInfo []string
 	musicInfo = append(musicInfo, musicName)
@@ -344,22 +354,19 @@ func getMusicSelect(ctx *zero.Ctx, files []fs.DirEntry, musicName string) {
 
 	musicNameSelect := "请选出正确歌曲：\n"
 	for i := 0; i < len(musicInfo); i++ {
-		// 解析歌曲信息
-		music := strings.Split(musicInfo[i], ".")
 		// 获取音乐后缀
-		musicType := music[len(music)-1]
+		musicType := strings.TrimPrefix(path.Ext(musicInfo[i]), ".")
 		if !strings.Contains(musictypelist, musicType) {
 			ctx.SendChain(message.Text("抽取到了歌曲：\n",
 				musicInfo[i], "\n该歌曲不是音乐后缀,请联系bot主人修改"))
 		}
 		// 获取音乐信息
-		musicInfo := strings.Split(strings.ReplaceAll(musicInfo[i], "."+musicType, ""), " - ")
-		infoNum := len(musicInfo)
-		if infoNum == 1 {
-			ctx.SendChain(message.Text("抽取到了歌曲：\n",
-				musicInfo[i], "\n该歌曲命名不符合命名规则,请联系bot主人修改"))
+		info, err := getMusicInfo(pathOfMusic, musicInfo[i])
+		if err != nil {
+			ctx.SendChain(message.Text("抽取到了歌曲：\n", musicInfo[i], "\n", err))
+			continue
 		}
-		musicNameSelect += musicInfo[0] + "  歌手:" + musicInfo[1] + "\n"
+		musicNameSelect += info[0] + "  歌手:" + info[1] + "\n"
 	}
 	ctx.SendChain(message.Text(musicNameSelect))
 }
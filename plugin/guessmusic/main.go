@@ -50,7 +50,8 @@ var (
 			"4.未设置默认歌单的场合,猜歌歌单为歌单列表第一个。\n" +
 			"此外可在\"[个人/团队]猜歌\"指令后面添加[-歌单名称]进行指定歌单猜歌\n" +
 			"5.猜歌内容必须以[-]开头才会识别\n" +
-			"6.歌曲命名规则为:\n歌名 - 歌手 - 其他(歌曲出处之类)" +
+			"6.优先读取歌曲的ID3v2/FLAC/Vorbis标签,没有标签时歌曲命名规则为:\n歌名 - 歌手 - 其他(歌曲出处之类)\n" +
+			"7.回答支持拼音、拼音首字母、罗马音与繁体, 也可以只答多位歌手中的一位" +
 			"\n------插 件 扩 展------\n" +
 			"内置了独角兽API,但API不保证可靠性。\n" +
 			"可以自行搭建或寻找NeteaseCloudMusicApi框架的API,本插件支持该API以下指令\n" +
@@ -70,7 +71,7 @@ var (
 	// 用于存放用户的配置
 	cfgFile = engine.DataFolder() + "config.json"
 	// ffmpeg支持的格式
-	musictypelist = "mp3;MP3;wav;WAV;amr;AMR;3gp;3GP;3gpp;3GPP;acc;ACC"
+	musictypelist = "mp3;MP3;wav;WAV;amr;AMR;3gp;3GP;3gpp;3GPP;acc;ACC;flac;FLAC;ogg;OGG;m4a;M4A"
 )
 
 func init() {
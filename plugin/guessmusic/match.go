@@ -0,0 +1,263 @@
+package guessmusic
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// matchThreshold 模糊匹配的最低相似度
+const matchThreshold = 0.8
+
+var (
+	// 括号内的注释, 如 (feat. xxx)、（Live）、[伴奏]
+	bracketRe = regexp.MustCompile(`[(（\[【「『]([^)）\]】」』]*)[)）\]】」』]`)
+	// 合作歌手后缀
+	featRe = regexp.MustCompile(`(?i)\s+(feat\.?|ft\.)\s.*$`)
+	// 多个歌手之间的分隔
+	artistSep = regexp.MustCompile(`\s*(&|/|、|,|，|;|；|\bx\b|\bvs\.?)\s*`)
+	pyargs    = func() pinyin.Args {
+		a := pinyin.NewArgs()
+		a.Fallback = func(r rune, _ pinyin.Args) []string {
+			return []string{string(r)}
+		}
+		return a
+	}()
+)
+
+// 括号内的版本说明, 不是歌名的别名
+var (
+	tagWords = map[string]struct{}{
+		"live": {}, "remix": {}, "mix": {}, "cover": {}, "inst": {}, "instrumental": {},
+		"acoustic": {}, "demo": {}, "remaster": {}, "remastered": {}, "ver": {}, "version": {},
+		"edit": {}, "tv": {}, "size": {}, "short": {}, "off": {}, "vocal": {}, "karaoke": {},
+		"explicit": {}, "feat": {}, "ft": {}, "prod": {}, "piano": {}, "bonus": {}, "track": {},
+	}
+	tagHan = []string{"伴奏", "纯音乐", "翻唱", "现场", "版", "主题曲", "插曲", "片头曲", "片尾曲", "完整", "剪辑", "原唱", "录音"}
+)
+
+// 罗马音, 只覆盖常用的平假名, 片假名先转为平假名
+var kanaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'を': "wo", 'ん': "n",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+}
+
+// 拗音
+var kanaSmall = map[rune]string{'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo"}
+
+// 去掉括号注释、合作歌手与标点, 统一大小写与简繁
+func normalize(s string) string {
+	s = bracketRe.ReplaceAllString(s, "")
+	s = featRe.ReplaceAllString(s, "")
+	return strip(ConvertText(s))
+}
+
+// 只保留文字与数字
+func strip(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// 括号内的文字是否为版本说明, 如 Live、伴奏、Piano Ver.
+func istag(s string) bool {
+	words := strings.FieldsFunc(ConvertText(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if _, ok := tagWords[w]; ok {
+			return true
+		}
+		for _, t := range tagHan {
+			if strings.Contains(w, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 歌名或歌手可接受的写法: 原文、括号内的别名, 版本说明不算别名
+func aliases(s string) []string {
+	list := []string{normalize(s)}
+	for _, m := range bracketRe.FindAllStringSubmatch(s, -1) {
+		if a := strip(ConvertText(m[1])); a != "" && !istag(m[1]) {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// 拆分多个歌手
+func splitArtists(s string) []string {
+	s = featRe.ReplaceAllString(s, "")
+	return append(artistSep.Split(s, -1), s)
+}
+
+// 片假名转为平假名
+func hiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - 0x60
+	}
+	return r
+}
+
+// 转为拼音或罗马音, 其他字符保持不变
+func romanize(s string) string {
+	rs := []rune(s)
+	for i := range rs {
+		rs[i] = hiragana(rs[i])
+	}
+	var sb strings.Builder
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.Is(unicode.Han, r):
+			sb.WriteString(strings.Join(pinyin.LazyPinyin(string(r), pyargs), ""))
+		case r == 'っ':
+			// 促音, 重复下一个辅音
+			if i+1 < len(rs) {
+				if next := kanaRomaji[rs[i+1]]; next != "" {
+					sb.WriteByte(next[0])
+				}
+			}
+		case r == 'ー':
+		default:
+			ro, ok := kanaRomaji[r]
+			if !ok {
+				sb.WriteRune(r)
+				continue
+			}
+			if i+1 < len(rs) {
+				if sm, ok := kanaSmall[rs[i+1]]; ok && len(ro) > 1 && strings.HasSuffix(ro, "i") {
+					ro = strings.TrimSuffix(ro, "i")
+					if ro == "sh" || ro == "ch" || ro == "j" {
+						sm = sm[1:]
+					}
+					ro += sm
+					i++
+				}
+			}
+			sb.WriteString(ro)
+		}
+	}
+	return sb.String()
+}
+
+// 拼音首字母
+func initials(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			sb.WriteString(romanize(string(r))[:1])
+		}
+	}
+	return sb.String()
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// 编辑距离相似度, 0~1
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// 单个候选答案的得分
+func scoreOne(answer, target string) float64 {
+	if answer == "" || target == "" {
+		return 0
+	}
+	if answer == target {
+		return 1
+	}
+	// 答案是标准答案的一部分, 或标准答案被完整包含在回答里
+	n := len([]rune(answer))
+	if (n >= 2 || len([]rune(target)) <= 2) && strings.Contains(target, answer) {
+		return 1
+	}
+	if len([]rune(target)) >= 2 && strings.Contains(answer, target) {
+		return 1
+	}
+	score := similarity(answer, target)
+	// 用拼音或罗马音作答
+	ra, rt := romanize(answer), romanize(target)
+	if ra != answer || rt != target {
+		if ra == rt || (len(ra) >= 4 && strings.Contains(rt, ra)) {
+			return 1
+		}
+		score = max(score, similarity(ra, rt))
+	}
+	// 用拼音首字母作答
+	if n >= 2 && isASCII(answer) && answer == initials(target) {
+		return 1
+	}
+	return score
+}
+
+// 回答与一项信息的最高相似度
+func matchScore(answer, info string, isArtist bool) float64 {
+	answer = normalize(answer)
+	targets := []string{info}
+	if isArtist {
+		targets = splitArtists(info)
+	}
+	best := 0.0
+	for _, t := range targets {
+		for _, a := range aliases(t) {
+			best = max(best, scoreOne(answer, a))
+		}
+	}
+	return best
+}
+
+// 回答是否匹配
+func isMatch(answer, info string, isArtist bool) bool {
+	return matchScore(answer, info, isArtist) >= matchThreshold
+}
@@ -0,0 +1,89 @@
+package guessmusic
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{name: "plain", in: "晴天", want: []string{"晴天"}},
+		{name: "alias", in: "打上花火 (Uchiage Hanabi)", want: []string{"打上花火", "uchiagehanabi"}},
+		{name: "feat", in: "Stay (feat. Justin Bieber)", want: []string{"stay"}},
+		{name: "live", in: "后来 (Live)", want: []string{"后来"}},
+		{name: "accompaniment", in: "起风了【伴奏】", want: []string{"起风了"}},
+		{name: "remix", in: "Faded (Alan Walker Remix)", want: []string{"faded"}},
+		{name: "version", in: "千本桜 (Piano Ver.)", want: []string{"千本桜"}},
+		{name: "theme song", in: "光年之外（电影《太空旅客》中国区主题曲）", want: []string{"光年之外"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aliases(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("aliases(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRomanize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "晴天", want: "qingtian"},
+		{in: "さくら", want: "sakura"},
+		{in: "カタカナ", want: "katakana"},
+		{in: "きょう", want: "kyou"},
+		{in: "しゃしん", want: "shashin"},
+		{in: "ちょっと", want: "chotto"},
+		{in: "abc", want: "abc"},
+	}
+	for _, tt := range tests {
+		if got := romanize(tt.in); got != tt.want {
+			t.Errorf("romanize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScoreOne(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		target string
+		match  bool
+	}{
+		{name: "exact", answer: "晴天", target: "晴天", match: true},
+		{name: "empty", answer: "", target: "晴天", match: false},
+		{name: "part of title", answer: "花火", target: "打上花火", match: true},
+		{name: "single char", answer: "花", target: "打上花火", match: false},
+		{name: "pinyin", answer: "qingtian", target: "晴天", match: true},
+		{name: "initials", answer: "qt", target: "晴天", match: true},
+		{name: "romaji", answer: "sakura", target: "さくら", match: true},
+		{name: "typo", answer: "yesterdey", target: "yesterday", match: true},
+		{name: "wrong", answer: "七里香", target: "晴天", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoreOne(tt.answer, tt.target) >= matchThreshold; got != tt.match {
+				t.Errorf("scoreOne(%q, %q) = %v, want match %v", tt.answer, tt.target, scoreOne(tt.answer, tt.target), tt.match)
+			}
+		})
+	}
+}
+
+func TestIsMatchIgnoresTags(t *testing.T) {
+	for _, answer := range []string{"live", "伴奏", "remix"} {
+		for _, title := range []string{"后来 (Live)", "起风了 (伴奏)", "Faded (Remix)"} {
+			if isMatch(answer, title, false) {
+				t.Errorf("%q should not match %q", answer, title)
+			}
+		}
+	}
+	if !isMatch("后来", "后来 (Live)", false) {
+		t.Error("title without tag should match")
+	}
+}
@@ -0,0 +1,48 @@
+package guessmusic
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/pkg/errors"
+)
+
+// 读取歌曲信息（歌名，歌手，[其他信息]）
+// 优先读取 ID3v2/FLAC/Vorbis 标签, 缺失的部分按“歌名 - 歌手 - 其他”的文件名补全
+func getMusicInfo(pathOfMusic, musicName string) ([]string, error) {
+	musicInfo := strings.Split(strings.TrimSuffix(musicName, path.Ext(musicName)), " - ")
+	title, artist, other := musicInfo[0], "", ""
+	if len(musicInfo) > 1 {
+		artist = musicInfo[1]
+	}
+	if len(musicInfo) > 2 {
+		other = musicInfo[2]
+	}
+	f, err := os.Open(pathOfMusic + musicName)
+	if err == nil {
+		m, err := tag.ReadFrom(f)
+		_ = f.Close()
+		if err == nil {
+			if t := strings.TrimSpace(m.Title()); t != "" {
+				title = t
+			}
+			if a := strings.TrimSpace(m.Artist()); a != "" {
+				artist = a
+			} else if a := strings.TrimSpace(m.AlbumArtist()); a != "" {
+				artist = a
+			}
+			if a := strings.TrimSpace(m.Album()); a != "" && other == "" {
+				other = a
+			}
+		}
+	}
+	if title == "" || artist == "" {
+		return nil, errors.New("该歌曲没有标签且命名不符合命名规则,请联系bot主人修改")
+	}
+	if other == "" {
+		return []string{title, artist}, nil
+	}
+	return []string{title, artist, other}, nil
+}
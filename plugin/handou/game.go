@@ -26,6 +26,8 @@ import (
 	"github.com/FloatTech/zbputils/img/text"
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/gamerank/stats"
 )
 
 type idiomJSON struct {
@@ -150,6 +152,7 @@ func init() {
 		var win bool
 		recv, cancel := next.Repeat()
 		defer cancel()
+		round := stats.NewRound(stats.Handou, ctx.Event.GroupID)
 		tick := time.NewTimer(105 * time.Second)
 		after := time.NewTimer(120 * time.Second)
 		for {
@@ -157,6 +160,7 @@ func init() {
 			case <-tick.C:
 				ctx.SendChain(message.Text("猜成语，你还有15s作答时间"))
 			case <-after.C:
+				round.End(0)
 				ctx.Send(
 					message.ReplyWithMessage(ctx.Event.MessageID,
 						message.Text("猜成语超时，游戏结束...\n答案是: ", anser),
@@ -171,10 +175,14 @@ func init() {
 					logrus.Warn("更新用户习惯库时发生错误: ", err)
 				}
 				win, img, err = game(c.Event.Message.String())
+				if err != errLengthNotEnough && err != errHadGuessed && err != errUnknownWord {
+					round.Guess(c.Event.UserID)
+				}
 				switch {
 				case win:
 					tick.Stop()
 					after.Stop()
+					round.End(c.Event.UserID)
 					ctx.Send(
 						message.ReplyWithMessage(c.Event.MessageID,
 							message.ImageBytes(img),
@@ -185,6 +193,7 @@ func init() {
 				case err == errTimesRunOut:
 					tick.Stop()
 					after.Stop()
+					round.End(0)
 					ctx.Send(
 						message.ReplyWithMessage(c.Event.MessageID,
 							message.ImageBytes(img),
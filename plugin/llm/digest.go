@@ -0,0 +1,394 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+	"github.com/fumiama/cron"
+	"github.com/sirupsen/logrus"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/zbputils/chat"
+	"github.com/FloatTech/zbputils/control"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
+)
+
+const (
+	digestoff = iota
+	digestdaily
+	digestweekly
+)
+
+const (
+	// digestmaxlines 每个群最多缓存的消息条数, 超出时丢弃最早的消息
+	digestmaxlines = 3000
+	// digestlinemax 单条消息保留的最大字数
+	digestlinemax = 200
+	// placeholder 模板中消息内容的占位符, 模板不含占位符时消息附加在末尾
+	placeholder = "{messages}"
+)
+
+// 构造总结请求提示 (使用通用版省流提示词)
+// 使用反引号定义多行字符串，更清晰
+const defaulttemplate = `请对以下群聊对话进行【极简总结】。
+要求：
+1. 剔除客套与废话，直击主题。
+2. 使用 Markdown 列表格式。
+3. 按以下结构输出：
+   - 🎯 核心议题：(一句话概括)
+   - 💡 关键观点/结论：(提取3-5个重点)
+   - ✅ 下一步/待办：(如果有，明确谁做什么)
+
+群聊对话内容如下：
+`
+
+var weekdays = []string{"日", "一", "二", "三", "四", "五", "六"}
+
+func init() {
+	go func() {
+		err := dg.open(en.DataFolder() + "digest.db")
+		if err != nil {
+			logrus.Warnln("[llm] 打开定时总结数据库失败:", err)
+		}
+	}()
+	// 在规则中缓存消息, 避免被 single 丢弃
+	en.OnMessage(zero.OnlyGroup, func(ctx *zero.Ctx) bool {
+		dg.record(ctx.Event.GroupID, ctx.Event.Sender.Name(), ctx.ExtractPlainText())
+		return false
+	}).SetBlock(false).Handle(func(*zero.Ctx) {})
+	en.OnRegex(`^开启群聊(日报|周报)\s*(?:周([一二三四五六日天])\s*)?(\d{1,2})[:：](\d{2})$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			args := ctx.State["regex_matched"].([]string)
+			h, _ := strconv.Atoi(args[3])
+			m, _ := strconv.Atoi(args[4])
+			if h > 23 || m > 59 {
+				ctx.SendChain(message.Text("ERROR: 时间格式应为 21:00"))
+				return
+			}
+			c := dg.config(ctx.Event.GroupID)
+			c.SelfID = ctx.Event.SelfID
+			c.Minute = h*60 + m
+			c.Kind = digestdaily
+			if args[1] == "周报" {
+				if args[2] == "" {
+					ctx.SendChain(message.Text("ERROR: 请指定周几推送, 如 开启群聊周报 周日 21:00"))
+					return
+				}
+				c.Kind = digestweekly
+				c.Weekday = slices.Index(weekdays, strings.ReplaceAll(args[2], "天", "日"))
+			}
+			err := dg.save(&c)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("已开启, 将", c.String()))
+		})
+	en.OnRegex(`^关闭群聊(日报|周报)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			c := dg.config(ctx.Event.GroupID)
+			c.Kind = digestoff
+			err := dg.save(&c)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("已关闭定时推送并清空缓存的消息"))
+		})
+	en.OnFullMatch("查看群聊总结推送", zero.OnlyGroup).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			c := dg.config(ctx.Event.GroupID)
+			ctx.SendChain(message.Text(c.String()))
+		})
+	en.OnPrefix("设置群聊总结模板", zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			t := strings.TrimSpace(ctx.State["args"].(string))
+			if t == "" {
+				ctx.SendChain(message.Text("ERROR: 模板不能为空"))
+				return
+			}
+			c := dg.config(ctx.Event.GroupID)
+			c.Template = t
+			err := dg.save(&c)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("成功"))
+		})
+	en.OnFullMatch("查看群聊总结模板", zero.OnlyGroup).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			ctx.SendChain(message.Text(dg.template(ctx.Event.GroupID)))
+		})
+	en.OnFullMatch("重置群聊总结模板", zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			c := dg.config(ctx.Event.GroupID)
+			c.Template = ""
+			err := dg.save(&c)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("成功"))
+		})
+}
+
+// digestcfg 群的定时总结配置与总结模板
+type digestcfg struct {
+	GrpID    int64  `db:"gid"`      // GrpID 群号
+	SelfID   int64  `db:"sid"`      // SelfID 推送的 bot
+	Kind     int    `db:"kind"`     // Kind 关闭/日报/周报
+	Weekday  int    `db:"weekday"`  // Weekday 周报在周几推送, 0 为周日
+	Minute   int    `db:"minute"`   // Minute 在当天第几分钟推送
+	Template string `db:"template"` // Template 自定义总结模板, 为空时使用默认模板
+}
+
+// String 推送时间的可读描述
+func (c *digestcfg) String() string {
+	at := fmt.Sprintf("%02d:%02d", c.Minute/60, c.Minute%60)
+	switch c.Kind {
+	case digestdaily:
+		return "每天 " + at + " 推送日报"
+	case digestweekly:
+		return "每周" + weekdays[c.Weekday] + " " + at + " 推送周报"
+	default:
+		return "未开启定时推送"
+	}
+}
+
+// spec cron 表达式
+func (c *digestcfg) spec() string {
+	if c.Kind == digestweekly {
+		return fmt.Sprintf("%d %d * * %d", c.Minute%60, c.Minute/60, c.Weekday)
+	}
+	return fmt.Sprintf("%d %d * * *", c.Minute%60, c.Minute/60)
+}
+
+// buffered 等待总结的群消息
+type buffered struct {
+	ID    int64  `db:"id"`   // ID 收到时的 unix 纳秒
+	GrpID int64  `db:"gid"`  // GrpID 群号
+	Name  string `db:"name"` // Name 发送者昵称
+	Text  string `db:"text"` // Text 纯文本内容
+}
+
+// digester 缓存群消息并定时推送总结
+type digester struct {
+	sync.RWMutex
+	db      sql.Sqlite
+	cron    *cron.Cron
+	entries map[int64]cron.EntryID
+}
+
+var dg = digester{
+	cron:    cron.New(),
+	entries: make(map[int64]cron.EntryID, 16),
+}
+
+func (d *digester) open(path string) error {
+	d.db = sql.New(path)
+	err := d.db.Open(time.Hour)
+	if err != nil {
+		return err
+	}
+	err = d.db.Create("config", &digestcfg{})
+	if err != nil {
+		return err
+	}
+	err = d.db.Create("buffer", &buffered{}, "CREATE INDEX IF NOT EXISTS idx_buffer_gid ON buffer(gid);")
+	if err != nil {
+		return err
+	}
+	cfgs, err := sql.FindAll[digestcfg](&d.db, "config", "WHERE kind > 0")
+	if err != nil && err != sql.ErrNullResult {
+		return err
+	}
+	for _, c := range cfgs {
+		err = d.schedule(c)
+		if err != nil {
+			logrus.Warnln("[llm] 注册群", c.GrpID, "的定时总结失败:", err)
+		}
+	}
+	d.cron.Start()
+	return nil
+}
+
+// config 获取群的配置, 不存在时返回空配置
+func (d *digester) config(gid int64) digestcfg {
+	d.RLock()
+	defer d.RUnlock()
+	c, err := sql.Find[digestcfg](&d.db, "config", "WHERE gid = ?", gid)
+	if err != nil {
+		return digestcfg{GrpID: gid}
+	}
+	return c
+}
+
+// template 群的总结模板
+func (d *digester) template(gid int64) string {
+	if t := d.config(gid).Template; t != "" {
+		return t
+	}
+	return defaulttemplate
+}
+
+// save 保存配置并重新注册定时
+func (d *digester) save(c *digestcfg) error {
+	d.Lock()
+	err := d.db.Insert("config", c)
+	d.Unlock()
+	if err != nil {
+		return err
+	}
+	return d.schedule(c)
+}
+
+// schedule 按配置注册或取消定时推送
+func (d *digester) schedule(c *digestcfg) error {
+	d.Lock()
+	defer d.Unlock()
+	if eid, ok := d.entries[c.GrpID]; ok {
+		d.cron.Remove(eid)
+		delete(d.entries, c.GrpID)
+	}
+	if c.Kind == digestoff {
+		return d.db.Del("buffer", "WHERE gid = ?", c.GrpID)
+	}
+	gid, sid := c.GrpID, c.SelfID
+	eid, err := d.cron.AddFunc(c.spec(), func() { d.push(gid, sid) })
+	if err != nil {
+		return err
+	}
+	d.entries[c.GrpID] = eid
+	return nil
+}
+
+// enabled 群是否开启了定时总结
+func (d *digester) enabled(gid int64) bool {
+	d.RLock()
+	_, ok := d.entries[gid]
+	d.RUnlock()
+	return ok
+}
+
+// record 缓存开启了定时总结的群的消息
+func (d *digester) record(gid int64, name, txt string) {
+	txt = strings.TrimSpace(txt)
+	if txt == "" || !d.enabled(gid) {
+		return
+	}
+	if rs := []rune(txt); len(rs) > digestlinemax {
+		txt = string(rs[:digestlinemax])
+	}
+	d.Lock()
+	defer d.Unlock()
+	err := d.db.Insert("buffer", &buffered{ID: time.Now().UnixNano(), GrpID: gid, Name: name, Text: txt})
+	if err != nil {
+		logrus.Warnln("[llm] 缓存群", gid, "消息失败:", err)
+		return
+	}
+	// 只保留最新的 digestmaxlines 条, 推送时读取的即为全部缓存
+	err = d.db.Del("buffer", "WHERE gid = ? AND id < (SELECT id FROM buffer WHERE gid = ? ORDER BY id DESC LIMIT 1 OFFSET ?)", gid, gid, digestmaxlines-1)
+	if err != nil {
+		logrus.Warnln("[llm] 清理群", gid, "过早的缓存消息失败:", err)
+	}
+}
+
+// push 总结缓存的消息并推送到群
+func (d *digester) push(gid, sid int64) {
+	ctx := zero.GetBot(sid)
+	if ctx == nil {
+		zero.RangeBot(func(id int64, c *zero.Ctx) bool {
+			ctx, sid = c, id
+			return false
+		})
+	}
+	if ctx == nil {
+		logrus.Warnln("[llm] 推送群", gid, "的定时总结失败: 没有可用的 bot")
+		return
+	}
+	if !en.IsEnabledIn(gid) {
+		return
+	}
+	if err := usage.Check(gid, 0, false); err != nil {
+		logrus.Infoln("[llm] 跳过群", gid, "的定时总结:", err)
+		return
+	}
+	d.RLock()
+	msgs, err := sql.FindAll[buffered](&d.db, "buffer", "WHERE gid = ? ORDER BY id DESC LIMIT ?", gid, digestmaxlines)
+	d.RUnlock()
+	if err != nil {
+		if err != sql.ErrNullResult {
+			logrus.Warnln("[llm] 读取群", gid, "缓存的消息失败:", err)
+		}
+		return
+	}
+	cutoff := msgs[0].ID
+	lines := make([]string, len(msgs))
+	for i, m := range msgs {
+		lines[len(msgs)-1-i] = m.Name + ": " + m.Text
+	}
+	temp := chat.Storage(0).Temp()
+	if c, ok := control.Lookup("llm"); ok {
+		temp = chat.Storage(c.GetData(gid)).Temp()
+	}
+	summary, err := llmchat(buildprompt(d.template(gid), lines), temp, gid, 0)
+	if err != nil {
+		logrus.Warnln("[llm] 总结群", gid, "的消息失败:", err)
+		return
+	}
+	title := "日报"
+	if d.config(gid).Kind == digestweekly {
+		title = "周报"
+	}
+	head := fmt.Sprintf("群聊%s (%s, 共 %d 条消息):\n\n", title, time.Now().Format("2006-01-02"), len(lines))
+	name := zero.BotConfig.NickName[0]
+	msg := make(message.Message, 0, 4)
+	for _, s := range splitreply(head + summary) {
+		msg = append(msg, message.CustomNode(name, sid, message.Message{message.Text(s)}))
+	}
+	if id := ctx.SendGroupForwardMessage(gid, msg).Get("message_id").Int(); id == 0 {
+		logrus.Warnln("[llm] 推送群", gid, "的定时总结失败")
+		return
+	}
+	d.Lock()
+	err = d.db.Del("buffer", "WHERE gid = ? AND id <= ?", gid, cutoff)
+	d.Unlock()
+	if err != nil {
+		logrus.Warnln("[llm] 清理群", gid, "缓存的消息失败:", err)
+	}
+}
+
+// buildprompt 将消息填入模板
+func buildprompt(template string, lines []string) string {
+	msgs := strings.Join(lines, "\n")
+	if strings.Contains(template, placeholder) {
+		return strings.ReplaceAll(template, placeholder, msgs)
+	}
+	return template + msgs
+}
+
+// splitreply 按1000字符长度切割, 尽量在换行处分割
+func splitreply(s string) []string {
+	chunks := make([]string, 0, len(s)/1000+1)
+	for len(s) > 0 {
+		if len(s) <= 1000 {
+			chunks = append(chunks, s)
+			break
+		}
+		chunk := s[:1000]
+		if i := strings.LastIndex(chunk, "\n"); i > 0 {
+			chunk = s[:i+1]
+		}
+		chunks = append(chunks, chunk)
+		s = s[len(chunk):]
+	}
+	return chunks
+}
@@ -18,6 +18,9 @@ import (
 	"github.com/FloatTech/zbputils/chat"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/provider"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
 )
 
 var (
@@ -26,7 +29,16 @@ var (
 		DisableOnDefault: false,
 		Brief:            "大模型聊天和群聊总结",
 		Help: "- 群聊总结 [消息数目]|群聊总结 1000\n" +
-			"- /gpt [内容] （使用大模型聊天）\n",
+			"- /gpt [内容] （使用大模型聊天）\n" +
+			"- 开启群聊日报 21:00\n" +
+			"- 开启群聊周报 周日 21:00\n" +
+			"- 关闭群聊(日报|周报)\n" +
+			"- 查看群聊总结推送\n" +
+			"- 设置群聊总结模板 [模板]\n" +
+			"- 查看群聊总结模板\n" +
+			"- 重置群聊总结模板\n" +
+			"Tips: 开启后会缓存本群消息并在设定时间推送总结, 模板中的 {messages} 会被替换为消息内容, 没有时附加在末尾",
+		PrivateDataFolder: "llm",
 	}).ApplySingle(single.New(
 		single.WithKeyFn(func(ctx *zero.Ctx) int64 {
 			if ctx.Event.GroupID == 0 {
@@ -45,11 +57,15 @@ var (
 func init() {
 	// 添加群聊总结功能
 	en.OnRegex(`^群聊总结\s?(\d*)$`, chat.EnsureConfig, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Limit(limit.LimitByGroup).Handle(func(ctx *zero.Ctx) {
-		ctx.SendChain(message.Text("少女思考中..."))
 		gid := ctx.Event.GroupID
 		if gid == 0 {
 			gid = -ctx.Event.UserID
 		}
+		if err := usage.Check(gid, ctx.Event.UserID, false); err != nil {
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(err))
+			return
+		}
+		ctx.SendChain(message.Text("少女思考中..."))
 		p, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[1], 10, 64)
 		if p > 1000 {
 			p = 1000
@@ -80,20 +96,7 @@ func init() {
 			return
 		}
 
-		// 构造总结请求提示 (使用通用版省流提示词)
-		// 使用反引号定义多行字符串，更清晰
-		promptTemplate := `请对以下群聊对话进行【极简总结】。
-要求：
-1. 剔除客套与废话，直击主题。
-2. 使用 Markdown 列表格式。
-3. 按以下结构输出：
-   - 🎯 核心议题：(一句话概括)
-   - 💡 关键观点/结论：(提取3-5个重点)
-   - ✅ 下一步/待办：(如果有，明确谁做什么)
-
-群聊对话内容如下：
-`
-		summaryPrompt := promptTemplate + strings.Join(messages, "\n")
+		summaryPrompt := buildprompt(dg.template(gid), messages)
 
 		stor, err := chat.NewStorage(ctx, gid)
 		if err != nil {
@@ -101,7 +104,7 @@ func init() {
 			return
 		}
 		// 调用大模型API进行总结
-		summary, err := llmchat(summaryPrompt, stor.Temp())
+		summary, err := llmchat(summaryPrompt, stor.Temp(), gid, ctx.Event.UserID)
 
 		if err != nil {
 			ctx.SendChain(message.Text("ERROR: ", err))
@@ -119,23 +122,9 @@ func init() {
 		b.WriteString(summary)
 
 		// 分割总结内容为多段（按1000字符长度切割）
-		summaryText := b.String()
 		msg := make(message.Message, 0)
-		for len(summaryText) > 0 {
-			if len(summaryText) <= 1000 {
-				msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Text(summaryText)))
-				break
-			}
-
-			// 查找1000字符内的最后一个换行符，尽量在换行处分割
-			chunk := summaryText[:1000]
-			lastNewline := strings.LastIndex(chunk, "\n")
-			if lastNewline > 0 {
-				chunk = summaryText[:lastNewline+1]
-			}
-
+		for _, chunk := range splitreply(b.String()) {
 			msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Text(chunk)))
-			summaryText = summaryText[len(chunk):]
 		}
 		if len(msg) > 0 {
 			ctx.Send(msg)
@@ -195,8 +184,12 @@ func init() {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return
 		}
+		if err := usage.Check(gid, ctx.Event.UserID, false); err != nil {
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(err))
+			return
+		}
 		// 调用大模型API进行聊天
-		reply, err := llmchat(query, stor.Temp())
+		reply, err := llmchat(query, stor.Temp(), gid, ctx.Event.UserID)
 		if err != nil {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return
@@ -204,21 +197,8 @@ func init() {
 
 		// 分割总结内容为多段（按1000字符长度切割）
 		msg := make(message.Message, 0)
-		for len(reply) > 0 {
-			if len(reply) <= 1000 {
-				msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Text(reply)))
-				break
-			}
-
-			// 查找1000字符内的最后一个换行符，尽量在换行处分割
-			chunk := reply[:1000]
-			lastNewline := strings.LastIndex(chunk, "\n")
-			if lastNewline > 0 {
-				chunk = reply[:lastNewline+1]
-			}
-
+		for _, chunk := range splitreply(reply) {
 			msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Text(chunk)))
-			reply = reply[len(chunk):]
 		}
 		if len(msg) > 0 {
 			ctx.Send(msg)
@@ -226,18 +206,14 @@ func init() {
 	})
 }
 
-// llmchat 调用大模型API包装
-func llmchat(prompt string, temp float32) (string, error) {
+// llmchat 调用大模型API包装, 用量计入 gid 与 uid
+func llmchat(prompt string, temp float32, gid, uid int64) (string, error) {
 	topp, maxn := chat.AC.MParams()
 
-	x := deepinfra.NewAPI(chat.AC.API, string(chat.AC.Key))
-
-	mod, err := chat.AC.Type.Protocol(chat.AC.ModelName, temp, topp, maxn)
-	if err != nil {
-		return "", nil
-	}
-
-	data, err := x.Request(mod.User(model.NewContentText(prompt)))
+	m := provider.Meter{GrpID: gid, UserID: uid, Plugin: "llm"}
+	data, err := provider.Request(m, temp, topp, maxn, func(p model.Protocol) deepinfra.Model {
+		return p.User(model.NewContentText(prompt))
+	})
 	if err != nil {
 		return "", err
 	}
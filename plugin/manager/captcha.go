@@ -0,0 +1,211 @@
+package manager
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg"
+	"github.com/FloatTech/gg/factory"
+	"github.com/FloatTech/zbputils/control"
+	"github.com/FloatTech/zbputils/img/text"
+)
+
+// 验证方式
+const (
+	captchaMath = iota
+	captchaImage
+	captchaQA
+)
+
+// 验证失败的处理
+const (
+	punishKick = iota
+	punishBlock
+)
+
+var (
+	captchaModes   = [...]string{"算术", "图片", "问答"}
+	captchaPunishs = [...]string{"踢出", "拉黑"}
+	// punishPhrases 提示新成员时的说法
+	punishPhrases = [...]string{"踢出去", "踢出去并拉黑"}
+)
+
+// captchaChars 去除了易混淆字符的验证码字符集
+const captchaChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// captchacfg 入群验证设置
+type captchacfg struct {
+	GrpID    int64  `db:"gid"`
+	Mode     int    `db:"mode"`     // Mode 验证方式
+	Timeout  int64  `db:"timeout"`  // Timeout 答题时限(秒)
+	Retry    int    `db:"retry"`    // Retry 可答错次数
+	Punish   int    `db:"punish"`   // Punish 验证失败的处理
+	Question string `db:"question"` // Question 自定义问题
+	Answer   string `db:"answer"`   // Answer 自定义答案
+}
+
+// getcaptchacfg 获取本群入群验证设置, 未设置时返回默认值
+func getcaptchacfg(gid int64) (cfg captchacfg) {
+	err := db.Find("captcha", &cfg, "WHERE gid = ?", gid)
+	if err != nil {
+		cfg = captchacfg{GrpID: gid, Mode: captchaMath, Timeout: 60, Retry: 3, Punish: punishKick}
+	}
+	return
+}
+
+func (cfg *captchacfg) save() error {
+	return db.Insert("captcha", cfg)
+}
+
+// String 设置的可读描述, 会发到群里, 因此不含答案
+func (cfg *captchacfg) String() string {
+	s := fmt.Sprintf("验证方式: %s\n答题时限: %d秒\n可答错次数: %d\n失败处理: %s",
+		captchaModes[cfg.Mode], cfg.Timeout, cfg.Retry, captchaPunishs[cfg.Punish])
+	if cfg.Question != "" {
+		s += "\n自定义问题: " + cfg.Question
+	}
+	return s
+}
+
+// challenge 生成题目与答案, 无法生成时退回算术题
+func (cfg *captchacfg) challenge() (prompt message.Segment, answer string) {
+	switch cfg.Mode {
+	case captchaQA:
+		if cfg.Question != "" {
+			return message.Text("请回答：", cfg.Question), cfg.Answer
+		}
+	case captchaImage:
+		code := make([]byte, 4)
+		for i := range code {
+			code[i] = captchaChars[rand.Intn(len(captchaChars))]
+		}
+		data, err := drawcaptcha(string(code))
+		if err == nil {
+			return message.ImageBytes(data), string(code)
+		}
+	}
+	a := rand.Intn(100)
+	b := rand.Intn(100)
+	return message.Text(fmt.Sprintf("考你一道题：%d+%d=?", a, b)), strconv.Itoa(a + b)
+}
+
+// drawcaptcha 绘制带干扰线的验证码图片
+func drawcaptcha(code string) ([]byte, error) {
+	fontdata, err := file.GetLazyData(text.BoldFontFile, control.Md5File, true)
+	if err != nil {
+		return nil, err
+	}
+	const step = 60.0
+	w, h := int(step)*len(code)+40, 100
+	canvas := gg.NewContext(w, h)
+	canvas.SetRGB255(240, 240, 240)
+	canvas.Clear()
+	for i := 0; i < 8; i++ {
+		canvas.SetRGB255(rand.Intn(200), rand.Intn(200), rand.Intn(200))
+		canvas.SetLineWidth(1 + rand.Float64()*2)
+		canvas.DrawLine(rand.Float64()*float64(w), rand.Float64()*float64(h), rand.Float64()*float64(w), rand.Float64()*float64(h))
+		canvas.Stroke()
+	}
+	if err = canvas.ParseFontFace(fontdata, 56); err != nil {
+		return nil, err
+	}
+	for i, ch := range code {
+		x := 20 + step/2 + step*float64(i)
+		y := float64(h) / 2
+		canvas.Push()
+		canvas.RotateAbout(gg.Radians(float64(rand.Intn(50)-25)), x, y)
+		canvas.SetRGB255(rand.Intn(128), rand.Intn(128), rand.Intn(128))
+		canvas.DrawStringAnchored(string(ch), x, y, 0.5, 0.5)
+		canvas.Pop()
+	}
+	for i := 0; i < 120; i++ {
+		canvas.SetRGB255(rand.Intn(256), rand.Intn(256), rand.Intn(256))
+		canvas.DrawPoint(rand.Float64()*float64(w), rand.Float64()*float64(h), 1)
+		canvas.Fill()
+	}
+	return factory.ToBytes(canvas.Image())
+}
+
+// isattempt 消息是否像是在作答, 闲聊不计入答错次数
+func (cfg *captchacfg) isattempt(answer, ans string) bool {
+	isdigits := func(s string) bool {
+		for _, r := range s {
+			if !unicode.IsDigit(r) {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case isdigits(ans):
+		return isdigits(answer) && len(answer) <= len(ans)+2
+	case cfg.Mode == captchaImage:
+		if len(answer) != len(ans) {
+			return false
+		}
+		for _, r := range strings.ToUpper(answer) {
+			if !strings.ContainsRune(captchaChars, r) {
+				return false
+			}
+		}
+		return true
+	default:
+		return utf8.RuneCountInString(answer) <= 2*utf8.RuneCountInString(ans)
+	}
+}
+
+// verifynewmember 对新成员进行入群验证
+func verifynewmember(ctx *zero.Ctx, uid int64) {
+	cfg := getcaptchacfg(ctx.Event.GroupID)
+	prompt, ans := cfg.challenge()
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	ctx.SendChain(message.At(uid), prompt, message.Text(fmt.Sprintf("\n如果%d秒之内答不上来，%s就要把你%s了哦~", cfg.Timeout, zero.BotConfig.NickName[0], punishPhrases[cfg.Punish])))
+	failed := make(chan struct{}, 1)
+	var wrong int32
+	// 匹配发送者进行验证
+	rule := func(ctx *zero.Ctx) bool {
+		answer := strings.ReplaceAll(ctx.ExtractPlainText(), " ", "")
+		if answer == "" {
+			return false
+		}
+		if strings.EqualFold(answer, ans) {
+			return true
+		}
+		if !cfg.isattempt(answer, ans) {
+			return false
+		}
+		n := atomic.AddInt32(&wrong, 1)
+		if cfg.Retry > 0 && int(n) >= cfg.Retry {
+			select {
+			case failed <- struct{}{}:
+			default:
+			}
+			return false
+		}
+		ctx.SendChain(message.Text("答案不对哦，再想想吧~"))
+		return false
+	}
+	next := zero.NewFutureEvent("message", 999, false, ctx.CheckSession(), rule)
+	recv, cancel := next.Repeat()
+	defer cancel()
+	select {
+	case <-time.After(timeout):
+		ctx.SendChain(message.Text("时间到了，拜拜啦~"))
+	case <-failed:
+		ctx.SendChain(message.Text("答错太多次啦，拜拜~"))
+	case <-recv:
+		ctx.SendChain(message.Text("答对啦~"))
+		return
+	}
+	ctx.SetThisGroupKick(uid, cfg.Punish == punishBlock)
+}
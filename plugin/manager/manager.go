@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,19 +15,21 @@ import (
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/message"
 
+	"github.com/FloatTech/floatbox/binary"
 	"github.com/FloatTech/floatbox/math"
 	"github.com/FloatTech/floatbox/process"
 	sql "github.com/FloatTech/sqlite"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
+	"github.com/FloatTech/zbputils/img/text"
 
 	"github.com/FloatTech/ZeroBot-Plugin/plugin/manager/timer"
 )
 
 const (
 	hint = "====群管====\n" +
-		"- 禁言@QQ 1分钟\n" +
+		"- 禁言@QQ 1分钟 [理由]\n" +
 		"- 解除禁言 @QQ\n" +
 		"- 我要自闭 1分钟\n" +
 		"- 开启全员禁言\n" +
@@ -36,8 +39,8 @@ const (
 		"- 修改名片@QQ XXX\n" +
 		"- 修改头衔@QQ XXX\n" +
 		"- 申请头衔 XXX\n" +
-		"- 对信息回复: 撤回\n" +
-		"- 踢出群聊@QQ\n" +
+		"- 对信息回复: 撤回 [理由]\n" +
+		"- 踢出群聊@QQ [理由]\n" +
 		"- 退出群聊 1234@bot\n" +
 		"- 群聊转发 1234 XXX\n" +
 		"- 私聊转发 0000 XXX\n" +
@@ -48,6 +51,10 @@ const (
 		"- 在\"cron\"时(用[url])提醒大家[xxx]\n" +
 		"- 取消在\"cron\"的提醒\n" +
 		"- 列出所有提醒\n" +
+		"- [10分钟后 | 明天下午3点 | 每个工作日9点 | 下周一](私聊)提醒[我 | 大家]XXX(重复N次)(每隔N分钟)\n" +
+		"- 我的提醒\n" +
+		"- 取消我的提醒 [序号]\n" +
+		"- 稍后提醒 [N分钟]\n" +
 		"- 翻牌\n" +
 		"- 赞我\n" +
 		"- 群签到\n" +
@@ -57,9 +64,17 @@ const (
 		"- 设置告别辞 参数同设置欢迎语\n" +
 		"- 测试告别辞\n" +
 		"- [开启 | 关闭]入群验证\n" +
-		"- 对信息回复: [设置 | 取消]精华\n" +
+		"- 设置入群验证方式 [算术 | 图片 | 问答]\n" +
+		"- 设置入群验证问答 XXX 答案 XXX\n" +
+		"- 设置入群验证时限 60秒\n" +
+		"- 设置入群验证次数 3\n" +
+		"- 设置入群验证失败[踢出 | 拉黑]\n" +
+		"- 查看入群验证 (问答的答案私聊发送)\n" +
+		"- 对信息回复: [设置 | 取消]精华 [理由]\n" +
 		"- 取消精华 [信息ID]\n" +
 		"- /精华列表\n" +
+		"- 查看处罚记录@QQ\n" +
+		"- 群管日志 [天数]\n" +
 		"Tips: {at}可在发送时艾特被欢迎者 {nickname}是被欢迎者名字 {avatar}是被欢迎者头像 {uid}是被欢迎者QQ号 {gid}是当前群群号 {groupname} 是当前群群名"
 )
 
@@ -95,6 +110,14 @@ func init() { // 插件主体
 		if err != nil {
 			panic(err)
 		}
+		err = db.Create("modcase", &modcase{})
+		if err != nil {
+			panic(err)
+		}
+		err = db.Create("captcha", &captchacfg{})
+		if err != nil {
+			panic(err)
+		}
 	}()
 
 	// 升为管理
@@ -124,16 +147,12 @@ func init() { // 插件主体
 			ctx.SendChain(message.Text("残念~ " + nickname + " 暂时失去了管理员的资格"))
 		})
 	// 踢出群聊
-	engine.OnRegex(`^踢出群聊.*?(\d+)`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+	engine.OnRegex(`^踢出群聊.*?(\d+)\S*\s*(.*)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
-			ctx.SetThisGroupKick(
-				math.Str2Int64(ctx.State["regex_matched"].([]string)[1]), // 被踢出群聊的人的qq
-				false,
-			)
-			nickname := ctx.GetThisGroupMemberInfo( // 被踢出群聊的人的昵称
-				math.Str2Int64(ctx.State["regex_matched"].([]string)[1]), // 被踢出群聊的人的qq
-				false,
-			).Get("nickname").Str
+			uid := math.Str2Int64(ctx.State["regex_matched"].([]string)[1])        // 被踢出群聊的人的qq
+			nickname := ctx.GetThisGroupMemberInfo(uid, false).Get("nickname").Str // 被踢出群聊的人的昵称
+			ctx.SetThisGroupKick(uid, false)
+			logcase(ctx, uid, "踢出群聊", "", ctx.State["regex_matched"].([]string)[2])
 			ctx.SendChain(message.Text("残念~ " + nickname + " 被放逐"))
 		})
 	// 退出群聊
@@ -157,7 +176,7 @@ func init() { // 插件主体
 			ctx.SendChain(message.Text("全员自闭结束~"))
 		})
 	// 禁言
-	engine.OnMessage(zero.NewPattern(nil).Text("^禁言").At().Text("(\\d+)\\s*(.*)").AsRule(), zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+	engine.OnMessage(zero.NewPattern(nil).Text("^禁言").At().Text("(\\d+)\\s*(分钟|小时|天)?\\s*(.*)").AsRule(), zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
 			parsed := ctx.State[zero.KeyPattern].([]zero.PatternParsed)
 			duration := math.Str2Int64(parsed[2].Text()[1])
@@ -178,15 +197,17 @@ func init() { // 插件主体
 				math.Str2Int64(parsed[1].At()), // 要禁言的人的qq
 				duration*60,                    // 要禁言的时间（分钟）
 			)
+			logcase(ctx, math.Str2Int64(parsed[1].At()), "禁言", strconv.FormatInt(duration, 10)+"分钟", parsed[2].Text()[3])
 			ctx.SendChain(message.Text("小黑屋收留成功~"))
 		})
 	// 解除禁言
-	engine.OnRegex(`^解除禁言.*?(\d+)`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+	engine.OnRegex(`^解除禁言.*?(\d+)\S*\s*(.*)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
 			ctx.SetThisGroupBan(
 				math.Str2Int64(ctx.State["regex_matched"].([]string)[1]), // 要解除禁言的人的qq
 				0,
 			)
+			logcase(ctx, math.Str2Int64(ctx.State["regex_matched"].([]string)[1]), "解除禁言", "", ctx.State["regex_matched"].([]string)[2])
 			ctx.SendChain(message.Text("小黑屋释放成功~"))
 		})
 	// 自闭禁言
@@ -260,12 +281,15 @@ func init() { // 插件主体
 			ctx.SendChain(message.Text("嗯！不错的头衔呢~"))
 		})
 	// 撤回
-	// 群聊中直接回复消息结尾带上撤回
+	// 群聊中直接回复消息, 以撤回开头, 后面可跟理由
 	// 权限够的话，可以把请求撤回的消息也一并撤回
-	engine.OnRegex(`^\[CQ:reply,id=(-?\d+)\].*撤回$`, zero.AdminPermission, zero.OnlyGroup).SetBlock(true).
+	engine.OnRegex(`^\[CQ:reply,id=(-?\d+)\]\s*(?:\[CQ:at[^\]]*\]\s*)*撤回\s*(.*)$`, zero.AdminPermission, zero.OnlyGroup).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
+			// 撤回前记录原消息
+			uid, digest := msgsender(ctx, math.Str2Int64(ctx.State["regex_matched"].([]string)[1]))
 			// 删除需要撤回的消息ID
 			ctx.DeleteMessage(ctx.State["regex_matched"].([]string)[1])
+			logcase(ctx, uid, "撤回", digest, ctx.State["regex_matched"].([]string)[2])
 		})
 	// 群聊转发
 	engine.OnRegex(`^群聊转发.*?(\d+)\s(.*)`, zero.SuperUserPermission).SetBlock(true).
@@ -359,6 +383,109 @@ func init() { // 插件主体
 		Handle(func(ctx *zero.Ctx) {
 			ctx.SendChain(message.Text(clock.ListTimers(ctx.Event.GroupID)))
 		})
+	// 自然语言提醒
+	engine.OnRegex(`^(\S+?)(私聊)?提醒(我|大家)\s*(.+)$`, func(ctx *zero.Ctx) bool {
+		// 无法理解的时间表达式不做响应, 以免打扰普通聊天
+		sch, err := timer.ParseSchedule(ctx.State["regex_matched"].([]string)[1], time.Now())
+		if err == timer.ErrUnknownExpr {
+			return false
+		}
+		ctx.State["schedule"] = sch
+		ctx.State["schedule_err"] = err
+		return true
+	}).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			matched := ctx.State["regex_matched"].([]string)
+			sch := ctx.State["schedule"].(timer.Schedule)
+			err, _ := ctx.State["schedule_err"].(error)
+			if err != nil {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: ", err))
+				return
+			}
+			alert, times, interval := timer.ParseRepeat(matched[4])
+			if alert == "" {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: 提醒内容不能为空"))
+				return
+			}
+			r := &timer.Reminder{
+				SelfID: ctx.Event.SelfID,
+				GrpID:  ctx.Event.GroupID,
+				UserID: ctx.Event.UserID,
+				Owner:  ctx.Event.UserID,
+				Alert:  alert,
+				Cron:   sch.Cron,
+				Next:   sch.Next.Unix(),
+				Remain: int64(times),
+			}
+			if sch.Cron == "" && times > 1 {
+				if interval <= 0 {
+					interval = 5 * time.Minute
+				}
+				r.Interval = int64(interval / time.Second)
+			}
+			switch {
+			case matched[3] == "大家":
+				if ctx.Event.GroupID == 0 || !zero.AdminPermission(ctx) {
+					ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: 只有群管理才能提醒大家"))
+					return
+				}
+				r.UserID = 0
+			case matched[2] != "":
+				r.GrpID = 0
+			}
+			err = clock.AddReminder(r)
+			if err != nil {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("记住了~将在", reminderinfo(r)))
+		})
+	// 列出个人提醒
+	engine.OnFullMatch("我的提醒").SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			rs := clock.ListReminders(ctx.Event.UserID)
+			if len(rs) == 0 {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("你还没有设置提醒哦~"))
+				return
+			}
+			var sb strings.Builder
+			sb.WriteString("你的提醒:")
+			for i := range rs {
+				sb.WriteString(fmt.Sprintf("\n%d. ", i+1))
+				sb.WriteString(reminderinfo(&rs[i]))
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(sb.String()))
+		})
+	// 取消个人提醒
+	engine.OnRegex(`^取消我的提醒\s*(\d+)$`).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			i, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+			rs := clock.ListReminders(ctx.Event.UserID)
+			if i <= 0 || i > len(rs) {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: ", timer.ErrNoSuchReminder))
+				return
+			}
+			err := clock.CancelReminder(rs[i-1].ID)
+			if err != nil {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("取消成功~"))
+		})
+	// 推迟最近一次个人提醒
+	engine.OnRegex(`^稍后提醒\s*(\d*)\s*(分钟)?$`).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			n, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+			if n <= 0 {
+				n = 10
+			}
+			r, err := clock.SnoozeReminder(ctx.Event.UserID, time.Duration(n)*time.Minute)
+			if err != nil {
+				ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("好的~将在", reminderinfo(r)))
+		})
 	// 随机点名
 	engine.OnFullMatchGroup([]string{"翻牌"}, zero.OnlyGroup).SetBlock(true).Limit(ctxext.LimitByUser).
 		Handle(func(ctx *zero.Ctx) {
@@ -460,39 +587,7 @@ func init() { // 插件主体
 				if ok {
 					enable := c.GetData(ctx.Event.GroupID)&1 == 1
 					if enable {
-						uid := ctx.Event.UserID
-						a := rand.Intn(100)
-						b := rand.Intn(100)
-						r := a + b
-						ctx.SendChain(message.At(uid), message.Text(fmt.Sprintf("考你一道题：%d+%d=?\n如果60秒之内答不上来，%s就要把你踢出去了哦~", a, b, zero.BotConfig.NickName[0])))
-						// 匹配发送者进行验证
-						rule := func(ctx *zero.Ctx) bool {
-							for _, elem := range ctx.Event.Message {
-								if elem.Type == "text" {
-									text := strings.ReplaceAll(elem.Data["text"], " ", "")
-									ans, err := strconv.Atoi(text)
-									if err == nil {
-										if ans != r {
-											ctx.SendChain(message.Text("答案不对哦，再想想吧~"))
-											return false
-										}
-										return true
-									}
-								}
-							}
-							return false
-						}
-						next := zero.NewFutureEvent("message", 999, false, ctx.CheckSession(), rule)
-						recv, cancel := next.Repeat()
-						select {
-						case <-time.After(time.Minute):
-							cancel()
-							ctx.SendChain(message.Text("拜拜啦~"))
-							ctx.SetThisGroupKick(uid, false)
-						case <-recv:
-							cancel()
-							ctx.SendChain(message.Text("答对啦~"))
-						}
+						verifynewmember(ctx, ctx.Event.UserID)
 					}
 				}
 			}
@@ -591,6 +686,81 @@ func init() { // 插件主体
 			}
 			ctx.SendChain(message.Text("找不到服务!"))
 		})
+	// 入群验证设置
+	engine.OnRegex(`^设置入群验证(方式|问答|时限|次数|失败)\s*(.+)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			option := ctx.State["regex_matched"].([]string)[1]
+			arg := strings.TrimSpace(ctx.State["regex_matched"].([]string)[2])
+			cfg := getcaptchacfg(ctx.Event.GroupID)
+			switch option {
+			case "方式":
+				i := slices.Index(captchaModes[:], arg)
+				if i < 0 {
+					ctx.SendChain(message.Text("ERROR: 验证方式只能是 算术 | 图片 | 问答"))
+					return
+				}
+				if i == captchaQA && cfg.Question == "" {
+					ctx.SendChain(message.Text("ERROR: 请先设置入群验证问答"))
+					return
+				}
+				cfg.Mode = i
+			case "问答":
+				q, a, ok := strings.Cut(arg, "答案")
+				q, a = strings.TrimSpace(q), strings.TrimSpace(a)
+				if !ok || q == "" || a == "" {
+					ctx.SendChain(message.Text("ERROR: 格式为 设置入群验证问答 问题 答案 答案"))
+					return
+				}
+				cfg.Question, cfg.Answer, cfg.Mode = q, a, captchaQA
+			case "时限":
+				n, _, inmin := strings.Cut(arg, "分")
+				t, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(n, "秒")), 10, 64)
+				if err != nil || t <= 0 {
+					ctx.SendChain(message.Text("ERROR: 请输入正确的时限"))
+					return
+				}
+				if inmin {
+					t *= 60
+				}
+				if t > 3600 {
+					t = 3600
+				}
+				cfg.Timeout = t
+			case "次数":
+				n, err := strconv.Atoi(strings.TrimSuffix(arg, "次"))
+				if err != nil || n < 0 {
+					ctx.SendChain(message.Text("ERROR: 请输入正确的次数, 0 为不限"))
+					return
+				}
+				cfg.Retry = n
+			case "失败":
+				i := slices.Index(captchaPunishs[:], arg)
+				if i < 0 {
+					ctx.SendChain(message.Text("ERROR: 失败处理只能是 踢出 | 拉黑"))
+					return
+				}
+				cfg.Punish = i
+			}
+			err := cfg.save()
+			if err != nil {
+				ctx.SendChain(message.Text("出错啦: ", err))
+				return
+			}
+			ctx.SendChain(message.Text("记住啦!\n", cfg.String()))
+		})
+	// 查看入群验证设置
+	engine.OnFullMatch("查看入群验证", zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			cfg := getcaptchacfg(ctx.Event.GroupID)
+			status := "未开启"
+			if c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx]); ok && c.GetData(ctx.Event.GroupID)&1 == 1 {
+				status = "已开启"
+			}
+			ctx.SendChain(message.Text("入群验证", status, "\n", cfg.String()))
+			if cfg.Answer != "" {
+				ctx.SendPrivateMessage(ctx.Event.UserID, message.Text("群", ctx.Event.GroupID, "入群验证问题的答案: ", cfg.Answer))
+			}
+		})
 	// 加群 gist 验证开关
 	engine.OnRegex(`^(.*)gist加群自动审批$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
 		Handle(func(ctx *zero.Ctx) {
@@ -644,7 +814,7 @@ func init() { // 插件主体
 		}
 	})
 	// 设精
-	engine.OnRegex(`^\[CQ:reply,id=(-?\d+)\][\s\S]*(设置|取消)精华$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
+	engine.OnRegex(`^\[CQ:reply,id=(-?\d+)\]\s*(?:\[CQ:at[^\]]*\]\s*)*(设置|取消)精华\s*(.*)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
 		essenceID, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[1], 10, 64)
 		option := ctx.State["regex_matched"].([]string)[2]
 		var rsp zero.APIResponse
@@ -655,6 +825,8 @@ func init() { // 插件主体
 			rsp = ctx.DeleteGroupEssenceMessage(essenceID)
 		}
 		if rsp.RetCode == 0 {
+			uid, digest := msgsender(ctx, essenceID)
+			logcase(ctx, uid, option+"精华", digest, ctx.State["regex_matched"].([]string)[3])
 			ctx.SendChain(message.Text(option, "成功"))
 		} else {
 			ctx.SendChain(message.Text(option, "失败, 信息: ", rsp.Message, "解释: ", rsp.Wording))
@@ -697,18 +869,111 @@ func init() { // 插件主体
 		}
 	})
 	engine.OnPrefix("取消精华", zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
-		essenceID, err := strconv.ParseInt(strings.TrimSpace(ctx.State["args"].(string)), 10, 64)
+		idstr, reason, _ := strings.Cut(strings.TrimSpace(ctx.State["args"].(string)), " ")
+		essenceID, err := strconv.ParseInt(idstr, 10, 64)
 		if err != nil {
 			ctx.SendChain(message.Text("ERROR: 请输入正确的设精ID"))
 			return
 		}
 		rsp := ctx.DeleteGroupEssenceMessage(essenceID)
 		if rsp.RetCode == 0 {
+			uid, digest := msgsender(ctx, essenceID)
+			logcase(ctx, uid, "取消精华", digest, reason)
 			ctx.SendChain(message.Text("取消成功"))
 		} else {
 			ctx.SendChain(message.Text("取消失败, 信息: ", rsp.Message, "解释: ", rsp.Wording))
 		}
 	})
+	// 查看某人的处罚记录
+	engine.OnRegex(`^查看处罚记录.*?(\d+)`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
+		uid := math.Str2Int64(ctx.State["regex_matched"].([]string)[1])
+		cs, err := getcases(ctx.Event.GroupID, uid, time.Unix(0, 0))
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(cs) == 0 {
+			ctx.SendChain(message.Text(ctx.CardOrNickName(uid), "(", uid, ")还没有处罚记录哦~"))
+			return
+		}
+		n := len(cs)
+		if n > 50 {
+			ctx.SendChain(message.Text("记录太多,仅显示最近50条"))
+			n = 50
+		}
+		msg := make(message.Message, 0, n+1)
+		msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Text(ctx.CardOrNickName(uid), "(", uid, ")共有", len(cs), "条记录")))
+		for _, c := range cs[:n] {
+			msg = append(msg, ctxext.FakeSenderForwardNode(ctx, message.Text(c.String(ctx))))
+		}
+		if id := ctx.Send(msg).ID(); id == 0 {
+			ctx.SendChain(message.Text("ERROR: 可能被风控了"))
+		}
+	})
+	// 群管日志
+	engine.OnRegex(`^群管日志\s*(\d*)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).Limit(ctxext.LimitByUser).Handle(func(ctx *zero.Ctx) {
+		days, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+		if days <= 0 {
+			days = 7
+		}
+		cs, err := getcases(ctx.Event.GroupID, 0, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(cs) == 0 {
+			ctx.SendChain(message.Text("近", days, "天没有群管记录哦~"))
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString(casesummary(ctx, days, cs))
+		n := len(cs)
+		if n > 30 {
+			n = 30
+		}
+		sb.WriteString(fmt.Sprintf("\n最近%d条记录:\n", n))
+		for _, c := range cs[:n] {
+			sb.WriteString("\n")
+			sb.WriteString(c.String(ctx))
+			sb.WriteString("\n")
+		}
+		data, err := text.RenderToBase64(sb.String(), text.FontFile, 600, 20)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if id := ctx.SendChain(message.Image("base64://" + binary.BytesToString(data))); id.ID() == 0 {
+			ctx.SendChain(message.Text("ERROR: 可能被风控了"))
+		}
+	})
+}
+
+// reminderinfo 提醒的可读描述
+func reminderinfo(r *timer.Reminder) string {
+	var sb strings.Builder
+	sb.WriteString(time.Unix(r.Next, 0).Format("01月02日 15:04"))
+	if r.Cron != "" {
+		sb.WriteString("(周期: ")
+		sb.WriteString(r.Cron)
+		sb.WriteByte(')')
+	}
+	switch {
+	case r.GrpID == 0:
+		sb.WriteString(" 私聊")
+	case r.UserID == 0:
+		sb.WriteString(" 在群")
+		sb.WriteString(strconv.FormatInt(r.GrpID, 10))
+		sb.WriteString("@全体成员")
+	default:
+		sb.WriteString(" 在群")
+		sb.WriteString(strconv.FormatInt(r.GrpID, 10))
+	}
+	sb.WriteString("提醒: ")
+	sb.WriteString(r.Alert)
+	if r.Remain > 1 {
+		sb.WriteString(fmt.Sprintf(" (剩余%d次)", r.Remain))
+	}
+	return sb.String()
 }
 
 // 传入 ctx 和 welcome格式string 返回cq格式string  使用方法:welcometocq(ctx,w.Msg)
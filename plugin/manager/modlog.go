@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+
+	sql "github.com/FloatTech/sqlite"
+)
+
+// modcase 群管操作记录
+type modcase struct {
+	ID       int64  `db:"id"`     // ID 记录时的 unix 纳秒
+	GrpID    int64  `db:"gid"`    // GrpID 群号
+	Operator int64  `db:"oper"`   // Operator 操作者
+	Target   int64  `db:"target"` // Target 被操作者
+	Action   string `db:"action"` // Action 操作类型
+	Detail   string `db:"detail"` // Detail 时长、消息内容等附加信息
+	Reason   string `db:"reason"` // Reason 操作理由
+	Time     int64  `db:"time"`   // Time unix 时间
+}
+
+// logcase 将群管操作写入记录, 失败时仅提示而不影响操作本身
+func logcase(ctx *zero.Ctx, target int64, action, detail, reason string) {
+	now := time.Now()
+	c := &modcase{
+		ID:       now.UnixNano(),
+		GrpID:    ctx.Event.GroupID,
+		Operator: ctx.Event.UserID,
+		Target:   target,
+		Action:   action,
+		Detail:   detail,
+		Reason:   strings.TrimSpace(reason),
+		Time:     now.Unix(),
+	}
+	if err := db.Insert("modcase", c); err != nil {
+		ctx.SendChain(message.Text("ERROR: 记录处罚失败: ", err))
+	}
+}
+
+// getcases 获取群内某人(target 为 0 时为全体)自 since 起的记录, 按时间倒序
+func getcases(gid, target int64, since time.Time) ([]*modcase, error) {
+	q := "WHERE gid = ? AND time >= ?"
+	args := []any{gid, since.Unix()}
+	if target != 0 {
+		q += " AND target = ?"
+		args = append(args, target)
+	}
+	cs, err := sql.FindAll[modcase](&db, "modcase", q+" ORDER BY time DESC", args...)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	return cs, err
+}
+
+// String 记录的可读描述
+func (c *modcase) String(ctx *zero.Ctx) string {
+	var sb strings.Builder
+	sb.WriteString(time.Unix(c.Time, 0).Format("2006-01-02 15:04:05"))
+	sb.WriteString(" [")
+	sb.WriteString(c.Action)
+	sb.WriteString("]\n操作者: ")
+	sb.WriteString(ctx.CardOrNickName(c.Operator))
+	sb.WriteString("(" + strconv.FormatInt(c.Operator, 10) + ")")
+	if c.Target != 0 {
+		sb.WriteString("\n对象: ")
+		sb.WriteString(ctx.CardOrNickName(c.Target))
+		sb.WriteString("(" + strconv.FormatInt(c.Target, 10) + ")")
+	}
+	if c.Detail != "" {
+		sb.WriteString("\n详情: ")
+		sb.WriteString(c.Detail)
+	}
+	sb.WriteString("\n理由: ")
+	if c.Reason == "" {
+		sb.WriteString("未填写")
+	} else {
+		sb.WriteString(c.Reason)
+	}
+	return sb.String()
+}
+
+// casesummary 统计各操作与各操作者的次数
+func casesummary(ctx *zero.Ctx, days int, cs []*modcase) string {
+	actions := make(map[string]int, 8)
+	opers := make(map[int64]int, 8)
+	for _, c := range cs {
+		actions[c.Action]++
+		opers[c.Operator]++
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("近%d天共有%d条群管记录\n\n按操作:\n", days, len(cs)))
+	akeys := make([]string, 0, len(actions))
+	for k := range actions {
+		akeys = append(akeys, k)
+	}
+	sort.Slice(akeys, func(i, j int) bool { return actions[akeys[i]] > actions[akeys[j]] })
+	for _, k := range akeys {
+		sb.WriteString(fmt.Sprintf("  %s: %d次\n", k, actions[k]))
+	}
+	sb.WriteString("\n按操作者:\n")
+	okeys := make([]int64, 0, len(opers))
+	for k := range opers {
+		okeys = append(okeys, k)
+	}
+	sort.Slice(okeys, func(i, j int) bool { return opers[okeys[i]] > opers[okeys[j]] })
+	for _, k := range okeys {
+		sb.WriteString(fmt.Sprintf("  %s(%d): %d次\n", ctx.CardOrNickName(k), k, opers[k]))
+	}
+	return sb.String()
+}
+
+// msgsender 获取消息的发送者与纯文本摘要
+func msgsender(ctx *zero.Ctx, msgid int64) (uid int64, digest string) {
+	msg := ctx.GetMessage(msgid, true)
+	if msg.Sender != nil {
+		uid = msg.Sender.ID
+	}
+	digest = truncate(msg.Elements.ExtractPlainText(), 50)
+	return
+}
+
+// truncate 截取前 n 个字符
+func truncate(s string, n int) string {
+	rs := []rune(s)
+	if len(rs) <= n {
+		return s
+	}
+	return string(rs[:n]) + "..."
+}
@@ -0,0 +1,336 @@
+package timer
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fumiama/cron"
+)
+
+// Schedule 自然语言时间表达式的解析结果
+type Schedule struct {
+	// Next 首次提醒时间
+	Next time.Time
+	// Cron 非空时为周期提醒
+	Cron string
+}
+
+var (
+	// ErrUnknownExpr 无法理解的时间表达式
+	ErrUnknownExpr = errors.New("无法理解的时间表达式")
+	// ErrPassedTime 指定的时间已经过去
+	ErrPassedTime = errors.New("该时间已经过去了")
+
+	cnnum    = `[\d零一二两三四五六七八九十]+`
+	relre    = regexp.MustCompile(`^(` + cnnum + `|半)个?(秒钟?|分钟?|小时|钟头|天|周|星期|礼拜)(后|之后|以后)$`)
+	workre   = regexp.MustCompile(`^每个?工作日`)
+	weekndre = regexp.MustCompile(`^每个?周末`)
+	dailyre  = regexp.MustCompile(`^(每天|每日|每晚|每早)`)
+	weeklyre = regexp.MustCompile(`^每个?(?:周|星期|礼拜)([1-7一二三四五六日天])`)
+	monthre  = regexp.MustCompile(`^每个?月(` + cnnum + `)[号日]`)
+	dayre    = regexp.MustCompile(`^(今天|今日|今晚|明天|明日|明晚|后天|大后天)`)
+	weekre   = regexp.MustCompile(`^(下下|下|这|本)?个?(?:周|星期|礼拜)([1-7一二三四五六日天])`)
+	datere   = regexp.MustCompile(`^(` + cnnum + `)月(` + cnnum + `)[号日]`)
+	domre    = regexp.MustCompile(`^(` + cnnum + `)[号日]`)
+	periodre = regexp.MustCompile(`^(凌晨|早上|早晨|清晨|上午|中午|下午|傍晚|晚上|夜里|半夜)`)
+	clockre  = regexp.MustCompile(`^(` + cnnum + `)\s*[点时:：]\s*(半|一刻|三刻|` + cnnum + `)?分?整?$`)
+	repeatre = regexp.MustCompile(`\s*(?:[,，]?\s*重复(` + cnnum + `)次)?\s*(?:[,，]?\s*(?:每隔|间隔)(` + cnnum + `)分钟)?\s*$`)
+)
+
+// cn2int 将不超过 99 的阿拉伯或汉字数字转为 int
+func cn2int(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+	digits := map[rune]int{'零': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	rs := []rune(s)
+	n, cur := 0, -1
+	for _, r := range rs {
+		if r == '十' {
+			if cur < 0 {
+				cur = 1
+			}
+			n += cur * 10
+			cur = -1
+			continue
+		}
+		d, ok := digits[r]
+		if !ok {
+			return 0, false
+		}
+		if cur > 0 {
+			// 不支持 "二三" 这样的连写
+			return 0, false
+		}
+		cur = d
+	}
+	if cur > 0 {
+		n += cur
+	}
+	return n, true
+}
+
+// weekdayOf 将 1-7/一-日 转换为 time.Weekday
+func weekdayOf(s string) time.Weekday {
+	switch s {
+	case "日", "天", "7":
+		return time.Sunday
+	}
+	n, _ := cn2int(s)
+	return time.Weekday(n % 7)
+}
+
+// ParseRepeat 从提醒内容末尾解析 "重复N次" 与 "每隔N分钟" 选项
+func ParseRepeat(alert string) (rest string, times int, interval time.Duration) {
+	loc := repeatre.FindStringSubmatchIndex(alert)
+	if loc == nil || loc[0] == len(alert) {
+		return alert, 0, 0
+	}
+	if loc[2] >= 0 {
+		times, _ = cn2int(alert[loc[2]:loc[3]])
+	}
+	if loc[4] >= 0 {
+		n, _ := cn2int(alert[loc[4]:loc[5]])
+		interval = time.Duration(n) * time.Minute
+	}
+	return strings.TrimSpace(alert[:loc[0]]), times, interval
+}
+
+// ParseSchedule 解析如 "10分钟后" "明天下午3点" "每个工作日9点" "下周一" 的时间表达式
+func ParseSchedule(expr string, now time.Time) (s Schedule, err error) {
+	expr = strings.TrimSpace(expr)
+	expr = strings.TrimPrefix(expr, "在")
+	expr = strings.TrimSuffix(strings.TrimSuffix(expr, "的时候"), "时候")
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return s, ErrUnknownExpr
+	}
+	if m := relre.FindStringSubmatch(expr); m != nil {
+		var d time.Duration
+		switch m[2] {
+		case "秒", "秒钟":
+			d = time.Second
+		case "分", "分钟":
+			d = time.Minute
+		case "小时", "钟头":
+			d = time.Hour
+		case "天":
+			d = 24 * time.Hour
+		default:
+			d = 7 * 24 * time.Hour
+		}
+		if m[1] == "半" {
+			s.Next = now.Add(d / 2)
+			return
+		}
+		n, ok := cn2int(m[1])
+		if !ok || n <= 0 {
+			return s, ErrUnknownExpr
+		}
+		s.Next = now.Add(time.Duration(n) * d)
+		return
+	}
+
+	var (
+		dow    = "*" // 周期提醒的星期
+		dom    = "*" // 周期提醒的日期
+		repeat = false
+		date   = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		hasday = false
+		period = ""
+	)
+	dm := matchAny(expr, workre, weekndre, dailyre, weeklyre, monthre, dayre, weekre, datere, domre)
+	switch m := dm; {
+	case m == nil:
+	case m.re == workre:
+		repeat, dow = true, "1-5"
+	case m.re == weekndre:
+		repeat, dow = true, "0,6"
+	case m.re == dailyre:
+		repeat = true
+		switch m.sub[1] {
+		case "每晚":
+			period = "晚上"
+		case "每早":
+			period = "早上"
+		}
+	case m.re == weeklyre:
+		repeat, dow = true, strconv.Itoa(int(weekdayOf(m.sub[1])))
+	case m.re == monthre:
+		d, ok := cn2int(m.sub[1])
+		if !ok || d < 1 || d > 31 {
+			return s, ErrUnknownExpr
+		}
+		repeat, dom = true, strconv.Itoa(d)
+	case m.re == dayre:
+		hasday = true
+		switch m.sub[1] {
+		case "今晚":
+			period = "晚上"
+		case "明天", "明日":
+			date = date.AddDate(0, 0, 1)
+		case "明晚":
+			date, period = date.AddDate(0, 0, 1), "晚上"
+		case "后天":
+			date = date.AddDate(0, 0, 2)
+		case "大后天":
+			date = date.AddDate(0, 0, 3)
+		}
+	case m.re == weekre:
+		hasday = true
+		// 以周一为一周的开始
+		w := int(weekdayOf(m.sub[2])+6) % 7
+		today := int(now.Weekday()+6) % 7
+		date = date.AddDate(0, 0, w-today)
+		switch m.sub[1] {
+		case "下":
+			date = date.AddDate(0, 0, 7)
+		case "下下":
+			date = date.AddDate(0, 0, 14)
+		}
+	case m.re == datere:
+		mon, ok1 := cn2int(m.sub[1])
+		d, ok2 := cn2int(m.sub[2])
+		if !ok1 || !ok2 || mon < 1 || mon > 12 || d < 1 || d > 31 {
+			return s, ErrUnknownExpr
+		}
+		hasday = true
+		date = time.Date(now.Year(), time.Month(mon), d, 0, 0, 0, 0, now.Location())
+		if date.Month() != time.Month(mon) {
+			return s, ErrUnknownExpr
+		}
+	case m.re == domre:
+		d, ok := cn2int(m.sub[1])
+		if !ok || d < 1 || d > 31 {
+			return s, ErrUnknownExpr
+		}
+		hasday = true
+		date = time.Date(now.Year(), now.Month(), d, 0, 0, 0, 0, now.Location())
+	}
+	matched := repeat || hasday
+	if dm != nil {
+		expr = strings.TrimSpace(strings.TrimPrefix(expr[len(dm.sub[0]):], "的"))
+	}
+	if p := periodre.FindString(expr); p != "" {
+		period = p
+		expr = strings.TrimSpace(expr[len(p):])
+		matched = true
+	}
+
+	h, mn, hasclock := -1, 0, false
+	if expr != "" {
+		m := clockre.FindStringSubmatch(expr)
+		if m == nil {
+			return s, ErrUnknownExpr
+		}
+		var ok bool
+		h, ok = cn2int(m[1])
+		if !ok || h > 24 {
+			return s, ErrUnknownExpr
+		}
+		switch m[2] {
+		case "":
+		case "半":
+			mn = 30
+		case "一刻":
+			mn = 15
+		case "三刻":
+			mn = 45
+		default:
+			mn, ok = cn2int(m[2])
+			if !ok || mn > 59 {
+				return s, ErrUnknownExpr
+			}
+		}
+		hasclock, matched = true, true
+	}
+	if !matched {
+		return s, ErrUnknownExpr
+	}
+	if !hasclock {
+		switch period {
+		case "凌晨", "半夜":
+			h = 0
+		case "早上", "早晨", "清晨":
+			h = 8
+		case "中午":
+			h = 12
+		case "下午":
+			h = 15
+		case "傍晚":
+			h = 18
+		case "晚上", "夜里":
+			h = 20
+		default:
+			h = 9
+		}
+	}
+	switch period {
+	case "下午", "傍晚", "晚上", "夜里":
+		if h < 12 {
+			h += 12
+		}
+	case "中午":
+		if h < 11 {
+			h += 12
+		}
+	case "半夜":
+		if h == 12 {
+			h = 0
+		}
+	}
+	if h == 24 {
+		h = 0
+		date = date.AddDate(0, 0, 1)
+	}
+
+	if repeat {
+		s.Cron = strconv.Itoa(mn) + " " + strconv.Itoa(h) + " " + dom + " * " + dow
+		sched, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return s, err
+		}
+		s.Next = sched.Next(now)
+		return s, nil
+	}
+
+	s.Next = time.Date(date.Year(), date.Month(), date.Day(), h, mn, 0, 0, now.Location())
+	if !s.Next.After(now) {
+		switch {
+		case !hasday && period == "" && h < 12 && s.Next.Add(12*time.Hour).After(now):
+			// "3点" 在下午说出时理解为 15 点
+			s.Next = s.Next.Add(12 * time.Hour)
+		case !hasday:
+			s.Next = s.Next.AddDate(0, 0, 1)
+		case dm.re == weekre && dm.sub[1] == "":
+			s.Next = s.Next.AddDate(0, 0, 7)
+		case dm.re == datere:
+			s.Next = s.Next.AddDate(1, 0, 0)
+		case dm.re == domre:
+			s.Next = s.Next.AddDate(0, 1, 0)
+		default:
+			return s, ErrPassedTime
+		}
+	}
+	return s, nil
+}
+
+type reMatch struct {
+	re  *regexp.Regexp
+	sub []string
+}
+
+func matchAny(s string, res ...*regexp.Regexp) *reMatch {
+	for _, re := range res {
+		if sub := re.FindStringSubmatch(s); sub != nil {
+			return &reMatch{re: re, sub: sub}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package timer
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	sql "github.com/FloatTech/sqlite"
+	"github.com/fumiama/cron"
+	"github.com/sirupsen/logrus"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// Reminder 由自然语言创建的提醒
+type Reminder struct {
+	ID       int64  `db:"id"`
+	SelfID   int64  `db:"sid"`
+	GrpID    int64  `db:"gid"`      // GrpID 为 0 时私聊提醒
+	UserID   int64  `db:"uid"`      // UserID 为 0 时 @全体成员
+	Owner    int64  `db:"owner"`    // Owner 创建者
+	Alert    string `db:"alert"`    // Alert 提醒内容
+	Cron     string `db:"cron"`     // Cron 非空时为周期提醒
+	Next     int64  `db:"next"`     // Next 下次提醒的 unix 时间
+	Interval int64  `db:"interval"` // Interval 单次提醒重复的间隔秒数
+	Remain   int64  `db:"remain"`   // Remain 剩余提醒次数, 周期提醒为 0 时不限
+}
+
+// ErrNoSuchReminder 找不到提醒
+var ErrNoSuchReminder = errors.New("没有这个提醒哦~")
+
+// reminders 提醒集合, 以指针形式保存在 Clock 中
+type reminders struct {
+	sync.Mutex
+	db *sql.Sqlite
+	// items id <-> 提醒
+	items map[int64]*Reminder
+	// stops id <-> 取消调度
+	stops map[int64]func()
+	// fired uid <-> 最近一次触发的个人提醒, 用于稍后提醒
+	fired map[int64]Reminder
+}
+
+func (c *Clock) loadReminders(db *sql.Sqlite) {
+	c.rems = &reminders{
+		db:    db,
+		items: make(map[int64]*Reminder),
+		stops: make(map[int64]func()),
+		fired: make(map[int64]Reminder),
+	}
+	err := db.Create("reminder", &Reminder{})
+	if err != nil {
+		logrus.Warnln("[群管]创建提醒表失败:", err)
+		return
+	}
+	var r Reminder
+	_ = db.FindFor("reminder", &r, "", func() error {
+		rescape := r
+		// 错过的单次提醒在 bot 就绪后补发
+		if late := time.Now().Add(time.Minute).Unix(); rescape.Cron == "" && rescape.Next < late {
+			rescape.Next = late
+		}
+		c.rems.items[r.ID] = &rescape
+		c.scheduleReminder(&rescape)
+		return nil
+	})
+}
+
+// AddReminder 保存并调度提醒
+func (c *Clock) AddReminder(r *Reminder) error {
+	if r.ID == 0 {
+		r.ID = time.Now().UnixNano()
+	}
+	if r.Cron == "" && r.Remain <= 0 {
+		r.Remain = 1
+	}
+	c.rems.Lock()
+	err := c.rems.db.Insert("reminder", r)
+	if err == nil {
+		c.rems.items[r.ID] = r
+	}
+	c.rems.Unlock()
+	if err != nil {
+		return err
+	}
+	c.scheduleReminder(r)
+	return nil
+}
+
+// ListReminders 按下次提醒时间列出某人创建的提醒
+func (c *Clock) ListReminders(owner int64) []Reminder {
+	c.rems.Lock()
+	rs := make([]Reminder, 0, 8)
+	for _, r := range c.rems.items {
+		if r.Owner == owner {
+			rs = append(rs, *r)
+		}
+	}
+	c.rems.Unlock()
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Next < rs[j].Next })
+	return rs
+}
+
+// CancelReminder 取消提醒
+func (c *Clock) CancelReminder(id int64) error {
+	c.rems.Lock()
+	defer c.rems.Unlock()
+	if _, ok := c.rems.items[id]; !ok {
+		return ErrNoSuchReminder
+	}
+	c.removeReminderLocked(id)
+	return nil
+}
+
+// SnoozeReminder 将 uid 最近一次收到的个人提醒推迟 d 后再次提醒
+func (c *Clock) SnoozeReminder(uid int64, d time.Duration) (*Reminder, error) {
+	c.rems.Lock()
+	last, ok := c.rems.fired[uid]
+	if ok {
+		delete(c.rems.fired, uid)
+	}
+	c.rems.Unlock()
+	if !ok {
+		return nil, ErrNoSuchReminder
+	}
+	r := &Reminder{
+		SelfID: last.SelfID,
+		GrpID:  last.GrpID,
+		UserID: last.UserID,
+		Owner:  last.UserID,
+		Alert:  last.Alert,
+		Next:   time.Now().Add(d).Unix(),
+		Remain: 1,
+	}
+	return r, c.AddReminder(r)
+}
+
+func (c *Clock) removeReminderLocked(id int64) {
+	if stop, ok := c.rems.stops[id]; ok {
+		stop()
+		delete(c.rems.stops, id)
+	}
+	delete(c.rems.items, id)
+	_ = c.rems.db.Del("reminder", "WHERE id = ?", id)
+}
+
+func (c *Clock) scheduleReminder(r *Reminder) {
+	id := r.ID
+	if r.Cron != "" {
+		eid, err := c.cron.AddFunc(r.Cron, func() { c.fireReminder(id) })
+		if err != nil {
+			logrus.Warnln("[群管]注册提醒", id, "失败:", err)
+			return
+		}
+		c.rems.Lock()
+		c.rems.stops[id] = func() { c.cron.Remove(eid) }
+		c.rems.Unlock()
+		return
+	}
+	t := time.AfterFunc(time.Until(time.Unix(r.Next, 0)), func() { c.fireReminder(id) })
+	c.rems.Lock()
+	c.rems.stops[id] = func() { t.Stop() }
+	c.rems.Unlock()
+}
+
+func (c *Clock) fireReminder(id int64) {
+	c.rems.Lock()
+	r, ok := c.rems.items[id]
+	if !ok {
+		c.rems.Unlock()
+		return
+	}
+	cur := *r
+	if cur.UserID != 0 {
+		c.rems.fired[cur.UserID] = cur
+	}
+	reschedule := false
+	switch {
+	case r.Cron != "":
+		if r.Remain == 1 {
+			c.removeReminderLocked(id)
+			break
+		}
+		if r.Remain > 1 {
+			r.Remain--
+		}
+		if sched, err := cron.ParseStandard(r.Cron); err == nil {
+			r.Next = sched.Next(time.Now()).Unix()
+		}
+		_ = c.rems.db.Insert("reminder", r)
+	case r.Remain > 1 && r.Interval > 0:
+		r.Remain--
+		r.Next = time.Now().Unix() + r.Interval
+		_ = c.rems.db.Insert("reminder", r)
+		reschedule = true
+	default:
+		c.removeReminderLocked(id)
+	}
+	c.rems.Unlock()
+	if reschedule {
+		c.scheduleReminder(r)
+	}
+	cur.send()
+}
+
+func (r *Reminder) send() {
+	ctx := zero.GetBot(r.SelfID)
+	if ctx == nil {
+		zero.RangeBot(func(id int64, c *zero.Ctx) bool {
+			ctx = c
+			return false
+		})
+	}
+	if ctx == nil {
+		logrus.Warnln("[群管]提醒", r.ID, "找不到可用的bot")
+		return
+	}
+	switch {
+	case r.GrpID == 0:
+		ctx.SendPrivateMessage(r.UserID, message.Message{message.Text("⏰ ", r.Alert, "\n(发送 稍后提醒 可推迟10分钟)")})
+	case r.UserID == 0:
+		ctx.SendGroupMessage(r.GrpID, message.Message{atall, message.Text(r.Alert)})
+	default:
+		ctx.SendGroupMessage(r.GrpID, message.Message{message.At(r.UserID), message.Text(" ⏰ ", r.Alert, "\n(发送 稍后提醒 可推迟10分钟)")})
+	}
+}
@@ -24,6 +24,8 @@ type Clock struct {
 	// entries key <-> cron
 	entries map[uint32]cron.EntryID
 	entmu   sync.Mutex
+	// rems 自然语言提醒
+	rems *reminders
 }
 
 var (
@@ -42,6 +44,7 @@ func NewClock(db *sql.Sqlite) (c Clock) {
 	c.entries = make(map[uint32]cron.EntryID)
 	c.timers = &map[uint32]*Timer{}
 	c.loadTimers(db)
+	c.loadReminders(db)
 	c.cron.Start()
 	return
 }
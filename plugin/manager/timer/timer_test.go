@@ -31,3 +31,52 @@ func TestClock(t *testing.T) {
 	t.Log(c.ListTimers(0))
 	t.Fail()
 }
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2024, 5, 15, 14, 0, 0, 0, time.Local) // 周三
+	for _, c := range []struct {
+		expr string
+		next time.Time
+		cron string
+	}{
+		{"10分钟后", now.Add(10 * time.Minute), ""},
+		{"半小时后", now.Add(30 * time.Minute), ""},
+		{"两个小时后", now.Add(2 * time.Hour), ""},
+		{"明天下午3点", time.Date(2024, 5, 16, 15, 0, 0, 0, time.Local), ""},
+		{"明天早上", time.Date(2024, 5, 16, 8, 0, 0, 0, time.Local), ""},
+		{"3点半", time.Date(2024, 5, 15, 15, 30, 0, 0, time.Local), ""},
+		{"9:05", time.Date(2024, 5, 15, 21, 5, 0, 0, time.Local), ""},
+		{"下周一", time.Date(2024, 5, 20, 9, 0, 0, 0, time.Local), ""},
+		{"周一", time.Date(2024, 5, 20, 9, 0, 0, 0, time.Local), ""},
+		{"周五晚上8点", time.Date(2024, 5, 17, 20, 0, 0, 0, time.Local), ""},
+		{"5月1日", time.Date(2025, 5, 1, 9, 0, 0, 0, time.Local), ""},
+		{"每个工作日9点", time.Date(2024, 5, 16, 9, 0, 0, 0, time.Local), "0 9 * * 1-5"},
+		{"每天晚上十点", time.Date(2024, 5, 15, 22, 0, 0, 0, time.Local), "0 22 * * *"},
+		{"每周日上午十一点", time.Date(2024, 5, 19, 11, 0, 0, 0, time.Local), "0 11 * * 0"},
+		{"每月1号", time.Date(2024, 6, 1, 9, 0, 0, 0, time.Local), "0 9 1 * *"},
+	} {
+		s, err := ParseSchedule(c.expr, now)
+		if err != nil {
+			t.Fatal(c.expr, err)
+		}
+		if !s.Next.Equal(c.next) || s.Cron != c.cron {
+			t.Fatal(c.expr, "expect", c.next, c.cron, "got", s.Next, s.Cron)
+		}
+	}
+	for _, expr := range []string{"今天上午10点", "随便什么时候", ""} {
+		if _, err := ParseSchedule(expr, now); err == nil {
+			t.Fatal(expr, "should fail")
+		}
+	}
+}
+
+func TestParseRepeat(t *testing.T) {
+	rest, times, interval := ParseRepeat("喝水 重复3次 每隔十分钟")
+	if rest != "喝水" || times != 3 || interval != 10*time.Minute {
+		t.Fatal(rest, times, interval)
+	}
+	rest, times, interval = ParseRepeat("开会")
+	if rest != "开会" || times != 0 || interval != 0 {
+		t.Fatal(rest, times, interval)
+	}
+}
@@ -0,0 +1,385 @@
+// Package mcfish 钓鱼模拟器
+package mcfish
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// 限时活动, 在活动时段内调整各分类的概率
+type eventInfo struct {
+	Name     string         `json:"名称"`
+	Weekdays []int          `json:"星期,omitempty"`   // 0为周日, 为空则每天
+	Hours    []int          `json:"时段,omitempty"`   // [开始, 结束) 小时, 可跨零点, 为空则全天
+	Start    string         `json:"开始日期,omitempty"` // 2006-01-02, 为空则不限
+	Stop     string         `json:"结束日期,omitempty"` // 2006-01-02, 当天包含在内
+	Zone     map[string]int `json:"分类倍率,omitempty"` // 类型: 概率倍率(%)
+	Fish     []string       `json:"限定鱼类,omitempty"` // 只在活动期间才能钓到的鱼
+}
+
+// 鱼的尺寸与重量范围
+type sizeInfo struct {
+	Size   [2]float64 // cm
+	Weight [2]float64 // kg
+}
+
+// 个人图鉴
+type collection struct {
+	Name      string  // 物品名称
+	First     int64   // 首次钓到时间
+	Number    int     // 累计钓到数量
+	MaxSize   float64 // 最大尺寸(cm)
+	MaxWeight float64 // 最大重量(kg)
+	Duration  int64   // 最近钓到时间
+}
+
+// 群纪录
+type fishRecord struct {
+	ID       string  // 群号/鱼名
+	GrpID    int64   // 群号
+	Name     string  // 鱼名
+	UserID   int64   // 纪录保持者
+	Size     float64 // 尺寸(cm)
+	Weight   float64 // 重量(kg)
+	Duration int64   // 创造时间
+}
+
+// 一次钓获
+type catchInfo struct {
+	Name   string
+	Size   float64
+	Weight float64
+}
+
+var (
+	// 限时活动, 由 articlesInfo.json 中的"活动"定义, 默认没有活动
+	eventList []eventInfo
+	// 默认尺寸, 可在 articlesInfo.json 中用"尺寸""重量"覆盖
+	sizeList = map[string]sizeInfo{
+		"鳕鱼":  {Size: [2]float64{30, 100}, Weight: [2]float64{0.5, 12}},
+		"鲑鱼":  {Size: [2]float64{50, 120}, Weight: [2]float64{2, 25}},
+		"热带鱼": {Size: [2]float64{5, 30}, Weight: [2]float64{0.05, 1.5}},
+		"河豚":  {Size: [2]float64{10, 50}, Weight: [2]float64{0.2, 4}},
+		"鹦鹉螺": {Size: [2]float64{10, 25}, Weight: [2]float64{0.3, 2}},
+		"墨鱼":  {Size: [2]float64{15, 60}, Weight: [2]float64{0.3, 5}},
+		"海豚":  {Size: [2]float64{150, 400}, Weight: [2]float64{80, 650}},
+	}
+	zoneOrder = []string{"treasure", "pole", "fish", "waste"}
+)
+
+// 活动是否在进行中
+func (e *eventInfo) active(now time.Time) bool {
+	if len(e.Weekdays) > 0 && !slices.Contains(e.Weekdays, int(now.Weekday())) {
+		return false
+	}
+	if len(e.Hours) == 2 && e.Hours[0] != e.Hours[1] {
+		h := now.Hour()
+		if e.Hours[0] < e.Hours[1] && (h < e.Hours[0] || h >= e.Hours[1]) {
+			return false
+		}
+		if e.Hours[0] > e.Hours[1] && h < e.Hours[0] && h >= e.Hours[1] {
+			return false
+		}
+	}
+	day := now.Format("2006-01-02")
+	if e.Start != "" && day < e.Start {
+		return false
+	}
+	if e.Stop != "" && day > e.Stop {
+		return false
+	}
+	return true
+}
+
+func (e *eventInfo) describe() string {
+	var sb strings.Builder
+	sb.WriteString(e.Name)
+	if len(e.Weekdays) > 0 {
+		sb.WriteString(" 每周")
+		for _, d := range e.Weekdays {
+			sb.WriteString([]string{"日", "一", "二", "三", "四", "五", "六"}[d%7])
+		}
+	}
+	if len(e.Hours) == 2 && e.Hours[0] != e.Hours[1] {
+		sb.WriteString(" " + strconv.Itoa(e.Hours[0]) + ":00~" + strconv.Itoa(e.Hours[1]) + ":00")
+	}
+	if e.Start != "" || e.Stop != "" {
+		sb.WriteString(" " + e.Start + "~" + e.Stop)
+	}
+	for _, zone := range zoneOrder {
+		if rate, ok := e.Zone[zone]; ok {
+			sb.WriteString("\n  " + zoneName(zone) + "概率x" + strconv.FormatFloat(float64(rate)/100, 'f', -1, 64))
+		}
+	}
+	if len(e.Fish) > 0 {
+		sb.WriteString("\n  限定: " + strings.Join(e.Fish, "、"))
+	}
+	return sb.String()
+}
+
+func zoneName(zone string) string {
+	switch zone {
+	case "treasure":
+		return "宝藏"
+	case "pole":
+		return "鱼竿"
+	case "fish":
+		return "鱼类"
+	default:
+		return "垃圾"
+	}
+}
+
+// 进行中的活动名称
+func activeEvents(now time.Time) (names []string) {
+	for _, e := range eventList {
+		if e.active(now) {
+			names = append(names, e.Name)
+		}
+	}
+	return
+}
+
+// 按进行中的活动调整分类概率
+func applyEvents(localProbabilities map[string]probabilityLimit, now time.Time) {
+	rates := make(map[string]int, len(zoneOrder))
+	for _, e := range eventList {
+		if !e.active(now) {
+			continue
+		}
+		for zone, rate := range e.Zone {
+			if _, ok := rates[zone]; !ok {
+				rates[zone] = 100
+			}
+			rates[zone] = rates[zone] * rate / 100
+		}
+	}
+	if len(rates) == 0 {
+		return
+	}
+	// 各分类首尾相接, 按倍率重新排布
+	start := 0
+	for _, zone := range zoneOrder {
+		info := localProbabilities[zone]
+		width := info.Max - info.Min
+		if rate, ok := rates[zone]; ok {
+			width = width * rate / 100
+		}
+		info.Min = min(start, 100)
+		info.Max = min(start+width, 100)
+		localProbabilities[zone] = info
+		start += width
+	}
+}
+
+// 该鱼是否为当前不在时段内的活动限定鱼
+func lockedByEvent(name string, now time.Time) bool {
+	locked := false
+	for _, e := range eventList {
+		if !slices.Contains(e.Fish, name) {
+			continue
+		}
+		if e.active(now) {
+			return false
+		}
+		locked = true
+	}
+	return locked
+}
+
+// 随机生成钓获的尺寸与重量, 越大越稀有
+func newCatch(name string) catchInfo {
+	c := catchInfo{Name: name}
+	info, ok := sizeList[name]
+	if !ok {
+		return c
+	}
+	t := rand.Float64()
+	t *= t
+	c.Size = math.Round((info.Size[0]+(info.Size[1]-info.Size[0])*t)*10) / 10
+	w := info.Weight[0] + (info.Weight[1]-info.Weight[0])*t*t*t*(0.9+rand.Float64()*0.2)
+	c.Weight = math.Round(math.Min(math.Max(w, info.Weight[0]), info.Weight[1])*100) / 100
+	return c
+}
+
+func (c *catchInfo) String() string {
+	if c.Size == 0 {
+		return c.Name
+	}
+	return c.Name + "(" + strconv.FormatFloat(c.Size, 'f', 1, 64) + "cm/" + strconv.FormatFloat(c.Weight, 'f', 2, 64) + "kg)"
+}
+
+/*********************************************************/
+/************************图鉴相关函数***********************/
+/*********************************************************/
+
+// 记录钓获 number 个, 返回是否首次钓到、是否刷新个人纪录、是否打破群纪录
+func (sql *fishdb) updateCollectionFor(uid, gid int64, c catchInfo, number int) (first, personal, group bool, err error) {
+	name := strconv.FormatInt(uid, 10) + "Book"
+	sql.Lock()
+	defer sql.Unlock()
+	now := time.Now().Unix()
+	info := collection{Name: c.Name, First: now}
+	err = sql.db.Create(name, &info)
+	if err != nil {
+		return
+	}
+	first = sql.db.Find(name, &info, "WHERE Name = ?", c.Name) != nil
+	info.Number += number
+	info.Duration = now
+	if c.Size > info.MaxSize {
+		personal = !first
+		info.MaxSize = c.Size
+		info.MaxWeight = c.Weight
+	}
+	err = sql.db.Insert(name, &info)
+	if err != nil || c.Size == 0 || gid == 0 {
+		return
+	}
+	record := fishRecord{}
+	err = sql.db.Create("fishRecord", &record)
+	if err != nil {
+		return
+	}
+	id := strconv.FormatInt(gid, 10) + "/" + c.Name
+	_ = sql.db.Find("fishRecord", &record, "WHERE ID = ?", id)
+	if c.Size <= record.Size {
+		return
+	}
+	group = true
+	return first, personal, group, sql.db.Insert("fishRecord", &fishRecord{
+		ID:       id,
+		GrpID:    gid,
+		Name:     c.Name,
+		UserID:   uid,
+		Size:     c.Size,
+		Weight:   c.Weight,
+		Duration: now,
+	})
+}
+
+// 获取个人图鉴
+func (sql *fishdb) getCollectionFor(uid int64) (infos map[string]collection, err error) {
+	name := strconv.FormatInt(uid, 10) + "Book"
+	sql.Lock()
+	defer sql.Unlock()
+	info := collection{}
+	err = sql.db.Create(name, &info)
+	if err != nil {
+		return
+	}
+	infos = make(map[string]collection, 32)
+	count, err := sql.db.Count(name)
+	if err != nil || count == 0 {
+		return
+	}
+	err = sql.db.FindFor(name, &info, "", func() error {
+		infos[info.Name] = info
+		return nil
+	})
+	return
+}
+
+// 获取群纪录
+func (sql *fishdb) getGroupRecords(gid int64) (records map[string]fishRecord, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	record := fishRecord{}
+	err = sql.db.Create("fishRecord", &record)
+	if err != nil {
+		return
+	}
+	records = make(map[string]fishRecord, 8)
+	if !sql.db.CanFind("fishRecord", "WHERE GrpID = ?", gid) {
+		return
+	}
+	err = sql.db.FindFor("fishRecord", &record, "WHERE GrpID = ?", func() error {
+		records[record.Name] = record
+		return nil
+	}, gid)
+	return
+}
+
+func init() {
+	engine.OnFullMatch("钓鱼图鉴", getdb).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		infos, err := dbdata.getCollectionFor(ctx.Event.UserID)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at book.go.1]:", err))
+			return
+		}
+		var sb strings.Builder
+		count := 0
+		for _, thing := range articlesInfo.ArticleInfo {
+			info, ok := infos[thing.Name]
+			sb.WriteString("\n")
+			if !ok {
+				sb.WriteString("□ ???")
+				continue
+			}
+			count++
+			sb.WriteString("■ ")
+			sb.WriteString(thing.Name)
+			sb.WriteString(" x")
+			sb.WriteString(strconv.Itoa(info.Number))
+			sb.WriteString(" 首次:")
+			sb.WriteString(time.Unix(info.First, 0).Format("2006-01-02"))
+			if info.MaxSize > 0 {
+				sb.WriteString(" 最大:")
+				sb.WriteString(strconv.FormatFloat(info.MaxSize, 'f', 1, 64))
+				sb.WriteString("cm/")
+				sb.WriteString(strconv.FormatFloat(info.MaxWeight, 'f', 2, 64))
+				sb.WriteString("kg")
+			}
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(
+			"你的钓鱼图鉴(", count, "/", len(articlesInfo.ArticleInfo), "):", sb.String()))
+	})
+	engine.OnFullMatch("钓鱼纪录", zero.OnlyGroup, getdb).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		records, err := dbdata.getGroupRecords(ctx.Event.GroupID)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at book.go.2]:", err))
+			return
+		}
+		if len(records) == 0 {
+			ctx.SendChain(message.Text("本群还没有钓鱼纪录"))
+			return
+		}
+		msg := make(message.Message, 0, 1+len(records))
+		msg = append(msg, message.Text("本群钓鱼纪录:"))
+		for _, name := range fishList {
+			record, ok := records[name]
+			if !ok {
+				continue
+			}
+			msg = append(msg, message.Text(
+				"\n", name, " ", strconv.FormatFloat(record.Size, 'f', 1, 64), "cm/",
+				strconv.FormatFloat(record.Weight, 'f', 2, 64), "kg  ",
+				ctx.CardOrNickName(record.UserID), " ", time.Unix(record.Duration, 0).Format("2006-01-02")))
+		}
+		ctx.Send(msg)
+	})
+	engine.OnFullMatch("钓鱼活动").SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		if len(eventList) == 0 {
+			ctx.SendChain(message.Text("当前没有钓鱼活动"))
+			return
+		}
+		now := time.Now()
+		var sb strings.Builder
+		sb.WriteString("钓鱼活动:")
+		for _, e := range eventList {
+			sb.WriteString("\n")
+			if e.active(now) {
+				sb.WriteString("[进行中] ")
+			}
+			sb.WriteString(e.describe())
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+}
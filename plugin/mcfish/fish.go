@@ -153,7 +153,11 @@ func init() {
 			fishNumber /= 3
 		}
 		waitTime := 120 / (equipInfo.Induce + 1)
-		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("你开始去钓鱼了,请耐心等待鱼上钩(预计要", time.Second*time.Duration(waitTime), ")"))
+		eventMsg := ""
+		if events := activeEvents(time.Now()); len(events) != 0 {
+			eventMsg = "\n当前活动: " + strings.Join(events, "、")
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("你开始去钓鱼了,请耐心等待鱼上钩(预计要", time.Second*time.Duration(waitTime), ")", eventMsg))
 		timer := time.NewTimer(time.Second * time.Duration(rand.Intn(waitTime)+1))
 		for {
 			<-timer.C
@@ -195,6 +199,8 @@ func init() {
 				Max: 90,
 			}
 		}
+		now := time.Now()
+		applyEvents(localProbabilities, now)
 		if number2 != 0 {
 			info := localProbabilities["waste"]
 			info.Max = 100
@@ -221,6 +227,8 @@ func init() {
 		// 钓鱼结算
 		picName := ""
 		thingNameList := make(map[string]int)
+		bestCatch := make(map[string]catchInfo)
+		var firstList, personalList, groupList []string
 		for i := fishNumber; i > 0; i-- {
 			thingName := ""
 			typeOfThing := ""
@@ -296,6 +304,9 @@ func init() {
 				default:
 					thingName = "鹦鹉螺"
 				}
+				if lockedByEvent(thingName, now) {
+					thingName = "鳕鱼"
+				}
 				picName = thingName
 			default:
 				thingNameList["赛博空气"]++
@@ -339,8 +350,35 @@ func init() {
 					return
 				}
 				thingNameList[thingName] += number
+				c := newCatch(thingName)
+				if c.Size > bestCatch[thingName].Size || bestCatch[thingName].Name == "" {
+					bestCatch[thingName] = c
+				}
+				first, personal, group, err := dbdata.updateCollectionFor(uid, ctx.Event.GroupID, c, number)
+				if err != nil {
+					logrus.Warnln("[mcfish] 更新图鉴失败:", err)
+					continue
+				}
+				if first {
+					firstList = append(firstList, thingName)
+				}
+				if personal {
+					personalList = append(personalList, c.String())
+				}
+				if group {
+					groupList = append(groupList, c.String())
+				}
 			}
 		}
+		if len(firstList) != 0 {
+			msg += "\n图鉴新增: " + strings.Join(firstList, "、")
+		}
+		if len(personalList) != 0 {
+			msg += "\n刷新个人纪录: " + strings.Join(personalList, "、")
+		}
+		if len(groupList) != 0 {
+			msg += "\n打破本群纪录: " + strings.Join(groupList, "、")
+		}
 		err = dbdata.updateCurseFor(uid, "fish", fishNumber)
 		if err != nil {
 			logrus.Warnln(err)
@@ -352,6 +390,9 @@ func init() {
 				thingName = name
 				numberOfFish = number
 			}
+			if c, ok := bestCatch[thingName]; ok && numberOfFish == 1 {
+				thingName = c.String()
+			}
 			if picName != "" {
 				pic, err := engine.GetLazyData(picName+".png", false)
 				if err != nil {
@@ -368,6 +409,10 @@ func init() {
 		msgInfo := make(message.Message, 0, 3+len(thingNameList))
 		msgInfo = append(msgInfo, message.Reply(ctx.Event.MessageID), message.Text("你进行了", fishNumber, "次钓鱼,结果如下:\n"))
 		for name, number := range thingNameList {
+			if c, ok := bestCatch[name]; ok && c.Size > 0 {
+				msgInfo = append(msgInfo, message.Text(name, " : ", number, " 最大", strconv.FormatFloat(c.Size, 'f', 1, 64), "cm\n"))
+				continue
+			}
 			msgInfo = append(msgInfo, message.Text(name, " : ", number, "\n"))
 		}
 		msgInfo = append(msgInfo, message.Text(msg))
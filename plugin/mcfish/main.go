@@ -33,19 +33,22 @@ const version = "5.6.2"
 
 // 各物品信息
 type jsonInfo struct {
-	ZoneInfo    []zoneInfo    `json:"分类"` // 区域概率
-	ArticleInfo []articleInfo `json:"物品"` // 物品信息
+	ZoneInfo    []zoneInfo    `json:"分类"`           // 区域概率
+	ArticleInfo []articleInfo `json:"物品"`           // 物品信息
+	EventInfo   []eventInfo   `json:"活动,omitempty"` // 限时活动
 }
 type zoneInfo struct {
 	Name        string `json:"类型"`        // 类型
 	Probability int    `json:"概率[0-100)"` // 概率
 }
 type articleInfo struct {
-	Name        string `json:"名称"`                  // 名称
-	Type        string `json:"类型"`                  // 类型
-	Probability int    `json:"概率[0-100),omitempty"` // 概率
-	Durable     int    `json:"耐久上限,omitempty"`      // 耐久
-	Price       int    `json:"价格"`                  // 价格
+	Name        string    `json:"名称"`                  // 名称
+	Type        string    `json:"类型"`                  // 类型
+	Probability int       `json:"概率[0-100),omitempty"` // 概率
+	Durable     int       `json:"耐久上限,omitempty"`      // 耐久
+	Price       int       `json:"价格"`                  // 价格
+	Size        []float64 `json:"尺寸,omitempty"`        // 鱼的尺寸范围(cm)
+	Weight      []float64 `json:"重量,omitempty"`        // 鱼的重量范围(kg)
 }
 
 type probabilityLimit struct {
@@ -141,6 +144,13 @@ var (
 			"- 附魔[诱钓|海之眷顾]\n" +
 			"- 合成[xx竿|三叉戟]\n" +
 			"- 出售所有垃圾\n" +
+			"- 钓鱼市场 [物品名]\n" +
+			"- 上架xxx [数量] 一口价1000 [起拍500]\n" +
+			"- 竞拍[挂单编号] [出价] / 一口价购买[挂单编号]\n" +
+			"- 下架[挂单编号] / 我的挂单\n" +
+			"- 市场成交记录xxx\n" +
+			"- 设置市场手续费 5\n" +
+			"- 钓鱼图鉴 / 钓鱼纪录 / 钓鱼活动\n" +
 			"- 当前装备概率明细\n" +
 			"- 查看钓鱼规则\n",
 		PublicDataFolder: "McFish",
@@ -225,7 +235,14 @@ func init() {
 			Max: minMap[info.Type] + info.Probability,
 		}
 		minMap[info.Type] += info.Probability
+		if len(info.Size) == 2 && len(info.Weight) == 2 {
+			sizeList[info.Name] = sizeInfo{
+				Size:   [2]float64{info.Size[0], info.Size[1]},
+				Weight: [2]float64{info.Weight[0], info.Weight[1]},
+			}
+		}
 	}
+	eventList = articlesInfo.EventInfo
 	// }()
 }
 
@@ -0,0 +1,739 @@
+// Package mcfish 钓鱼模拟器
+package mcfish
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FloatTech/AnimeAPI/wallet"
+	"github.com/sirupsen/logrus"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+const (
+	marketExpire     = time.Hour * 48 // 挂单有效期
+	marketFeeDefault = 5              // 默认手续费(%)
+	marketListLimit  = 10             // 每人同时挂单上限
+	marketPriceDays  = 7              // 行情统计天数
+)
+
+// 市场挂单, 上架物品从卖家背包中扣除并暂存于此
+type listing struct {
+	ID       int64  // 挂单编号
+	GrpID    int64  // 群号
+	Seller   int64  // 卖家
+	Name     string // 物品名称
+	Type     string // 物品类型
+	Other    string // 耐久/维修次数/诱钓/眷顾
+	Number   int    // 数量
+	Price    int    // 一口价
+	Start    int    // 起拍价, 0 为不接受竞拍
+	Bid      int    // 当前最高出价
+	Bidder   int64  // 当前最高出价者
+	Duration int64  // 上架时间
+}
+
+// 成交记录
+type marketRecord struct {
+	ID       int64  // 成交时间(纳秒)
+	GrpID    int64  // 群号
+	Name     string // 物品名称
+	Number   int    // 数量
+	Price    int    // 成交总价
+	Duration int64  // 成交时间
+}
+
+// 已分配的最大挂单编号, 成交或下架的编号不再使用
+type marketSeq struct {
+	Name string
+	ID   int64
+}
+
+// 群市场手续费
+type marketFee struct {
+	GrpID int64
+	Fee   int // 百分比
+}
+
+// 物品的市场行情
+type marketPrice struct {
+	Name   string
+	Price  int // 平均单价
+	Number int // 成交数量
+}
+
+var (
+	errListingNotFound = errors.New("该挂单不存在或已成交")
+	errListingExpired  = errors.New("该挂单已过期")
+)
+
+func (l *listing) expired() bool {
+	return time.Since(time.Unix(l.Duration, 0)) > marketExpire
+}
+
+func (l *listing) title() string {
+	name := l.Name
+	if l.Other != "" && l.Name != "美西螈" {
+		name += "(" + l.Other + ")"
+	}
+	return name + "x" + strconv.Itoa(l.Number)
+}
+
+// 鱼竿类物品在背包中单独存放
+func isEquipment(name string) bool {
+	return strings.Contains(name, "竿") || name == "三叉戟"
+}
+
+/*********************************************************/
+/************************市场相关函数***********************/
+/*********************************************************/
+
+func (sql *fishdb) createMarket() (err error) {
+	err = sql.db.Create("market", &listing{})
+	if err != nil {
+		return
+	}
+	err = sql.db.Create("marketRecord", &marketRecord{})
+	if err != nil {
+		return
+	}
+	err = sql.db.Create("marketSeq", &marketSeq{})
+	if err != nil {
+		return
+	}
+	return sql.db.Create("marketFee", &marketFee{})
+}
+
+// 分配新的挂单编号, 调用时需持有锁
+func (sql *fishdb) nextListingID() (int64, error) {
+	seq := marketSeq{Name: "market"}
+	if sql.db.Find("marketSeq", &seq, "WHERE Name = 'market'") != nil {
+		// 旧数据没有记录, 从现有的最大编号继续
+		last := listing{}
+		_ = sql.db.Find("market", &last, "ORDER BY ID DESC")
+		seq.ID = last.ID
+	}
+	seq.ID++
+	return seq.ID, sql.db.Insert("marketSeq", &seq)
+}
+
+// 从背包中扣除物品并上架
+func (sql *fishdb) listThingFor(gid, uid int64, thing article, number, price, start int) (id int64, err error) {
+	name := strconv.FormatInt(uid, 10) + "Pack"
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	err = sql.db.Create(name, &thing)
+	if err != nil {
+		return
+	}
+	count := 0
+	info := listing{}
+	_ = sql.db.FindFor("market", &info, "WHERE GrpID = ? AND Seller = ?", func() error {
+		count++
+		return nil
+	}, gid, uid)
+	if count >= marketListLimit {
+		return 0, errors.New("你的挂单数量已达上限(" + strconv.Itoa(marketListLimit) + ")")
+	}
+	err = sql.db.Find(name, &thing, "WHERE Duration = ?", thing.Duration)
+	if err != nil || thing.Number < number {
+		return 0, errors.New("背包中的物品数量不足")
+	}
+	thing.Number -= number
+	if thing.Number == 0 {
+		err = sql.db.Del(name, "WHERE Duration = ?", thing.Duration)
+	} else {
+		err = sql.db.Insert(name, &thing)
+	}
+	if err != nil {
+		return
+	}
+	id, err = sql.nextListingID()
+	if err != nil {
+		return
+	}
+	info = listing{
+		ID:       id,
+		GrpID:    gid,
+		Seller:   uid,
+		Name:     thing.Name,
+		Type:     thing.Type,
+		Other:    thing.Other,
+		Number:   number,
+		Price:    price,
+		Start:    start,
+		Duration: time.Now().Unix(),
+	}
+	return info.ID, sql.db.Insert("market", &info)
+}
+
+// 获取群内挂单, name 为空时获取全部
+func (sql *fishdb) getListings(gid int64, name string) (infos []listing, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	condition, args := "WHERE GrpID = ?", []any{gid}
+	if name != "" {
+		condition += " AND Name = ?"
+		args = append(args, name)
+	}
+	if !sql.db.CanFind("market", condition, args...) {
+		return
+	}
+	info := listing{}
+	err = sql.db.FindFor("market", &info, condition+" ORDER BY ID ASC", func() error {
+		infos = append(infos, info)
+		return nil
+	}, args...)
+	return
+}
+
+// 获取用户在群内的挂单与出价
+func (sql *fishdb) getUserListings(gid, uid int64) (infos []listing, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	if !sql.db.CanFind("market", "WHERE GrpID = ? AND (Seller = ? OR Bidder = ?)", gid, uid, uid) {
+		return
+	}
+	info := listing{}
+	err = sql.db.FindFor("market", &info, "WHERE GrpID = ? AND (Seller = ? OR Bidder = ?) ORDER BY ID ASC", func() error {
+		infos = append(infos, info)
+		return nil
+	}, gid, uid, uid)
+	return
+}
+
+// 出价, 返回出价前的挂单以便退还上一位出价者
+func (sql *fishdb) bidListing(gid, id, uid int64, price int) (old listing, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	err = sql.db.Find("market", &old, "WHERE ID = ? AND GrpID = ?", id, gid)
+	if err != nil {
+		return old, errListingNotFound
+	}
+	switch {
+	case old.expired():
+		return old, errListingExpired
+	case old.Seller == uid:
+		return old, errors.New("不能竞拍自己的挂单")
+	case old.Start == 0:
+		return old, errors.New("该挂单只接受一口价购买")
+	case price < old.Start:
+		return old, errors.New("出价不能低于起拍价" + strconv.Itoa(old.Start))
+	case price <= old.Bid:
+		return old, errors.New("出价需高于当前最高价" + strconv.Itoa(old.Bid))
+	case price >= old.Price:
+		return old, errors.New("出价已达一口价, 请直接一口价购买")
+	}
+	info := old
+	info.Bid = price
+	info.Bidder = uid
+	return old, sql.db.Insert("market", &info)
+}
+
+// 取出挂单, check 不通过时不做修改
+func (sql *fishdb) takeListing(gid, id int64, check func(*listing) error) (info listing, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	err = sql.db.Find("market", &info, "WHERE ID = ? AND GrpID = ?", id, gid)
+	if err != nil {
+		return info, errListingNotFound
+	}
+	err = check(&info)
+	if err != nil {
+		return
+	}
+	return info, sql.db.Del("market", "WHERE ID = ?", id)
+}
+
+// 获取全部过期挂单, 结算前需先用 takeListing 取出, 以免重复结算
+func (sql *fishdb) getExpiredListings() (infos []listing, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(-marketExpire).Unix()
+	if !sql.db.CanFind("market", "WHERE Duration < ?", deadline) {
+		return
+	}
+	info := listing{}
+	err = sql.db.FindFor("market", &info, "WHERE Duration < ?", func() error {
+		infos = append(infos, info)
+		return nil
+	}, deadline)
+	return
+}
+
+// 将挂单物品放入用户背包
+func (sql *fishdb) putListingInPack(uid int64, info listing) (err error) {
+	name := strconv.FormatInt(uid, 10) + "Pack"
+	sql.Lock()
+	defer sql.Unlock()
+	thing := article{
+		Duration: time.Now().Unix(),
+		Name:     info.Name,
+		Type:     info.Type,
+		Other:    info.Other,
+	}
+	err = sql.db.Create(name, &thing)
+	if err != nil {
+		return
+	}
+	if !isEquipment(info.Name) {
+		_ = sql.db.Find(name, &thing, "WHERE Name = ?", info.Name)
+	}
+	for thing.Number == 0 && sql.db.CanFind(name, "WHERE Duration = ?", thing.Duration) {
+		thing.Duration++
+	}
+	thing.Number += info.Number
+	return sql.db.Insert(name, &thing)
+}
+
+// 记录成交
+func (sql *fishdb) recordTrade(info listing, price int) (err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	return sql.db.Insert("marketRecord", &marketRecord{
+		ID:       now.UnixNano(),
+		GrpID:    info.GrpID,
+		Name:     info.Name,
+		Number:   info.Number,
+		Price:    price,
+		Duration: now.Unix(),
+	})
+}
+
+// 获取物品最近的成交记录
+func (sql *fishdb) getTradeRecords(gid int64, name string, n int) (records []marketRecord, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	if !sql.db.CanFind("marketRecord", "WHERE GrpID = ? AND Name = ?", gid, name) {
+		return
+	}
+	record := marketRecord{}
+	err = sql.db.FindFor("marketRecord", &record, "WHERE GrpID = ? AND Name = ? ORDER BY ID DESC LIMIT ?", func() error {
+		records = append(records, record)
+		return nil
+	}, gid, name, n)
+	return
+}
+
+// 获取群内近期的市场行情, 按物品列表排序
+func (sql *fishdb) getMarketPrices(gid int64) (prices []marketPrice, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	since := time.Now().AddDate(0, 0, -marketPriceDays).Unix()
+	if !sql.db.CanFind("marketRecord", "WHERE GrpID = ? AND Duration > ?", gid, since) {
+		return
+	}
+	total := make(map[string]int, 16)
+	number := make(map[string]int, 16)
+	record := marketRecord{}
+	err = sql.db.FindFor("marketRecord", &record, "WHERE GrpID = ? AND Duration > ?", func() error {
+		total[record.Name] += record.Price
+		number[record.Name] += record.Number
+		return nil
+	}, gid, since)
+	if err != nil {
+		return
+	}
+	for _, name := range thingList {
+		if number[name] > 0 {
+			prices = append(prices, marketPrice{Name: name, Price: total[name] / number[name], Number: number[name]})
+		}
+	}
+	return
+}
+
+// 获取群市场手续费
+func (sql *fishdb) getMarketFee(gid int64) int {
+	sql.Lock()
+	defer sql.Unlock()
+	info := marketFee{GrpID: gid, Fee: marketFeeDefault}
+	if sql.createMarket() != nil {
+		return info.Fee
+	}
+	_ = sql.db.Find("marketFee", &info, "WHERE GrpID = ?", gid)
+	return info.Fee
+}
+
+// 设置群市场手续费
+func (sql *fishdb) setMarketFee(gid int64, fee int) (err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	err = sql.createMarket()
+	if err != nil {
+		return
+	}
+	return sql.db.Insert("marketFee", &marketFee{GrpID: gid, Fee: fee})
+}
+
+// 成交: 物品交给买家, 扣除手续费后付款给卖家
+func dealListing(info listing, buyer int64, price int) (income int, err error) {
+	err = dbdata.putListingInPack(buyer, info)
+	if err != nil {
+		return
+	}
+	income = price - price*dbdata.getMarketFee(info.GrpID)/100
+	err = wallet.InsertWalletOf(info.Seller, income)
+	if err != nil {
+		return
+	}
+	return income, dbdata.recordTrade(info, price)
+}
+
+// 结算过期挂单, 有人出价则由最高出价者成交, 否则退回卖家背包.
+// 挂单先从市场中取出再交付, 已被其它操作取出的挂单不会被重复结算
+var settleMarket = func(ctx *zero.Ctx) bool {
+	infos, err := dbdata.getExpiredListings()
+	if err != nil {
+		logrus.Warnln("[mcfish] 获取过期挂单失败:", err)
+		return true
+	}
+	for _, info := range infos {
+		info, err = dbdata.takeListing(info.GrpID, info.ID, func(l *listing) error {
+			if !l.expired() {
+				return errListingNotFound
+			}
+			return nil
+		})
+		if err != nil {
+			continue
+		}
+		if info.Bidder == 0 {
+			err = dbdata.putListingInPack(info.Seller, info)
+		} else {
+			_, err = dealListing(info, info.Bidder, info.Bid)
+		}
+		if err != nil {
+			logrus.Warnln("[mcfish] 结算挂单", info.ID, "失败:", err)
+		}
+	}
+	return true
+}
+
+func init() {
+	engine.OnRegex(`^钓鱼市场\s*(`+strings.Join(thingList, "|")+`)?$`, zero.OnlyGroup, getdb, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		infos, err := dbdata.getListings(ctx.Event.GroupID, ctx.State["regex_matched"].([]string)[1])
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.1]:", err))
+			return
+		}
+		if len(infos) == 0 {
+			ctx.SendChain(message.Text("市场上还没有挂单"))
+			return
+		}
+		msg := make(message.Message, 0, 2+len(infos))
+		msg = append(msg, message.Text("本群市场(手续费", dbdata.getMarketFee(ctx.Event.GroupID), "%):\n"))
+		for _, info := range infos {
+			text := "[" + strconv.FormatInt(info.ID, 10) + "] " + info.title() + " 一口价:" + strconv.Itoa(info.Price)
+			if info.Bid > 0 {
+				text += " 当前出价:" + strconv.Itoa(info.Bid)
+			} else if info.Start > 0 {
+				text += " 起拍价:" + strconv.Itoa(info.Start)
+			}
+			left := time.Until(time.Unix(info.Duration, 0).Add(marketExpire))
+			msg = append(msg, message.Text(text, " 剩余", int(left.Hours()), "小时\n"))
+		}
+		ctx.Send(msg)
+	})
+	engine.OnRegex(`^上架(`+strings.Join(thingList, "|")+`)\s*(\d*)\s+一口价\s*(\d+)(?:\s+起拍价?\s*(\d+))?$`, zero.OnlyGroup, getdb, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		uid := ctx.Event.UserID
+		args := ctx.State["regex_matched"].([]string)
+		thingName := args[1]
+		number, _ := strconv.Atoi(args[2])
+		price, _ := strconv.Atoi(args[3])
+		start, _ := strconv.Atoi(args[4])
+		if number == 0 || isEquipment(thingName) {
+			number = 1
+		}
+		if price <= 0 {
+			ctx.SendChain(message.Text("一口价必须大于0"))
+			return
+		}
+		if start >= price {
+			ctx.SendChain(message.Text("起拍价必须低于一口价"))
+			return
+		}
+		articles, err := dbdata.getUserThingInfo(uid, thingName)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.2]:", err))
+			return
+		}
+		if len(articles) == 0 {
+			ctx.SendChain(message.Text("你的背包不存在该物品"))
+			return
+		}
+		index := 0
+		if len(articles) > 1 {
+			msg := make(message.Message, 0, 3+len(articles))
+			msg = append(msg, message.Reply(ctx.Event.MessageID), message.Text("找到以下物品:\n"))
+			for i, info := range articles {
+				if info.Other != "" && info.Name != "美西螈" {
+					msg = append(msg, message.Text("[", i, "] ", info.Name, "(", info.Other, ")\n"))
+				} else {
+					msg = append(msg, message.Text("[", i, "]", info.Name, "  数量: ", info.Number, "\n"))
+				}
+			}
+			msg = append(msg, message.Text("————————\n输入对应序号进行上架,或回复“取消”取消"))
+			ctx.Send(msg)
+			// 等待用户下一步选择
+			recv, cancel := zero.NewFutureEvent("message", 999, false, zero.RegexRule(`^(取消|\d+)$`), zero.CheckUser(uid)).Repeat()
+			defer cancel()
+			chosen := false
+			for !chosen {
+				select {
+				case <-time.After(time.Second * 120):
+					ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text("等待超时,取消上架")))
+					return
+				case e := <-recv:
+					nextcmd := e.Event.Message.String()
+					if nextcmd == "取消" {
+						ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text("已取消上架")))
+						return
+					}
+					index, err = strconv.Atoi(nextcmd)
+					if err != nil || index > len(articles)-1 {
+						ctx.SendChain(message.At(uid), message.Text("请输入正确的序号"))
+						continue
+					}
+					chosen = true
+				}
+			}
+		}
+		thing := articles[index]
+		if thing.Number < number {
+			number = thing.Number
+		}
+		id, err := dbdata.listThingFor(ctx.Event.GroupID, uid, thing, number, price, start)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.3]:", err))
+			return
+		}
+		ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text(
+			"成功上架", number, "个", thingName, ", 挂单编号:", id,
+			"\n挂单", int(marketExpire.Hours()), "小时后过期, 有人出价则由最高出价者成交, 否则退回背包")))
+	})
+	engine.OnRegex(`^竞拍\s*(\d+)\s+(\d+)$`, zero.OnlyGroup, getdb, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		uid := ctx.Event.UserID
+		args := ctx.State["regex_matched"].([]string)
+		id, _ := strconv.ParseInt(args[1], 10, 64)
+		price, _ := strconv.Atoi(args[2])
+		money := wallet.GetWalletOf(uid)
+		if money < price {
+			ctx.SendChain(message.Text("你身上的钱(", money, ")不够出价"))
+			return
+		}
+		// 先冻结出价, 失败时退还
+		err := wallet.InsertWalletOf(uid, -price)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.4]:", err))
+			return
+		}
+		old, err := dbdata.bidListing(ctx.Event.GroupID, id, uid, price)
+		if err != nil {
+			if err1 := wallet.InsertWalletOf(uid, price); err1 != nil {
+				logrus.Warnln("[mcfish] 退还出价失败:", err1)
+			}
+			ctx.SendChain(message.Text("[ERROR at market.go.5]:", err))
+			return
+		}
+		if old.Bidder != 0 {
+			err = wallet.InsertWalletOf(old.Bidder, old.Bid)
+			if err != nil {
+				logrus.Warnln("[mcfish] 退还出价失败:", err)
+			}
+		}
+		msg := message.Message{message.Reply(ctx.Event.MessageID), message.Text("出价成功, 你以", price, "暂列", old.title(), "的最高出价")}
+		if old.Bidder != 0 && old.Bidder != uid {
+			msg = append(msg, message.Text("\n"), message.At(old.Bidder), message.Text(" 你的出价已被超过, ", old.Bid, "已退还"))
+		}
+		ctx.Send(msg)
+	})
+	engine.OnRegex(`^一口价购买\s*(\d+)$`, zero.OnlyGroup, getdb, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		uid := ctx.Event.UserID
+		id, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[1], 10, 64)
+		money := wallet.GetWalletOf(uid)
+		info, err := dbdata.takeListing(ctx.Event.GroupID, id, func(l *listing) error {
+			switch {
+			case l.expired():
+				return errListingExpired
+			case l.Seller == uid:
+				return errors.New("不能购买自己的挂单, 请使用下架")
+			case money < l.Price:
+				return errors.New("你身上的钱(" + strconv.Itoa(money) + ")不够支付" + strconv.Itoa(l.Price))
+			}
+			return nil
+		})
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.6]:", err))
+			return
+		}
+		err = wallet.InsertWalletOf(uid, -info.Price)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.7]:", err))
+			return
+		}
+		if info.Bidder != 0 {
+			err = wallet.InsertWalletOf(info.Bidder, info.Bid)
+			if err != nil {
+				logrus.Warnln("[mcfish] 退还出价失败:", err)
+			}
+		}
+		_, err = dealListing(info, uid, info.Price)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.8]:", err))
+			return
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("你用", info.Price, "购买了", info.title()), message.Text("\n"), message.At(info.Seller), message.Text(" 你的挂单已成交"))
+	})
+	engine.OnRegex(`^下架\s*(\d+)$`, zero.OnlyGroup, getdb, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		uid := ctx.Event.UserID
+		id, _ := strconv.ParseInt(ctx.State["regex_matched"].([]string)[1], 10, 64)
+		info, err := dbdata.takeListing(ctx.Event.GroupID, id, func(l *listing) error {
+			switch {
+			case l.expired():
+				return errListingExpired
+			case l.Seller != uid:
+				return errors.New("这不是你的挂单")
+			case l.Bidder != 0:
+				return errors.New("已有人出价, 不能下架")
+			}
+			return nil
+		})
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.9]:", err))
+			return
+		}
+		err = dbdata.putListingInPack(uid, info)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.10]:", err))
+			return
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("已下架", info.title(), ", 物品已退回背包"))
+	})
+	engine.OnFullMatch("我的挂单", zero.OnlyGroup, getdb, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		uid := ctx.Event.UserID
+		infos, err := dbdata.getUserListings(ctx.Event.GroupID, uid)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.11]:", err))
+			return
+		}
+		if len(infos) == 0 {
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("你在本群市场没有挂单或出价"))
+			return
+		}
+		var sb strings.Builder
+		for _, info := range infos {
+			sb.WriteString("[")
+			sb.WriteString(strconv.FormatInt(info.ID, 10))
+			sb.WriteString("] ")
+			sb.WriteString(info.title())
+			if info.Seller == uid {
+				sb.WriteString(" 出售中 一口价:")
+				sb.WriteString(strconv.Itoa(info.Price))
+			} else {
+				sb.WriteString(" 竞拍中")
+			}
+			if info.Bid > 0 {
+				sb.WriteString(" 最高出价:")
+				sb.WriteString(strconv.Itoa(info.Bid))
+			}
+			sb.WriteString("\n")
+		}
+		ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(strings.TrimSpace(sb.String())))
+	})
+	engine.OnRegex(`^市场成交记录\s*(`+strings.Join(thingList, "|")+`)$`, zero.OnlyGroup, getdb).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+		thingName := ctx.State["regex_matched"].([]string)[1]
+		records, err := dbdata.getTradeRecords(ctx.Event.GroupID, thingName, 10)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.12]:", err))
+			return
+		}
+		if len(records) == 0 {
+			ctx.SendChain(message.Text(thingName, "在本群市场还没有成交记录"))
+			return
+		}
+		total, number := 0, 0
+		var sb strings.Builder
+		sb.WriteString(thingName)
+		sb.WriteString("最近的成交记录:")
+		for _, record := range records {
+			total += record.Price
+			number += record.Number
+			sb.WriteString("\n")
+			sb.WriteString(time.Unix(record.Duration, 0).Format("01-02 15:04"))
+			sb.WriteString(" 数量:")
+			sb.WriteString(strconv.Itoa(record.Number))
+			sb.WriteString(" 单价:")
+			sb.WriteString(strconv.Itoa(record.Price / record.Number))
+		}
+		sb.WriteString("\n平均单价:")
+		sb.WriteString(strconv.Itoa(total / number))
+		sb.WriteString(" 商店价:")
+		sb.WriteString(strconv.Itoa(priceList[thingName] * discountList[thingName] / 100))
+		ctx.SendChain(message.Text(sb.String()))
+	})
+	engine.OnRegex(`^设置市场手续费\s*(\d+)%?$`, zero.OnlyGroup, zero.SuperUserPermission, getdb).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		fee, _ := strconv.Atoi(ctx.State["regex_matched"].([]string)[1])
+		if fee > 50 {
+			ctx.SendChain(message.Text("手续费不能超过50%"))
+			return
+		}
+		err := dbdata.setMarketFee(ctx.Event.GroupID, fee)
+		if err != nil {
+			ctx.SendChain(message.Text("[ERROR at market.go.13]:", err))
+			return
+		}
+		ctx.SendChain(message.Text("本群市场手续费已设置为", fee, "%"))
+	})
+}
+
+// 按成交量排序, 只保留前 n 项
+func topMarketPrices(prices []marketPrice, n int) []marketPrice {
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].Number > prices[j].Number
+	})
+	if len(prices) > n {
+		prices = prices[:n]
+	}
+	return prices
+}
@@ -175,7 +175,8 @@ func init() {
 		msg := "一款钓鱼模拟器\n----------指令----------\n" +
 			"- 钓鱼看板/钓鱼商店\n- 购买xxx\n- 购买xxx [数量]\n- 出售xxx\n- 出售xxx [数量]\n- 出售所有垃圾\n" +
 			"- 钓鱼背包\n- 装备[xx竿|三叉戟|美西螈]\n- 附魔[诱钓|海之眷顾]\n- 修复鱼竿\n- 合成[xx竿|三叉戟]\n- 消除[绑定|宝藏]诅咒\n- 消除[绑定|宝藏]诅咒 [数量]\n" +
-			"- 进行钓鱼\n- 进行n次钓鱼\n- " +
+			"- 钓鱼市场 [物品名]\n- 上架xxx [数量] 一口价1000 [起拍500]\n- 竞拍[挂单编号] [出价]\n- 一口价购买[挂单编号]\n- 下架[挂单编号]\n- 我的挂单\n- 市场成交记录xxx\n" +
+			"- 进行钓鱼\n- 进行n次钓鱼\n- 钓鱼图鉴\n- 钓鱼纪录\n- 钓鱼活动\n- " +
 			"当前装备概率明细\n" +
 			"规则V" + version + ":\n" +
 			"1.每日的商店价格是波动的!!如何最大化收益自己考虑一下喔\n" +
@@ -192,7 +193,11 @@ func init() {
 			"8.合成:\n-> 铁竿 : 3x木竿\n-> 金竿 : 3x铁竿\n-> 钻石竿 : 3x金竿\n-> 下界合金竿 : 3x钻石竿\n-> 三叉戟 : 3x下界合金竿\n注:合成成功率90%(包括梭哈),合成鱼竿的附魔等级=（附魔等级合/合成鱼竿数量）\n" +
 			"9.杂项:\n-> 无装备的情况下,每人最多可以购买3次100块钱的鱼竿,商店每日会上架1木竿\n-> 默认状态钓鱼上钩概率为60%(理论值!!!)\n-> 附魔的鱼竿会因附魔变得昂贵,每个附魔最高3级\n-> 三叉戟不算鱼竿,修复时可直接满耐久\n" +
 			"-> 鱼竿数量大于50的不能买东西;\n     鱼竿数量大于30的不能钓鱼;\n     每购/售10次鱼竿获得1层宝藏诅咒;\n     每购买20次物品将获得3次价格减半福利;\n     每钓鱼75次获得1本净化书;\n" +
-			"     每天可交易鱼竿10个，购买物品30件（垃圾除外）."
+			"     每天可交易鱼竿10个，购买物品30件（垃圾除外）.\n" +
+			"10.市场:\n-> 玩家之间按群交易,上架的物品会从背包中扣除,成交时卖家需支付手续费(默认5%)\n-> 出价时钱包会先扣除出价金额,被超过时退还\n" +
+			"-> 挂单48小时后过期,有人出价则由最高出价者成交,否则退回卖家背包;已有出价的挂单不能下架\n" +
+			"11.图鉴与活动:\n-> 每条鱼都有随机的尺寸和重量,越大越稀有,图鉴记录首次钓到的日期和个人最大纪录,群内最大的鱼会成为本群纪录\n" +
+			"-> 限时活动期间会调整各分类的上钩概率,部分鱼只在活动期间出现,详见\"钓鱼活动\""
 
 		ctx.Send(msg)
 	})
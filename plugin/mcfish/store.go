@@ -44,17 +44,26 @@ func limitSet(ctx *zero.Ctx) *rate.Limiter {
 }
 
 func init() {
-	engine.OnFullMatchGroup([]string{"钓鱼看板", "钓鱼商店"}, getdb, refreshFish).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
+	engine.OnFullMatchGroup([]string{"钓鱼看板", "钓鱼商店"}, getdb, refreshFish, settleMarket).SetBlock(true).Limit(limitSet).Handle(func(ctx *zero.Ctx) {
 		infos, err := dbdata.getStoreInfo()
 		if err != nil {
 			ctx.SendChain(message.Text("[ERROR at store.go.2]:", err))
 			return
 		}
+		var prices []marketPrice
+		if ctx.Event.GroupID != 0 {
+			prices, err = dbdata.getMarketPrices(ctx.Event.GroupID)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR at store.go.2.1]:", err))
+				return
+			}
+			prices = topMarketPrices(prices, 10)
+		}
 		var picImage image.Image
-		if len(infos) == 0 {
+		if len(infos) == 0 && len(prices) == 0 {
 			picImage, err = drawStroeEmptyImage()
 		} else {
-			picImage, err = drawStroeInfoImage(infos)
+			picImage, err = drawStroeInfoImage(infos, prices)
 		}
 		if err != nil {
 			ctx.SendChain(message.Text("[ERROR at store.go.3]:", err))
@@ -694,7 +703,7 @@ func drawStroeEmptyImage() (picImage image.Image, err error) {
 	return canvas.Image(), nil
 }
 
-func drawStroeInfoImage(stroeInfo []store) (picImage image.Image, err error) {
+func drawStroeInfoImage(stroeInfo []store, prices []marketPrice) (picImage image.Image, err error) {
 	fontdata, err := file.GetLazyData(text.BoldFontFile, control.Md5File, true)
 	if err != nil {
 		return nil, err
@@ -717,6 +726,9 @@ func drawStroeInfoImage(stroeInfo []store) (picImage image.Image, err error) {
 
 	bolckW := int(10 + nameW + 50 + numberW + 50 + priceW + 10)
 	backY := 10 + int(titleH*2+10)*2 + 10 + (len(stroeInfo)+len(discountList)/2+2)*int(textH*2) + 10
+	if len(prices) > 0 {
+		backY += int(titleH*2+10) + (len(prices)+1)*int(textH*2)
+	}
 	canvas = gg.NewContext(bolckW, math.Max(backY, 500))
 	// 画底色
 	canvas.DrawRectangle(0, 0, float64(bolckW), float64(backY))
@@ -811,5 +823,34 @@ func drawStroeInfoImage(stroeInfo []store) (picImage image.Image, err error) {
 		canvas.DrawStringAnchored(numberStr, 10+nameW+10+numberW/2, textDy+textH/2, 0.5, 0.5)
 		canvas.DrawStringAnchored(strconv.Itoa(pice), 10+nameW+10+numberW+50+priceW/2, textDy+textH/2, 0.5, 0.5)
 	}
+	if len(prices) == 0 {
+		return canvas.Image(), nil
+	}
+
+	textDy += textH * 2
+	err = canvas.ParseFontFace(fontdata, 100)
+	if err != nil {
+		return nil, err
+	}
+	canvas.DrawString("市场行情", 10, textDy+titleH*1.2)
+	canvas.DrawLine(10, textDy+titleH*1.6, titleW, textDy+titleH*1.6)
+	canvas.SetLineWidth(3)
+	canvas.SetRGBA255(0, 0, 0, 255)
+	canvas.Stroke()
+
+	textDy += 10 + titleH*1.7
+	if err = canvas.ParseFontFace(fontdata, 50); err != nil {
+		return nil, err
+	}
+	canvas.DrawStringAnchored("名称", 10+nameW/2, textDy+textH/2, 0.5, 0.5)
+	canvas.DrawStringAnchored("商店", 10+nameW+10+numberW/2, textDy+textH/2, 0.5, 0.5)
+	canvas.DrawStringAnchored("市价", 10+nameW+10+numberW+50+priceW/2, textDy+textH/2, 0.5, 0.5)
+	for _, info := range prices {
+		textDy += textH * 2
+		canvas.SetColor(color.Black)
+		canvas.DrawStringAnchored(info.Name+"("+strconv.Itoa(info.Number)+"个)", 10+nameW/2, textDy+textH/2, 0.5, 0.5)
+		canvas.DrawStringAnchored(strconv.Itoa(priceList[info.Name]*discountList[info.Name]/100), 10+nameW+10+numberW/2, textDy+textH/2, 0.5, 0.5)
+		canvas.DrawStringAnchored(strconv.Itoa(info.Price), 10+nameW+10+numberW+50+priceW/2, textDy+textH/2, 0.5, 0.5)
+	}
 	return canvas.Image(), nil
 }
@@ -2,8 +2,10 @@
 package music
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/tool"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
@@ -27,7 +29,7 @@ var platformMap = map[string]func(string) (message.Segment, error){
 }
 
 func init() {
-	control.AutoRegister(&ctrl.Options[*zero.Ctx]{
+	en := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
 		DisableOnDefault: false,
 		Brief:            "点歌",
 		Help: "- 点歌[xxx] (默认酷我)\n" +
@@ -36,7 +38,32 @@ func init() {
 			"- 酷狗点歌[xxx]\n" +
 			"- 咪咕点歌[xxx]\n" +
 			"- qq点歌[xxx]\n",
-	}).OnRegex(`^(.{0,2})点歌\s?(.{1,25})$`).SetBlock(true).Limit(ctxext.LimitByUser).
+	})
+	tool.Register(en, tool.Tool{
+		Name:   "music",
+		Desc:   "点歌, 搜索歌曲并直接将音乐卡片发送到当前会话",
+		Schema: `{"type":"object","properties":{"keyword":{"type":"string","description":"歌名或歌名加歌手"},"platform":{"type":"string","enum":["","咪咕","酷我","酷狗","网易","qq"],"description":"平台, 默认酷我"}},"required":["keyword"]}`,
+		Data:   "已发送的消息ID (number)",
+		Handle: func(ctx *zero.Ctx, args json.RawMessage) (any, error) {
+			var p struct {
+				Keyword  string `json:"keyword"`
+				Platform string `json:"platform"`
+			}
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, err
+			}
+			processFunc, ok := platformMap[p.Platform]
+			if !ok {
+				return nil, errors.New("不支持的点播平台：" + p.Platform)
+			}
+			seg, err := processFunc(p.Keyword)
+			if err != nil {
+				return nil, err
+			}
+			return ctx.SendChain(seg), nil
+		},
+	})
+	en.OnRegex(`^(.{0,2})点歌\s?(.{1,25})$`).SetBlock(true).Limit(ctxext.LimitByUser).
 		Handle(func(ctx *zero.Ctx) {
 			matches := ctx.State["regex_matched"].([]string)
 			platformPrefix := matches[1]
@@ -56,7 +56,7 @@ var (
 	engine = control.AutoRegister(&ctrl.Options[*zero.Ctx]{
 		DisableOnDefault: false,
 		Brief:            "一群一天一夫一妻制群老婆",
-		Help: "- 娶群友\n- 群老婆列表\n- [允许|禁止]自由恋爱\n- [允许|禁止]牛头人\n- 设置CD为xx小时    →(默认12小时)\n- 重置花名册\n- 重置所有花名册(用于清除所有群数据及其设置)\n- 查好感度[对方Q号|@对方QQ]\n- 好感度列表\n- 好感度数据整理 (当好感度列表出现重复名字时使用)\n" +
+		Help: "- 娶群友\n- 群老婆列表\n- 我的情史\n- 群CP统计\n- 群关系图\n- [允许|禁止]自由恋爱\n- [允许|禁止]牛头人\n- 设置CD为xx小时    →(默认12小时)\n- 重置花名册\n- 重置所有花名册(用于清除所有群数据及其设置)\n- 查好感度[对方Q号|@对方QQ]\n- 好感度列表\n- 好感度数据整理 (当好感度列表出现重复名字时使用)\n" +
 			"--------------------------------\n以下指令存在CD,不跨天刷新,前两个受指令开关\n--------------------------------\n" +
 			"- (娶|嫁)@对方QQ\n自由选择对象, 自由恋爱(好感度越高成功率越高,保底30%概率)\n" +
 			"- 当[对方Q号|@对方QQ]的小三\n我和你才是真爱, 为了你我愿意付出一切(好感度越高成功率越高,保底10%概率)\n" +
@@ -90,6 +90,12 @@ var (
 				ctx.SendChain(message.Text("[ERROR]:", err))
 				return false
 			}
+			// 创建情史表
+			err = 民政局.db.Create("history", &history{})
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+				return false
+			}
 			return true
 		}
 		ctx.SendChain(message.Text("[ERROR]:", err))
@@ -180,6 +186,10 @@ func init() {
 				ctx.SendChain(message.Text("[ERROR]:", err))
 				return
 			}
+			err = 民政局.记录情史(history{GroupID: gid, Event: 事件结婚, User: uid, Target: fiancee, Username: ctx.CardOrNickName(uid), Targetname: ctx.CardOrNickName(fiancee), Actor: uid})
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+			}
 			favor, err := 民政局.更新好感度(uid, fiancee, 1+rand.Intn(5))
 			if err != nil {
 				ctx.SendChain(message.Text("[ERROR]:", err))
@@ -418,7 +428,7 @@ func (sql *婚姻登记) 清理花名册(gid ...int64) error {
 		grouplist, err := sql.db.ListTables()
 		if err == nil {
 			for _, listName := range grouplist {
-				if listName == "favorability" {
+				if listName == "favorability" || listName == "history" {
 					continue
 				}
 				err = sql.db.Drop(listName)
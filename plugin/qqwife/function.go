@@ -149,6 +149,14 @@ func init() {
 				}
 				choicetext = "\n今天你的群老公是"
 			}
+			h := history{GroupID: gid, Event: 事件结婚, User: uid, Target: fiancee, Username: ctx.CardOrNickName(uid), Targetname: ctx.CardOrNickName(fiancee), Actor: uid}
+			if choice != "娶" {
+				h.User, h.Target, h.Username, h.Targetname = h.Target, h.User, h.Targetname, h.Username
+			}
+			err = 民政局.记录情史(h)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+			}
 			favor, err = 民政局.更新好感度(uid, fiancee, 1+rand.Intn(5))
 			if err != nil {
 				ctx.SendChain(message.Text("[ERROR]:", err))
@@ -199,6 +207,7 @@ func init() {
 			var ntrID = uid
 			var targetID = fiancee
 			var greenID int64 // 被牛的
+			var victim int64  // 原来的对象
 			fianceeInfo, err := 民政局.查户口(gid, fiancee)
 			switch {
 			case err != nil:
@@ -213,6 +222,7 @@ func init() {
 				ntrID = fiancee
 				targetID = ctx.Event.UserID
 				greenID = fianceeInfo.Target
+				victim = fianceeInfo.Target
 				choicetext = "老公"
 			case fianceeInfo.Target == fiancee: // 是0
 				err = 民政局.离婚休夫(gid, fianceeInfo.User)
@@ -221,6 +231,7 @@ func init() {
 					return
 				}
 				greenID = fianceeInfo.Target
+				victim = fianceeInfo.User
 				choicetext = "老婆"
 			default:
 				ctx.SendChain(message.Text("数据库发生问题力"))
@@ -231,6 +242,10 @@ func init() {
 				ctx.SendChain(message.Text("[qqwife]复婚登记失败力\n", err))
 				return
 			}
+			err = 民政局.记录情史(history{GroupID: gid, Event: 事件牛头, User: ntrID, Target: targetID, Username: ctx.CardOrNickName(ntrID), Targetname: ctx.CardOrNickName(targetID), Actor: uid, Victim: victim})
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+			}
 			favor, err = 民政局.更新好感度(uid, fiancee, -5)
 			if err != nil {
 				ctx.SendChain(message.Text("[ERROR]:", err))
@@ -291,6 +306,10 @@ func init() {
 				ctx.SendChain(message.Text("[ERROR]:", err))
 				return
 			}
+			err = 民政局.记录情史(history{GroupID: gid, Event: 事件做媒, User: gayOne, Target: gayZero, Username: ctx.CardOrNickName(gayOne), Targetname: ctx.CardOrNickName(gayZero), Actor: uid})
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+			}
 			_, err = 民政局.更新好感度(uid, gayOne, 1)
 			if err != nil {
 				ctx.SendChain(message.Text("[ERROR]:", err))
@@ -361,6 +380,10 @@ func init() {
 				ctx.SendChain(message.Text("[ERROR]:", err))
 				return
 			}
+			err = 民政局.记录情史(history{GroupID: gid, Event: 事件离婚, User: userInfo.User, Target: userInfo.Target, Username: userInfo.Username, Targetname: userInfo.Targetname, Actor: uid})
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+			}
 			ctx.SendChain(message.Text(sendtext[4][mun]))
 		})
 }
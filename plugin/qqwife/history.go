@@ -0,0 +1,423 @@
+package qqwife
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg"
+	"github.com/FloatTech/gg/factory"
+	control "github.com/FloatTech/zbputils/control"
+	"github.com/FloatTech/zbputils/ctxext"
+	"github.com/FloatTech/zbputils/img/text"
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// 情史事件
+const (
+	事件结婚 = "结婚"
+	事件离婚 = "离婚"
+	事件牛头 = "NTR"
+	事件做媒 = "做媒"
+)
+
+// 情史记录, 不随花名册重置
+type history struct {
+	ID         int64  // unix 纳秒
+	GroupID    int64  // 群号
+	Event      string // 事件类型
+	User       int64  // 攻方
+	Target     int64  // 受方
+	Username   string // 攻方名称
+	Targetname string // 受方名称
+	Actor      int64  // 发起人: 离婚的提出者, 小三, 媒人
+	Victim     int64  // 被牛的一方
+	Time       int64  // 时间
+}
+
+// cp 一对CP, 小号在前
+type cp [2]int64
+
+func newcp(a, b int64) cp {
+	if a > b {
+		a, b = b, a
+	}
+	return cp{a, b}
+}
+
+// 是否为组成CP的事件
+func (h *history) paired() bool {
+	return h.Event != 事件离婚 && h.User != 0 && h.Target != 0
+}
+
+func init() {
+	engine.OnFullMatch("我的情史", zero.OnlyGroup, getdb).SetBlock(true).Limit(ctxext.LimitByUser).
+		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			uid := ctx.Event.UserID
+			list, err := 民政局.查情史(gid, uid)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+				return
+			}
+			if len(list) == 0 {
+				ctx.SendChain(message.Text("你在本群还是一张白纸哦"))
+				return
+			}
+			names := 情史名册(list)
+			var married, divorced, ntr, ntred int
+			partners := make(map[int64]int)
+			for _, h := range list {
+				switch {
+				case h.Event == 事件离婚:
+					divorced++
+				case h.Event == 事件牛头 && h.Actor == uid:
+					ntr++
+				case h.Event == 事件牛头 && h.Victim == uid:
+					ntred++
+				}
+				if h.paired() && (h.User == uid || h.Target == uid) {
+					married++
+					partners[h.User+h.Target-uid]++
+				}
+			}
+			var sb strings.Builder
+			sb.WriteString(ctx.CardOrNickName(uid) + " 的情史\n")
+			sb.WriteString("结婚" + strconv.Itoa(married) + "次, 离婚" + strconv.Itoa(divorced) + "次, 牛走别人" + strconv.Itoa(ntr) + "次, 被牛" + strconv.Itoa(ntred) + "次\n")
+			best, bestn := int64(0), 0
+			for p, n := range partners {
+				if n > bestn || n == bestn && p < best {
+					best, bestn = p, n
+				}
+			}
+			if bestn > 0 {
+				sb.WriteString("最常在一起的是 " + names[best] + "(" + strconv.FormatInt(best, 10) + "), 共" + strconv.Itoa(bestn) + "次\n")
+			}
+			sb.WriteString("————————————————\n")
+			if len(list) > 20 {
+				list = list[:20]
+			}
+			for _, h := range list {
+				sb.WriteString(time.Unix(h.Time, 0).Format("2006/01/02 15:04 "))
+				sb.WriteString(h.describe(uid, names))
+				sb.WriteString("\n")
+			}
+			data, err := text.RenderToBase64(sb.String(), text.BoldFontFile, 800, 40)
+			if err != nil {
+				ctx.SendChain(message.Text("[qqwife]ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Image("base64://" + string(data)))
+		})
+	engine.OnFullMatch("群CP统计", zero.OnlyGroup, getdb).SetBlock(true).Limit(ctxext.LimitByGroup).
+		Handle(func(ctx *zero.Ctx) {
+			list, err := 民政局.查情史(ctx.Event.GroupID, 0)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+				return
+			}
+			names := 情史名册(list)
+			counts, streaks := cp统计(list)
+			if len(counts) == 0 {
+				ctx.SendChain(message.Text("本群还没有人结过婚哦"))
+				return
+			}
+			var sb strings.Builder
+			sb.WriteString("群CP统计\n————————————————\n最常在一起的CP:\n")
+			for i, p := range 排序(counts, 5) {
+				sb.WriteString(strconv.Itoa(i+1) + ". " + names[p[0]] + " ←→ " + names[p[1]] + "  " + strconv.Itoa(counts[p]) + "次\n")
+			}
+			sb.WriteString("————————————————\n连续在一起最久的CP:\n")
+			for i, p := range 排序(streaks, 5) {
+				sb.WriteString(strconv.Itoa(i+1) + ". " + names[p[0]] + " ←→ " + names[p[1]] + "  " + strconv.Itoa(streaks[p]) + "天\n")
+			}
+			data, err := text.RenderToBase64(sb.String(), text.BoldFontFile, 800, 40)
+			if err != nil {
+				ctx.SendChain(message.Text("[qqwife]ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Image("base64://" + string(data)))
+		})
+	engine.OnFullMatch("群关系图", zero.OnlyGroup, getdb).SetBlock(true).Limit(ctxext.LimitByGroup).
+		Handle(func(ctx *zero.Ctx) {
+			gid := ctx.Event.GroupID
+			err := 民政局.开门时间(gid)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+				return
+			}
+			list, err := 民政局.查情史(gid, 0)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+				return
+			}
+			today, err := 民政局.花名册(gid)
+			if err != nil {
+				ctx.SendChain(message.Text("[ERROR]:", err))
+				return
+			}
+			if len(list) == 0 && len(today) == 0 {
+				ctx.SendChain(message.Text("本群还没有人结过婚哦"))
+				return
+			}
+			img, err := 画关系图(list, today)
+			if err != nil {
+				ctx.SendChain(message.Text("[qqwife]ERROR: ", err))
+				return
+			}
+			data, err := factory.ToBytes(img)
+			if err != nil {
+				ctx.SendChain(message.Text("[qqwife]ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.ImageBytes(data))
+		})
+}
+
+// describe 以 uid 的视角描述事件
+func (h *history) describe(uid int64, names map[int64]string) string {
+	other := h.User + h.Target - uid
+	switch h.Event {
+	case 事件离婚:
+		if h.Actor == uid {
+			return "和 " + names[other] + " 离婚了"
+		}
+		return "被 " + names[other] + " 离婚了"
+	case 事件牛头:
+		switch uid {
+		case h.Actor:
+			return "当了小三, 从 " + names[h.Victim] + " 身边抢走了 " + names[other]
+		case h.Victim:
+			return "被 " + names[h.Actor] + " 牛走了对象"
+		}
+		return "被 " + names[h.Actor] + " 抢走, 离开了 " + names[h.Victim]
+	case 事件做媒:
+		if h.Actor == uid {
+			return "撮合了 " + names[h.User] + " 和 " + names[h.Target]
+		}
+		return "经 " + names[h.Actor] + " 做媒, 和 " + names[other] + " 在一起了"
+	}
+	if h.User == uid {
+		return "娶了 " + names[other]
+	}
+	return "嫁给了 " + names[other]
+}
+
+// 情史名册 记录中每个人最后使用的名称
+func 情史名册(list []history) map[int64]string {
+	names := make(map[int64]string, len(list))
+	// list 按时间倒序
+	for i := len(list) - 1; i >= 0; i-- {
+		h := &list[i]
+		if h.Username != "" {
+			names[h.User] = h.Username
+		}
+		if h.Targetname != "" {
+			names[h.Target] = h.Targetname
+		}
+	}
+	for _, h := range list {
+		for _, id := range [...]int64{h.User, h.Target, h.Actor, h.Victim} {
+			if _, ok := names[id]; !ok && id != 0 {
+				names[id] = strconv.FormatInt(id, 10)
+			}
+		}
+	}
+	return names
+}
+
+// cp统计 每对CP在一起的次数与最长连续天数
+func cp统计(list []history) (counts, streaks map[cp]int) {
+	counts = make(map[cp]int)
+	days := make(map[cp]map[int64]struct{})
+	for _, h := range list {
+		if !h.paired() {
+			continue
+		}
+		p := newcp(h.User, h.Target)
+		counts[p]++
+		if days[p] == nil {
+			days[p] = make(map[int64]struct{})
+		}
+		t := time.Unix(h.Time, 0)
+		days[p][time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix()/86400] = struct{}{}
+	}
+	streaks = make(map[cp]int, len(days))
+	for p, ds := range days {
+		sorted := make([]int64, 0, len(ds))
+		for d := range ds {
+			sorted = append(sorted, d)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		best, cur := 1, 1
+		for i := 1; i < len(sorted); i++ {
+			if sorted[i] == sorted[i-1]+1 {
+				cur++
+			} else {
+				cur = 1
+			}
+			best = max(best, cur)
+		}
+		streaks[p] = best
+	}
+	return
+}
+
+// 排序 取出数值最大的 n 对CP
+func 排序(m map[cp]int, n int) []cp {
+	ps := make([]cp, 0, len(m))
+	for p := range m {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if m[ps[i]] != m[ps[j]] {
+			return m[ps[i]] > m[ps[j]]
+		}
+		return ps[i][0] < ps[j][0] || ps[i][0] == ps[j][0] && ps[i][1] < ps[j][1]
+	})
+	if len(ps) > n {
+		ps = ps[:n]
+	}
+	return ps
+}
+
+// 画关系图 历史CP为灰线, 次数越多越粗, 今天的CP为红线
+func 画关系图(list []history, today [][4]string) (image.Image, error) {
+	const (
+		size    = 1200.0
+		maxNode = 24
+		radius  = 460.0
+		nodeR   = 36.0
+	)
+	names := 情史名册(list)
+	counts, _ := cp统计(list)
+	current := make(map[cp]bool, len(today))
+	for _, info := range today {
+		user, _ := strconv.ParseInt(info[1], 10, 64)
+		target, _ := strconv.ParseInt(info[3], 10, 64)
+		names[user], names[target] = info[0], info[2]
+		current[newcp(user, target)] = true
+	}
+	// 按出现次数选出最活跃的人, 今天的CP优先
+	weight := make(map[int64]int)
+	for p, n := range counts {
+		weight[p[0]] += n
+		weight[p[1]] += n
+	}
+	for p := range current {
+		weight[p[0]] += 1 << 16
+		weight[p[1]] += 1 << 16
+	}
+	nodes := make([]int64, 0, len(weight))
+	for id := range weight {
+		nodes = append(nodes, id)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if weight[nodes[i]] != weight[nodes[j]] {
+			return weight[nodes[i]] > weight[nodes[j]]
+		}
+		return nodes[i] < nodes[j]
+	})
+	if len(nodes) > maxNode {
+		nodes = nodes[:maxNode]
+	}
+	pos := make(map[int64][2]float64, len(nodes))
+	for i, id := range nodes {
+		a := 2*math.Pi*float64(i)/float64(len(nodes)) - math.Pi/2
+		pos[id] = [2]float64{size/2 + radius*math.Cos(a), 100 + size/2 + radius*math.Sin(a)}
+	}
+
+	canvas := gg.NewContext(int(size), int(size+100))
+	canvas.SetRGB(1, 1, 1)
+	canvas.Clear()
+	data, err := file.GetLazyData(text.BoldFontFile, control.Md5File, true)
+	if err != nil {
+		return nil, err
+	}
+	if err = canvas.ParseFontFace(data, 60); err != nil {
+		return nil, err
+	}
+	canvas.SetRGB(0, 0, 0)
+	canvas.DrawStringAnchored("群关系图", size/2, 70, 0.5, 0.5)
+
+	drawEdge := func(p cp) (a, b [2]float64, ok bool) {
+		a, ok1 := pos[p[0]]
+		b, ok2 := pos[p[1]]
+		return a, b, ok1 && ok2
+	}
+	for p, n := range counts {
+		a, b, ok := drawEdge(p)
+		if !ok || current[p] {
+			continue
+		}
+		canvas.SetRGBA255(120, 120, 120, 160)
+		canvas.SetLineWidth(2 + math.Min(float64(n), 10))
+		canvas.DrawLine(a[0], a[1], b[0], b[1])
+		canvas.Stroke()
+	}
+	for p := range current {
+		a, b, ok := drawEdge(p)
+		if !ok {
+			continue
+		}
+		canvas.SetRGB255(230, 60, 90)
+		canvas.SetLineWidth(8)
+		canvas.DrawLine(a[0], a[1], b[0], b[1])
+		canvas.Stroke()
+	}
+
+	if err = canvas.ParseFontFace(data, 24); err != nil {
+		return nil, err
+	}
+	for _, id := range nodes {
+		p := pos[id]
+		canvas.DrawCircle(p[0], p[1], nodeR)
+		canvas.SetRGB255(255, 214, 222)
+		canvas.FillPreserve()
+		canvas.SetRGB255(230, 60, 90)
+		canvas.SetLineWidth(3)
+		canvas.Stroke()
+		name := []rune(names[id])
+		if len(name) > 6 {
+			name = append(name[:5], '…')
+		}
+		canvas.SetRGB(0, 0, 0)
+		canvas.DrawStringAnchored(string(name), p[0], p[1]+nodeR+20, 0.5, 0.5)
+	}
+	return canvas.Image(), nil
+}
+
+func (sql *婚姻登记) 记录情史(h history) error {
+	sql.Lock()
+	defer sql.Unlock()
+	now := time.Now()
+	h.ID = now.UnixNano()
+	h.Time = now.Unix()
+	return sql.db.Insert("history", &h)
+}
+
+// 查情史 uid 为 0 时返回全群的记录, 按时间倒序
+func (sql *婚姻登记) 查情史(gid, uid int64) (list []history, err error) {
+	sql.Lock()
+	defer sql.Unlock()
+	var h history
+	condition := "WHERE GroupID = ? ORDER BY ID DESC"
+	args := []any{gid}
+	if uid != 0 {
+		condition = "WHERE GroupID = ? AND (User = ? OR Target = ? OR Actor = ? OR Victim = ?) ORDER BY ID DESC"
+		args = append(args, uid, uid, uid, uid)
+	}
+	if !sql.db.CanFind("history", condition, args...) {
+		return nil, nil
+	}
+	err = sql.db.FindFor("history", &h, condition, func() error {
+		list = append(list, h)
+		return nil
+	}, args...)
+	return
+}
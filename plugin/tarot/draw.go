@@ -0,0 +1,141 @@
+package tarot
+
+import (
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg"
+	"github.com/FloatTech/gg/factory"
+	"github.com/FloatTech/zbputils/control"
+	"github.com/FloatTech/zbputils/img/text"
+)
+
+const (
+	cardW, cardH   = 200.0, 340.0 // 牌的尺寸
+	unitX, unitY   = 280.0, 380.0 // 一个牌位的尺寸
+	margin         = 40.0
+	titleH         = 90.0
+	legendFontSize = 26.0
+)
+
+// drawn 抽到的一张牌
+type drawn struct {
+	card
+	reversed bool
+	img      image.Image // 获取失败时为 nil
+}
+
+func (d *drawn) position() string {
+	if d.reversed {
+		return "『逆位』"
+	}
+	return "『正位』"
+}
+
+func (d *drawn) description() string {
+	if d.reversed {
+		return d.ReverseDescription
+	}
+	return d.Description
+}
+
+// 牌旋转后的外框尺寸
+func bounds(angle float64) (w, h float64) {
+	rad := gg.Radians(angle)
+	c, s := math.Abs(math.Cos(rad)), math.Abs(math.Sin(rad))
+	return cardW*c + cardH*s, cardW*s + cardH*c
+}
+
+// drawSpread 按牌阵位置把抽到的牌拼成一张图, 逆位的牌旋转180度, 图下方附上各位置的释义
+func drawSpread(title string, spots []spot, cards []drawn) (image.Image, error) {
+	fontdata, err := file.GetLazyData(text.FontFile, control.Md5File, true)
+	if err != nil {
+		return nil, err
+	}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, s := range spots {
+		w, h := bounds(s.Rotate)
+		cx, cy := s.X*unitX, s.Y*unitY
+		minX, maxX = math.Min(minX, cx-w/2), math.Max(maxX, cx+w/2)
+		minY, maxY = math.Min(minY, cy-h/2), math.Max(maxY, cy+h/2)
+	}
+	width := math.Max(maxX-minX+margin*2, 900)
+	offX := (width-(maxX-minX))/2 - minX
+	offY := titleH - minY
+
+	// 先排版释义以确定图片高度
+	canvas := gg.NewContext(1, 1)
+	if err = canvas.ParseFontFace(fontdata, legendFontSize); err != nil {
+		return nil, err
+	}
+	lineH := canvas.FontHeight() * 1.5
+	legends := make([][]string, len(cards))
+	legendH := 0.0
+	for i, c := range cards {
+		head := strconv.Itoa(i+1) + ". " + spots[i].Name
+		if spots[i].Meaning != "" {
+			head += "(" + spots[i].Meaning + ")"
+		}
+		head += ": " + c.position() + "的『" + c.Name + "』"
+		legends[i] = append(canvas.WordWrap(head, width-margin*2), canvas.WordWrap("    "+c.description(), width-margin*2)...)
+		legendH += lineH*float64(len(legends[i])) + lineH/2
+	}
+
+	height := offY + maxY + margin + legendH + margin
+	canvas = gg.NewContext(int(width), int(height))
+	canvas.SetRGB255(32, 24, 48)
+	canvas.Clear()
+
+	if err = canvas.ParseFontFace(fontdata, 44); err != nil {
+		return nil, err
+	}
+	canvas.SetRGB255(240, 220, 160)
+	canvas.DrawStringAnchored(title, width/2, titleH/2, 0.5, 0.5)
+
+	if err = canvas.ParseFontFace(fontdata, legendFontSize); err != nil {
+		return nil, err
+	}
+	for i, s := range spots {
+		cx, cy := s.X*unitX+offX, s.Y*unitY+offY
+		angle := s.Rotate
+		if cards[i].reversed {
+			angle += 180
+		}
+		if cards[i].img != nil {
+			// imaging 以逆时针为正
+			canvas.DrawImageAnchored(factory.Rotate(cards[i].img, -angle, int(cardW), int(cardH)).Image(), int(cx), int(cy), 0.5, 0.5)
+		} else {
+			canvas.Push()
+			canvas.RotateAbout(gg.Radians(angle), cx, cy)
+			canvas.DrawRoundedRectangle(cx-cardW/2, cy-cardH/2, cardW, cardH, 12)
+			canvas.SetRGB255(80, 64, 112)
+			canvas.FillPreserve()
+			canvas.SetRGB255(240, 220, 160)
+			canvas.SetLineWidth(3)
+			canvas.Stroke()
+			canvas.DrawStringWrapped(cards[i].Name, cx, cy, 0.5, 0.5, cardW-20, 1.2, gg.AlignCenter)
+			canvas.Pop()
+		}
+		// 序号
+		w, h := bounds(s.Rotate)
+		bx, by := cx-w/2+22, cy-h/2+22
+		canvas.DrawCircle(bx, by, 18)
+		canvas.SetRGB255(240, 220, 160)
+		canvas.Fill()
+		canvas.SetRGB255(32, 24, 48)
+		canvas.DrawStringAnchored(strconv.Itoa(i+1), bx, by, 0.5, 0.5)
+	}
+
+	y := offY + maxY + margin
+	canvas.SetRGB255(235, 230, 245)
+	for _, lines := range legends {
+		for _, line := range lines {
+			canvas.DrawStringAnchored(line, margin, y+lineH/2, 0, 0.5)
+			y += lineH
+		}
+		y += lineH / 2
+	}
+	return canvas.Image(), nil
+}
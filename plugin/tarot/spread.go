@@ -0,0 +1,139 @@
+package tarot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// spot 牌阵中的一个位置, 坐标以牌位为单位, 指向牌的中心
+type spot struct {
+	Name    string  `json:"name"`
+	Meaning string  `json:"meaning,omitempty"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Rotate  float64 `json:"rotate,omitempty"` // 顺时针旋转角度, 如凯尔特十字的阻碍牌为 90
+}
+
+// 内置牌阵, formation.json 中没有同名牌阵时使用
+var builtinFormations = map[string]formation{
+	"过去现在未来": {
+		Positions: []spot{
+			{Name: "过去", Meaning: "影响当前问题的过往经历", X: 0, Y: 0},
+			{Name: "现在", Meaning: "问题目前的状态", X: 1, Y: 0},
+			{Name: "未来", Meaning: "照此发展可能的结果", X: 2, Y: 0},
+		},
+	},
+	"凯尔特十字": {
+		Positions: []spot{
+			{Name: "现状", Meaning: "问题的核心", X: 1, Y: 1.5},
+			{Name: "阻碍", Meaning: "面临的挑战", X: 1, Y: 1.5, Rotate: 90},
+			{Name: "目标", Meaning: "意识层面的期望", X: 1, Y: 0.5},
+			{Name: "根基", Meaning: "潜意识与问题的根源", X: 1, Y: 2.5},
+			{Name: "过去", Meaning: "正在远去的影响", X: 0, Y: 1.5},
+			{Name: "未来", Meaning: "即将到来的影响", X: 2, Y: 1.5},
+			{Name: "自我", Meaning: "你在其中的态度", X: 3.4, Y: 3},
+			{Name: "环境", Meaning: "他人与外界的影响", X: 3.4, Y: 2},
+			{Name: "希望与恐惧", Meaning: "内心的期待与担忧", X: 3.4, Y: 1},
+			{Name: "结果", Meaning: "最终的走向", X: 3.4, Y: 0},
+		},
+	},
+}
+
+// spots 牌阵的各个位置, 未定义坐标时按每行五张排列
+func (f *formation) spots() []spot {
+	if len(f.Positions) > 0 {
+		return f.Positions
+	}
+	s := make([]spot, f.CardsNum)
+	for i := range s {
+		s[i] = spot{Name: "第" + strconv.Itoa(i+1) + "张", X: float64(i % 5), Y: float64(i / 5)}
+		if len(f.Represent) > 0 && i < len(f.Represent[0]) {
+			s[i].Name = f.Represent[0][i]
+		}
+	}
+	return s
+}
+
+// customFormations 各群自定义的牌阵
+type customFormations struct {
+	sync.RWMutex
+	file string
+	m    map[string]map[string]formation // 群号: 牌阵名: 牌阵
+}
+
+var custom = customFormations{m: make(map[string]map[string]formation)}
+
+func (c *customFormations) load(file string) error {
+	c.Lock()
+	defer c.Unlock()
+	c.file = file
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.m)
+}
+
+func (c *customFormations) save() error {
+	data, err := json.MarshalIndent(c.m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.file, data, 0644)
+}
+
+func (c *customFormations) get(gid int64, name string) (formation, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	f, ok := c.m[strconv.FormatInt(gid, 10)][name]
+	return f, ok
+}
+
+func (c *customFormations) names(gid int64) []string {
+	c.RLock()
+	defer c.RUnlock()
+	names := make([]string, 0, len(c.m[strconv.FormatInt(gid, 10)]))
+	for k := range c.m[strconv.FormatInt(gid, 10)] {
+		names = append(names, k)
+	}
+	return names
+}
+
+func (c *customFormations) set(gid int64, name string, positions []spot) error {
+	if len(positions) == 0 || len(positions) > 12 {
+		return errors.New("牌阵需要1~12个位置")
+	}
+	for _, p := range positions {
+		if p.Name == "" {
+			return errors.New("每个位置都需要名称")
+		}
+		if p.X < 0 || p.Y < 0 || p.X > 10 || p.Y > 10 {
+			return errors.New("坐标需在0~10之间")
+		}
+	}
+	c.Lock()
+	defer c.Unlock()
+	key := strconv.FormatInt(gid, 10)
+	if c.m[key] == nil {
+		c.m[key] = make(map[string]formation)
+	}
+	c.m[key][name] = formation{CardsNum: len(positions), Positions: positions}
+	return c.save()
+}
+
+func (c *customFormations) del(gid int64, name string) (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	key := strconv.FormatInt(gid, 10)
+	if _, ok := c.m[key][name]; !ok {
+		return false, nil
+	}
+	delete(c.m[key], name)
+	return true, c.save()
+}
@@ -2,7 +2,11 @@
 package tarot
 
 import (
+	"bytes"
 	"encoding/json"
+	"image"
+	_ "image/jpeg" // 解析牌面
+	_ "image/png"  // 解析牌面
 	"math/rand"
 	"os"
 	"path"
@@ -11,6 +15,7 @@ import (
 
 	"github.com/FloatTech/floatbox/binary"
 	fcext "github.com/FloatTech/floatbox/ctxext"
+	"github.com/FloatTech/gg/factory"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
@@ -34,6 +39,7 @@ type formation struct {
 	CardsNum  int        `json:"cards_num"`
 	IsCut     bool       `json:"is_cut"`
 	Represent [][]string `json:"represent"`
+	Positions []spot     `json:"positions,omitempty"`
 }
 type cardSet = map[string]card
 
@@ -55,7 +61,10 @@ func init() {
 		Help: "- 抽[塔罗牌|大阿卡纳|小阿卡纳]\n" +
 			"- 抽n张[塔罗牌|大阿卡纳|小阿卡纳]\n" +
 			"- 解塔罗牌[牌名]\n" +
-			"- [塔罗|大阿卡纳|小阿卡纳|混合]牌阵[圣三角|时间之流|四要素|五牌阵|吉普赛十字|马蹄|六芒星]",
+			"- [塔罗|大阿卡纳|小阿卡纳|混合]牌阵[圣三角|时间之流|四要素|五牌阵|吉普赛十字|马蹄|六芒星|过去现在未来|凯尔特十字]\n" +
+			"- 添加牌阵[名称] [{\"name\":\"过去\",\"meaning\":\"释义\",\"x\":0,\"y\":0,\"rotate\":0},...]\n" +
+			"- 删除牌阵[名称]\n" +
+			"Tips: 坐标以牌位为单位, 自定义牌阵仅在本群可用",
 		PublicDataFolder: "Tarot",
 	}).ApplySingle(ctxext.DefaultSingle)
 
@@ -106,10 +115,20 @@ func init() {
 			ctx.SendChain(message.Text("ERROR: ", err))
 			return false
 		}
+		for k, v := range builtinFormations {
+			if _, ok := formationMap[k]; !ok {
+				formationMap[k] = v
+			}
+		}
 		for k := range formationMap {
 			formationName = append(formationName, k)
 		}
 		logrus.Infof("[tarot]读取%d组塔罗牌阵", len(formationMap))
+		err = custom.load(engine.DataFolder() + "custom_formation.json")
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return false
+		}
 		return true
 	})
 	engine.OnRegex(`^抽(\d{1,2}张)?((塔罗牌|大阿(尔)?卡纳)|小阿(尔)?卡纳)$`, getTarot).SetBlock(true).Limit(ctxext.LimitByGroup).Handle(func(ctx *zero.Ctx) {
@@ -239,9 +258,15 @@ func init() {
 	engine.OnRegex(`^((塔罗|大阿(尔)?卡纳)|小阿(尔)?卡纳|混合)牌阵\s?(.*)`, getTarot).SetBlock(true).Limit(ctxext.LimitByGroup).Handle(func(ctx *zero.Ctx) {
 		cardType := ctx.State["regex_matched"].([]string)[1]
 		match := ctx.State["regex_matched"].([]string)[5]
-		info, ok := formationMap[match]
-		position := [...]string{"『正位』", "『逆位』"}
-		reverse := [...]string{"", "Reverse/"}
+		info, ok := custom.get(ctx.Event.GroupID, match)
+		if !ok {
+			info, ok = formationMap[match]
+		}
+		if !ok {
+			names := append(custom.names(ctx.Event.GroupID), formationName...)
+			ctx.SendChain(message.Text("没有找到", match, "噢~\n现有牌阵列表: \n", strings.Join(names, "\n")))
+			return
+		}
 		start, length := 0, 22
 		if strings.Contains(cardType, "小") {
 			start = 22
@@ -250,65 +275,70 @@ func init() {
 			start = 0
 			length = 77
 		}
-		if ok {
-			ctx.SendChain(message.Text("少女祈祷中..."))
-			var build strings.Builder
-			build.WriteString(ctx.CardOrNickName(ctx.Event.UserID))
-			build.WriteString("---")
-			build.WriteString(match)
-			build.WriteString("\n")
-			msg := make(message.Message, info.CardsNum+1)
-			randomIntMap := make(map[int]int, 30)
-			for i := 0; i < info.CardsNum; i++ {
-				j := rand.Intn(length)
-				_, ok := randomIntMap[j]
-				for ok {
-					j = rand.Intn(length)
-					_, ok = randomIntMap[j]
-				}
-				randomIntMap[j] = 0
-				p := rand.Intn(2)
-				card := cardMap[strconv.Itoa(j+start)]
-				name := card.Name
-				description := card.Description
-				if p == 1 {
-					description = card.ReverseDescription
-				}
-				var tarotmsg message.Message
-				imgurl := reverse[p] + card.ImgURL
-				var imgmsg message.Segment
-				var err error
-				data, err := engine.GetLazyData(imgurl, true)
-				if err != nil {
-					// ctx.SendChain(message.Text("ERROR: ", err))
-					logrus.Infof("[tarot]获取图片失败: %v", err)
-					// return
-				} else {
-					imgmsg = message.ImageBytes(data)
-					tarotmsg = append(tarotmsg, imgmsg)
-				}
-				build.WriteString(info.Represent[0][i])
-				build.WriteString(":")
-				build.WriteString(position[p])
-				build.WriteString("的『")
-				build.WriteString(name)
-				build.WriteString("』\n其释义为: \n")
-				build.WriteString(description)
-				build.WriteString("\n")
-				msg[i] = ctxext.FakeSenderForwardNode(ctx, tarotmsg...)
-			}
-			txt := build.String()
-			formation, err := text.RenderToBase64(txt, text.FontFile, 420, 20)
+		spots := info.spots()
+		if len(spots) > length {
+			ctx.SendChain(message.Text("ERROR: 牌阵需要的牌数超过了牌堆"))
+			return
+		}
+		ctx.SendChain(message.Text("少女祈祷中..."))
+		cards := make([]drawn, len(spots))
+		for i, j := range rand.Perm(length)[:len(spots)] {
+			cards[i] = drawn{card: cardMap[strconv.Itoa(j+start)], reversed: rand.Intn(2) == 1}
+			// 逆位由绘图时旋转, 这里只取正位的图片
+			data, err := engine.GetLazyData(cards[i].ImgURL, true)
 			if err != nil {
-				ctx.SendChain(message.Text("ERROR: ", err))
-				return
+				logrus.Infof("[tarot]获取图片失败: %v", err)
+				continue
 			}
-			msg[info.CardsNum] = ctxext.FakeSenderForwardNode(ctx, message.Message{message.Image("base64://" + binary.BytesToString(formation))}...)
-			if id := ctx.Send(msg).ID(); id == 0 {
-				ctx.SendChain(message.Text("ERROR: 可能被风控了"))
+			cards[i].img, _, err = image.Decode(bytes.NewReader(data))
+			if err != nil {
+				logrus.Infof("[tarot]解析图片失败: %v", err)
 			}
-		} else {
-			ctx.SendChain(message.Text("没有找到", match, "噢~\n现有牌阵列表: \n", strings.Join(formationName, "\n")))
 		}
+		img, err := drawSpread(ctx.CardOrNickName(ctx.Event.UserID)+"的"+match, spots, cards)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		data, err := factory.ToBytes(img)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if id := ctx.SendChain(message.ImageBytes(data)).ID(); id == 0 {
+			ctx.SendChain(message.Text("ERROR: 可能被风控了"))
+		}
+	})
+	engine.OnRegex(`^添加牌阵\s*(\S+)\s+(&#91;[\s\S]*&#93;)$`, zero.OnlyGroup, zero.AdminPermission, getTarot).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		args := ctx.State["regex_matched"].([]string)
+		if _, ok := formationMap[args[1]]; ok {
+			ctx.SendChain(message.Text("ERROR: 不能覆盖内置牌阵"))
+			return
+		}
+		var positions []spot
+		err := json.Unmarshal([]byte(message.UnescapeCQText(args[2])), &positions)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		err = custom.set(ctx.Event.GroupID, args[1], positions)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("已添加", len(positions), "张牌的牌阵『", args[1], "』"))
+	})
+	engine.OnRegex(`^删除牌阵\s*(\S+)$`, zero.OnlyGroup, zero.AdminPermission, getTarot).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		name := ctx.State["regex_matched"].([]string)[1]
+		ok, err := custom.del(ctx.Event.GroupID, name)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if !ok {
+			ctx.SendChain(message.Text("本群没有自定义牌阵『", name, "』"))
+			return
+		}
+		ctx.SendChain(message.Text("已删除牌阵『", name, "』"))
 	})
 }
@@ -0,0 +1,177 @@
+package translation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/FloatTech/AnimeAPI/tl"
+	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/floatbox/web"
+	"github.com/FloatTech/zbputils/chat"
+	"github.com/fumiama/deepinfra"
+	"github.com/fumiama/deepinfra/model"
+	"github.com/tidwall/gjson"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/provider"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/usage"
+)
+
+// 翻译后端
+const (
+	backenddefault = "默认"
+	backendllm     = "大模型"
+	backendlibre   = "LibreTranslate"
+)
+
+const llmprompt = "你是翻译引擎。将用户发送的文本%s翻译为%s, 保留原有格式, 只输出译文, 不要解释。"
+
+var (
+	errNoLLM   = errors.New("未配置大模型, 请先在 aichatcfg 中设置")
+	errNoLibre = errors.New("未设置 LibreTranslate 地址")
+	// errDefaultLang 默认接口无法处理的语言
+	errDefaultLang = errors.New("默认翻译后端仅支持中英互译, 其它语言请先设置翻译后端为大模型或 LibreTranslate")
+)
+
+// request 一次翻译请求
+type request struct {
+	gid, uid int64
+	src, tgt int
+	text     string
+}
+
+// config 全局翻译配置
+type config struct {
+	mu       sync.Mutex
+	Backend  string `json:"backend"`   // Backend 翻译后端
+	LibreURL string `json:"libre_url"` // LibreURL LibreTranslate 地址
+	LibreKey string `json:"libre_key"` // LibreKey LibreTranslate 密钥, 可留空
+}
+
+var cfg config
+
+// 加载配置
+func (c *config) load(filename string) error {
+	if file.IsNotExist(filename) {
+		return nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return json.NewDecoder(f).Decode(c)
+}
+
+// 修改并保存配置
+func (c *config) set(filename string, f func(c *config)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f(c)
+	fp, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return json.NewEncoder(fp).Encode(c)
+}
+
+func (c *config) backend() (b, url, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b = c.Backend
+	if b == "" {
+		b = backenddefault
+	}
+	return b, c.LibreURL, c.LibreKey
+}
+
+// translate 按当前后端翻译
+func translate(r *request) (string, error) {
+	b, url, key := cfg.backend()
+	switch b {
+	case backendllm:
+		return llmtranslate(r)
+	case backendlibre:
+		return libretranslate(url, key, r)
+	default:
+		// 默认接口仅支持中英互译, 自动判断方向
+		if !iszhen(r) {
+			return "", errDefaultLang
+		}
+		return tl.Translate(r.text)
+	}
+}
+
+// iszhen 请求是否为默认接口能处理的中英互译
+func iszhen(r *request) bool {
+	src := r.src
+	if src == langauto {
+		src = detect(r.text)
+	}
+	switch r.tgt {
+	case langzh:
+		return src == langen || src == langauto
+	case langen:
+		return src == langzh || src == langauto
+	default:
+		return false
+	}
+}
+
+func llmtranslate(r *request) (string, error) {
+	if chat.AC.API == "" {
+		return "", errNoLLM
+	}
+	if err := usage.Check(r.gid, r.uid, false); err != nil {
+		return "", err
+	}
+	src := ""
+	if r.src != langauto {
+		src = "从" + langs[r.src].name
+	}
+	topp, maxn := chat.AC.MParams()
+	m := provider.Meter{GrpID: r.gid, UserID: r.uid, Plugin: "translation"}
+	data, err := provider.Request(m, 0.2, topp, maxn, func(p model.Protocol) deepinfra.Model {
+		return p.System(fmt.Sprintf(llmprompt, src, langs[r.tgt].name)).User(model.NewContentText(r.text))
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(data), nil
+}
+
+func libretranslate(url, key string, r *request) (string, error) {
+	if url == "" {
+		return "", errNoLibre
+	}
+	body, err := json.Marshal(map[string]string{
+		"q":       r.text,
+		"source":  langs[r.src].code,
+		"target":  langs[r.tgt].code,
+		"format":  "text",
+		"api_key": key,
+	})
+	if err != nil {
+		return "", err
+	}
+	data, err := web.RequestDataWithHeaders(web.NewDefaultClient(), strings.TrimSuffix(url, "/")+"/translate", "POST", func(req *http.Request) error {
+		req.Header.Set("Content-Type", "application/json")
+		return nil
+	}, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	res := gjson.ParseBytes(data)
+	if e := res.Get("error"); e.Exists() {
+		return "", errors.New(e.String())
+	}
+	return res.Get("translatedText").String(), nil
+}
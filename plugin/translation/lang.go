@@ -0,0 +1,102 @@
+package translation
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// lang 支持的语言, 下标存入群数据, 0 表示未设置
+type lang struct {
+	code string
+	name string
+}
+
+var langs = [...]lang{
+	{"auto", "自动"},
+	{"zh", "中文"},
+	{"en", "英语"},
+	{"ja", "日语"},
+	{"ko", "韩语"},
+	{"fr", "法语"},
+	{"de", "德语"},
+	{"ru", "俄语"},
+	{"es", "西班牙语"},
+	{"it", "意大利语"},
+	{"pt", "葡萄牙语"},
+	{"ar", "阿拉伯语"},
+}
+
+const (
+	langauto = iota
+	langzh
+	langen
+	langja
+	langko
+	_
+	_
+	langru
+)
+
+var errUnknownLang = errors.New("未知语言")
+
+// findlang 按代码或中文名查找语言
+func findlang(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	for i, l := range langs {
+		if strings.EqualFold(l.code, s) || l.name == s || strings.TrimSuffix(l.name, "语") == s {
+			return i, nil
+		}
+	}
+	return 0, errUnknownLang
+}
+
+// parseflag 解析 -目标 或 -源:目标 形式的语言选项
+func parseflag(flag string) (src, tgt int, err error) {
+	s, t, ok := strings.Cut(flag, ":")
+	if !ok {
+		s, t = "auto", s
+	}
+	src, err = findlang(s)
+	if err != nil {
+		return
+	}
+	tgt, err = findlang(t)
+	if err == nil && tgt == langauto {
+		err = errUnknownLang
+	}
+	return
+}
+
+// detect 按文字种类粗略识别语言, 无法识别时返回 langauto
+func detect(s string) int {
+	var han, kana, hangul, cyrillic, latin int
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	switch {
+	case kana > 0:
+		return langja
+	case hangul > 0 && hangul >= han:
+		return langko
+	case han > 0 && han*2 >= latin:
+		return langzh
+	case cyrillic > latin:
+		return langru
+	case latin > 0:
+		return langen
+	default:
+		return langauto
+	}
+}
@@ -2,27 +2,200 @@
 package translation
 
 import (
-	"github.com/FloatTech/AnimeAPI/tl"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/tool"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
+	"github.com/sirupsen/logrus"
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/message"
 )
 
 func init() {
-	control.AutoRegister(&ctrl.Options[*zero.Ctx]{
+	en := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
 		DisableOnDefault: false,
-		Brief:            "单词翻译",
-		Help:             ">TL [好|good]",
-	}).OnRegex(`^>TL\s(-.{1,10}? )?(.*)$`).SetBlock(true).Limit(ctxext.LimitByUser).
+		Brief:            "翻译",
+		Help: "- >TL [好|good]\n" +
+			"- >TL -日语 你好 (指定目标语言)\n" +
+			"- >TL -en:zh hello (指定源语言与目标语言)\n" +
+			"- [回复某条消息] >TL [-语言]\n" +
+			"- 翻译语言列表\n" +
+			"- 设置翻译目标语言 日语\n" +
+			"- 设置翻译后端[默认|大模型|LibreTranslate]\n" +
+			"- 设置LibreTranslate地址 http://127.0.0.1:5000 [密钥]\n" +
+			"- 查看翻译配置\n" +
+			"Tips: 默认后端仅支持中英互译; 未指定目标语言时使用本群设置, 原文已是该语言时译为英语",
+		PrivateDataFolder: "translation",
+	})
+	cfgfile := en.DataFolder() + "config.json"
+	if err := cfg.load(cfgfile); err != nil {
+		logrus.Warnln("[translation] 加载配置错误:", err)
+	}
+	tool.Register(en, tool.Tool{
+		Name:   "translate",
+		Desc:   "翻译文本",
+		Schema: `{"type":"object","properties":{"text":{"type":"string","description":"要翻译的文本"},"target":{"type":"string","description":"目标语言代码, 如 zh en ja, 可省略"}},"required":["text"]}`,
+		Data:   "译文 (string)",
+		Handle: func(ctx *zero.Ctx, args json.RawMessage) (any, error) {
+			var p struct {
+				Text   string `json:"text"`
+				Target string `json:"target"`
+			}
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, err
+			}
+			if p.Text == "" {
+				return nil, errors.New("empty text")
+			}
+			r := &request{text: p.Text}
+			if ctx != nil && ctx.Event != nil {
+				r.gid, r.uid = groupkey(ctx), ctx.Event.UserID
+			}
+			if p.Target != "" {
+				tgt, err := findlang(p.Target)
+				if err != nil {
+					return nil, err
+				}
+				r.tgt = tgt
+			}
+			resolve(r)
+			return translate(r)
+		},
+	})
+	en.OnRegex(`^(?:\[CQ:reply,id=(-?\d+)\][\s\S]*?)?>TL(?:\s+-(\S{1,12}))?(?:\s+([\s\S]*))?$`).SetBlock(true).Limit(ctxext.LimitByUser).
 		Handle(func(ctx *zero.Ctx) {
-			msg := []string{ctx.State["regex_matched"].([]string)[2]}
-			data, err := tl.Translate(msg[0])
+			args := ctx.State["regex_matched"].([]string)
+			r := &request{gid: groupkey(ctx), uid: ctx.Event.UserID, text: strings.TrimSpace(args[3])}
+			if args[2] != "" {
+				src, tgt, err := parseflag(args[2])
+				if err != nil {
+					// 不是语言选项, 视为原文的一部分
+					r.text = strings.TrimSpace("-" + args[2] + " " + r.text)
+				} else {
+					r.src, r.tgt = src, tgt
+				}
+			}
+			if args[1] != "" {
+				id, _ := strconv.ParseInt(args[1], 10, 64)
+				if msg := ctx.GetMessage(id); msg.Elements != nil {
+					if t := strings.TrimSpace(msg.Elements.ExtractPlainText()); t != "" {
+						r.text = t
+					}
+				}
+			}
+			if r.text == "" {
+				return
+			}
+			resolve(r)
+			data, err := translate(r)
 			if err != nil {
-				ctx.SendChain(message.Text("ERROR: ", data))
+				ctx.SendChain(message.Text("ERROR: ", err))
 				return
 			}
-			ctx.SendChain(message.Text(data))
+			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text(data))
+		})
+	en.OnFullMatch("翻译语言列表").SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		var sb strings.Builder
+		sb.WriteString("支持的语言:")
+		for _, l := range langs[1:] {
+			sb.WriteString("\n")
+			sb.WriteString(l.code)
+			sb.WriteString(" ")
+			sb.WriteString(l.name)
+		}
+		ctx.SendChain(message.Text(sb.String()))
+	})
+	en.OnRegex(`^设置翻译目标语言\s*(\S+)$`, zero.AdminPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		i, err := findlang(ctx.State["regex_matched"].([]string)[1])
+		if err == nil && i == langauto {
+			err = errUnknownLang
+		}
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		c, ok := ctx.State["manager"].(*ctrl.Control[*zero.Ctx])
+		if !ok {
+			ctx.SendChain(message.Text("找不到服务!"))
+			return
+		}
+		gid := groupkey(ctx)
+		err = c.SetData(gid, (c.GetData(gid)&^0xff)|int64(i))
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("本群翻译目标语言已设置为", langs[i].name))
+	})
+	en.OnRegex(`^设置翻译后端\s*(默认|大模型|LibreTranslate)$`, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		b := ctx.State["regex_matched"].([]string)[1]
+		err := cfg.set(cfgfile, func(c *config) {
+			c.Backend = b
+		})
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("翻译后端已设置为", b))
+	})
+	en.OnRegex(`^设置LibreTranslate地址\s*(\S+)(?:\s+(\S+))?$`, zero.OnlyPrivate, zero.SuperUserPermission).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		args := ctx.State["regex_matched"].([]string)
+		err := cfg.set(cfgfile, func(c *config) {
+			c.LibreURL, c.LibreKey = args[1], args[2]
 		})
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		ctx.SendChain(message.Text("成功"))
+	})
+	en.OnFullMatch("查看翻译配置").SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		b, url, _ := cfg.backend()
+		msg := "翻译后端: " + b + "\n本群目标语言: " + langs[grouptarget(groupkey(ctx))].name
+		if url != "" {
+			msg += "\nLibreTranslate地址: " + url
+		}
+		ctx.SendChain(message.Text(msg))
+	})
+}
+
+func groupkey(ctx *zero.Ctx) int64 {
+	if ctx.Event.GroupID != 0 {
+		return ctx.Event.GroupID
+	}
+	return -ctx.Event.UserID
+}
+
+// grouptarget 群设置的目标语言, 默认中文
+func grouptarget(gid int64) int {
+	if c, ok := control.Lookup("translation"); ok {
+		if i := int(c.GetData(gid) & 0xff); i > langauto && i < len(langs) {
+			return i
+		}
+	}
+	return langzh
+}
+
+// resolve 补全未指定的目标语言, 原文已是目标语言时改译为英语或中文
+func resolve(r *request) {
+	if r.tgt != langauto {
+		return
+	}
+	r.tgt = grouptarget(r.gid)
+	src := r.src
+	if src == langauto {
+		src = detect(r.text)
+	}
+	if src == r.tgt {
+		if r.tgt == langen {
+			r.tgt = langzh
+		} else {
+			r.tgt = langen
+		}
+	}
 }
@@ -2,6 +2,7 @@
 package wallet
 
 import (
+	"encoding/json"
 	"math"
 	"os"
 	"regexp"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/FloatTech/AnimeAPI/wallet"
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/aichat/tool"
 	"github.com/FloatTech/floatbox/binary"
 	"github.com/FloatTech/floatbox/file"
 	ctrl "github.com/FloatTech/zbpctrl"
@@ -189,6 +191,29 @@ func init() {
 			ctx.SendChain(message.Reply(ctx.Event.MessageID), message.Text("钱包余额修改成功，已修改用户:", uidStr, "的钱包，修改金额为：", amount))
 		})
 
+	tool.Register(en, tool.Tool{
+		Name:   "wallet_balance",
+		Desc:   "查看钱包余额",
+		Schema: `{"type":"object","properties":{"user_id":{"type":"integer","description":"要查询的QQ号, 不填为发送者"}}}`,
+		Data:   "user_id QQ号 (number)；balance 余额 (number)；unit 货币名称 (string)",
+		Handle: func(ctx *zero.Ctx, args json.RawMessage) (any, error) {
+			var p struct {
+				UserID int64 `json:"user_id"`
+			}
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, err
+			}
+			if p.UserID == 0 {
+				p.UserID = ctx.Event.UserID
+			}
+			return map[string]any{
+				"user_id": p.UserID,
+				"balance": wallet.GetWalletOf(p.UserID),
+				"unit":    wallet.GetWalletName(),
+			}, nil
+		},
+	})
+
 	// 保留用户习惯,兼容旧语法“查看我的钱包”
 	en.OnPrefixGroup([]string{`查看钱包余额`, `查看我的钱包`}).SetBlock(true).Limit(ctxext.LimitByGroup).
 		Handle(func(ctx *zero.Ctx) {
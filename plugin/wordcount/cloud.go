@@ -0,0 +1,96 @@
+package wordcount
+
+import (
+	"image"
+	"math"
+
+	"github.com/FloatTech/gg"
+)
+
+const (
+	cloudWidth   = 1000
+	cloudHeight  = 700
+	cloudMinFont = 16.0
+	cloudMaxFont = 96.0
+)
+
+// cloudColors 词云配色
+var cloudColors = [...]string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#17becf", "#bcbd22", "#7f7f7f",
+}
+
+type rect struct {
+	x0, y0, x1, y1 float64
+}
+
+func (r rect) overlaps(o rect) bool {
+	return r.x0 < o.x1 && o.x0 < r.x1 && r.y0 < o.y1 && o.y0 < r.y1
+}
+
+func (r rect) inside(w, h float64) bool {
+	return r.x0 >= 0 && r.y0 >= 0 && r.x1 <= w && r.y1 <= h
+}
+
+// drawWordCloud 绘制词云, wc 需按词频降序排列
+func drawWordCloud(fontdata []byte, title string, wc pairlist) (image.Image, error) {
+	canvas := gg.NewContext(cloudWidth, cloudHeight)
+	canvas.SetRGB(1, 1, 1)
+	canvas.Clear()
+
+	if err := canvas.ParseFontFace(fontdata, 24); err != nil {
+		return nil, err
+	}
+	canvas.SetRGB(0.2, 0.2, 0.2)
+	canvas.DrawStringAnchored(title, cloudWidth/2, 24, 0.5, 0.5)
+
+	top := 48.0
+	w, h := float64(cloudWidth), float64(cloudHeight)
+	cx, cy := w/2, (h+top)/2
+	maxv, minv := float64(wc[0].Value), float64(wc[len(wc)-1].Value)
+	placed := make([]rect, 0, len(wc)+1)
+	// 标题区域不可放置
+	placed = append(placed, rect{0, 0, w, top})
+
+	for i, p := range wc {
+		size := cloudMaxFont
+		if maxv > minv {
+			// 取平方根使字号差距不至于过于悬殊
+			size = cloudMinFont + (cloudMaxFont-cloudMinFont)*math.Sqrt((float64(p.Value)-minv)/(maxv-minv))
+		}
+		if err := canvas.ParseFontFace(fontdata, size); err != nil {
+			return nil, err
+		}
+		tw, th := canvas.MeasureString(p.Key)
+		tw += 6
+		th += 6
+		// 沿阿基米德螺线寻找空位
+		for t := 0.0; t < 200*math.Pi; t += 0.1 {
+			r := 4 * t
+			x := cx + r*math.Cos(t)*w/h
+			y := cy + r*math.Sin(t)
+			box := rect{x - tw/2, y - th/2, x + tw/2, y + th/2}
+			if !box.inside(w, h) {
+				if r > w {
+					break
+				}
+				continue
+			}
+			ok := true
+			for _, o := range placed {
+				if box.overlaps(o) {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			placed = append(placed, box)
+			canvas.SetHexColor(cloudColors[i%len(cloudColors)])
+			canvas.DrawStringAnchored(p.Key, x, y, 0.5, 0.5)
+			break
+		}
+	}
+	return canvas.Image(), nil
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/fumiama/jieba"
 	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
 	"github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/wcharczuk/go-chart/v2"
@@ -20,6 +21,7 @@ import (
 	"github.com/FloatTech/floatbox/binary"
 	fcext "github.com/FloatTech/floatbox/ctxext"
 	"github.com/FloatTech/floatbox/file"
+	"github.com/FloatTech/gg/factory"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
@@ -32,13 +34,18 @@ import (
 var (
 	re        = regexp.MustCompile(`^[一-龥]+$`)
 	stopwords []string
+	seg       *jieba.Segmenter
+	wdb       *worddb
 )
 
 func init() {
 	engine := control.AutoRegister(&ctrl.Options[*zero.Ctx]{
 		DisableOnDefault: false,
 		Brief:            "聊天热词",
-		Help:             "- 热词 [消息数目]|热词 1000",
+		Help: "- 热词 [消息数目]|热词 1000\n" +
+			"- 热词 [今日|昨日|本周|本月|今年|近N天] [@某人]\n" +
+			"- 词云 [今日|昨日|本周|本月|今年|近N天] [@某人]\n" +
+			"Tips: 插件启用后会实时统计群消息词频, 不带消息数目时直接按时间段查询统计结果, 默认为今日",
 		PublicDataFolder: "WordCount",
 	})
 	cachePath := engine.DataFolder() + "cache/"
@@ -46,40 +53,49 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	seg, err := jieba.LoadDictionary(bytes.NewReader(dat))
+	seg, err = jieba.LoadDictionary(bytes.NewReader(dat))
+	if err != nil {
+		panic(err)
+	}
+	wdb, err = newworddb(engine.DataFolder() + "wordcount.db")
 	if err != nil {
 		panic(err)
 	}
 	_ = os.RemoveAll(cachePath)
 	_ = os.MkdirAll(cachePath, 0755)
-	engine.OnRegex(`^热词\s?(\d*)$`, zero.OnlyGroup, fcext.DoOnceOnSuccess(func(ctx *zero.Ctx) bool {
+	getstopwords := fcext.DoOnceOnSuccess(func(ctx *zero.Ctx) bool {
 		_, err := engine.GetLazyData("stopwords.txt", false)
-		if err != nil {
-			ctx.SendChain(message.Text("ERROR: ", err))
-			return false
+		if err == nil {
+			var data []byte
+			data, err = os.ReadFile(engine.DataFolder() + "stopwords.txt")
+			if err == nil {
+				stopwords = strings.Split(strings.ReplaceAll(binary.BytesToString(data), "\r", ""), "\n")
+				sort.Strings(stopwords)
+				logrus.Infoln("[wordcount]加载", len(stopwords), "条停用词")
+				return true
+			}
 		}
-		data, err := os.ReadFile(engine.DataFolder() + "stopwords.txt")
-		if err != nil {
+		logrus.Warnln("[wordcount] 加载停用词失败:", err)
+		// 仅在主动查询时提示, 避免每条群消息都报错
+		if _, ok := ctx.State["regex_matched"]; ok {
 			ctx.SendChain(message.Text("ERROR: ", err))
-			return false
 		}
-		stopwords = strings.Split(strings.ReplaceAll(binary.BytesToString(data), "\r", ""), "\n")
-		sort.Strings(stopwords)
-		logrus.Infoln("[wordcount]加载", len(stopwords), "条停用词")
-		return true
-	})).Limit(ctxext.LimitByUser).SetBlock(true).
+		return false
+	})
+	engine.OnMessage(zero.OnlyGroup, getstopwords).SetBlock(false).
 		Handle(func(ctx *zero.Ctx) {
-			_, err := file.GetLazyData(text.FontFile, control.Md5File, true)
-			if err != nil {
-				ctx.SendChain(message.Text("ERROR: ", err))
+			words := cutWords(ctx.ExtractPlainText())
+			if len(words) == 0 {
 				return
 			}
-			b, err := os.ReadFile(text.FontFile)
+			err := wdb.add(ctx.Event.GroupID, ctx.Event.UserID, time.Unix(ctx.Event.Time, 0), words)
 			if err != nil {
-				ctx.SendChain(message.Text("ERROR: ", err))
-				return
+				logrus.Warnln("[wordcount] 记录词频失败:", err)
 			}
-			font, err := freetype.ParseFont(b)
+		})
+	engine.OnRegex(`^热词\s?(\d+)$`, zero.OnlyGroup, getstopwords).Limit(ctxext.LimitByUser).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			font, err := loadChartFont()
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
 				return
@@ -108,16 +124,9 @@ func init() {
 			messageMap := make(map[string]int, 256)
 			h := ctx.GetGroupMessageHistory(gid, 0, p, false)
 			h.Get("messages").ForEach(func(_, msgObj gjson.Result) bool {
-				tex := strings.TrimSpace(message.ParseMessageFromString(msgObj.Get("raw_message").Str).ExtractPlainText())
-				if tex != "" {
-					words := seg.Cut(tex, true)
-					for _, word := range words {
-						word = strings.TrimSpace(word)
-						i := sort.SearchStrings(stopwords, word)
-						if re.MatchString(word) && (i >= len(stopwords) || stopwords[i] != word) {
-							messageMap[word]++
-						}
-					}
+				tex := message.ParseMessageFromString(msgObj.Get("raw_message").Str).ExtractPlainText()
+				for word, c := range cutWords(tex) {
+					messageMap[word] += c
 				}
 				return true
 			})
@@ -131,31 +140,13 @@ func init() {
 				ctx.SendChain(message.Text("ERROR: 历史消息为空或者无法获得历史消息"))
 				return
 			}
-			bars := make([]chart.Value, len(wc))
-			for i, v := range wc {
-				bars[i] = chart.Value{
-					Value: float64(v.Value),
-					Label: v.Key,
-				}
-			}
-			graph := chart.BarChart{
-				Font:  font,
-				Title: fmt.Sprintf("%s(%d)在%s号的%d条消息的热词top20", group.Name, gid, time.Now().Format("2006-01-02"), p),
-				Background: chart.Style{
-					Padding: chart.Box{
-						Top: 40,
-					},
-				},
-				Height:   500,
-				BarWidth: 25,
-				Bars:     bars,
-			}
+			title := fmt.Sprintf("%s(%d)在%s号的%d条消息的热词top20", group.Name, gid, time.Now().Format("2006-01-02"), p)
 			f, err := os.Create(drawedFile)
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
 				return
 			}
-			err = graph.Render(chart.PNG, f)
+			err = barChart(font, title, wc).Render(chart.PNG, f)
 			_ = f.Close()
 			if err != nil {
 				_ = os.Remove(drawedFile)
@@ -164,6 +155,150 @@ func init() {
 			}
 			ctx.SendChain(message.Image("file:///" + file.BOTPATH + "/" + drawedFile))
 		})
+	engine.OnRegex(`^(热词|词云)\s*(今日|今天|昨日|昨天|本周|本月|今年|近\d+天)?\s*(\[CQ:at,(?:\S*,)?qq=(\d+)(?:,\S*)?\])?\s*$`, zero.OnlyGroup, getstopwords).
+		Limit(ctxext.LimitByUser).SetBlock(true).Handle(func(ctx *zero.Ctx) {
+		matched := ctx.State["regex_matched"].([]string)
+		gid := ctx.Event.GroupID
+		uid, _ := strconv.ParseInt(matched[4], 10, 64)
+		period := matched[2]
+		if period == "" {
+			period = "今日"
+		}
+		from, to := periodRange(period, time.Now())
+		n := 20
+		if matched[1] == "词云" {
+			n = 100
+		}
+		wc, err := wdb.top(gid, uid, from, to, n)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if len(wc) == 0 {
+			ctx.SendChain(message.Text("ERROR: ", period, "暂无词频统计数据"))
+			return
+		}
+		who := ctx.GetGroupInfo(gid, false).Name
+		if uid != 0 {
+			who = ctx.CardOrNickName(uid)
+		}
+		title := fmt.Sprintf("%s在%s(%s~%s)的热词", who, period, from.Format("01-02"), to.Format("01-02"))
+		if matched[1] == "词云" {
+			fontdata, err := file.GetLazyData(text.FontFile, control.Md5File, true)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			img, err := drawWordCloud(fontdata, title, wc)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			data, err := factory.ToBytes(img)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			if id := ctx.SendChain(message.ImageBytes(data)); id.ID() == 0 {
+				ctx.SendChain(message.Text("ERROR: 可能被风控了"))
+			}
+			return
+		}
+		font, err := loadChartFont()
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		var buf bytes.Buffer
+		err = barChart(font, title+"top20", wc).Render(chart.PNG, &buf)
+		if err != nil {
+			ctx.SendChain(message.Text("ERROR: ", err))
+			return
+		}
+		if id := ctx.SendChain(message.ImageBytes(buf.Bytes())); id.ID() == 0 {
+			ctx.SendChain(message.Text("ERROR: 可能被风控了"))
+		}
+	})
+}
+
+// cutWords 分词并过滤停用词, 返回各词出现次数
+func cutWords(tex string) map[string]int {
+	tex = strings.TrimSpace(tex)
+	if tex == "" {
+		return nil
+	}
+	m := make(map[string]int, 16)
+	for _, word := range seg.Cut(tex, true) {
+		word = strings.TrimSpace(word)
+		i := sort.SearchStrings(stopwords, word)
+		if re.MatchString(word) && (i >= len(stopwords) || stopwords[i] != word) {
+			m[word]++
+		}
+	}
+	return m
+}
+
+// periodRange 将时间段描述转换为起止日期
+func periodRange(period string, now time.Time) (from, to time.Time) {
+	to = now
+	switch period {
+	case "昨日", "昨天":
+		to = now.AddDate(0, 0, -1)
+		from = to
+	case "本周":
+		wd := int(now.Weekday())
+		if wd == 0 {
+			wd = 7
+		}
+		from = now.AddDate(0, 0, 1-wd)
+	case "本月":
+		from = now.AddDate(0, 0, 1-now.Day())
+	case "今年":
+		from = now.AddDate(0, 0, 1-now.YearDay())
+	default:
+		from = now
+		if strings.HasPrefix(period, "近") {
+			n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(period, "近"), "天"))
+			if n > 1 {
+				from = now.AddDate(0, 0, 1-n)
+			}
+		}
+	}
+	return
+}
+
+func loadChartFont() (*truetype.Font, error) {
+	_, err := file.GetLazyData(text.FontFile, control.Md5File, true)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(text.FontFile)
+	if err != nil {
+		return nil, err
+	}
+	return freetype.ParseFont(b)
+}
+
+func barChart(font *truetype.Font, title string, wc pairlist) chart.BarChart {
+	bars := make([]chart.Value, len(wc))
+	for i, v := range wc {
+		bars[i] = chart.Value{
+			Value: float64(v.Value),
+			Label: v.Key,
+		}
+	}
+	return chart.BarChart{
+		Font:  font,
+		Title: title,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top: 40,
+			},
+		},
+		Height:   500,
+		BarWidth: 25,
+		Bars:     bars,
+	}
 }
 
 func rankByWordCount(wordFrequencies map[string]int) pairlist {
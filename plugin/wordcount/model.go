@@ -0,0 +1,96 @@
+package wordcount
+
+import (
+	"fmt"
+	"hash/crc64"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FloatTech/floatbox/binary"
+	sql "github.com/FloatTech/sqlite"
+)
+
+const wordtable = "words"
+
+// wordstat 某群某人某天某个词的出现次数
+type wordstat struct {
+	ID    int64  `db:"id"`    // ID gid_uid_day_word 的 crc64
+	Gid   int64  `db:"gid"`   // Gid 群号
+	UID   int64  `db:"uid"`   // UID 发言人
+	Day   int64  `db:"day"`   // Day 形如 20060102
+	Word  string `db:"word"`  // Word 词
+	Count int64  `db:"count"` // Count 次数
+}
+
+// worddb 词频数据库
+type worddb struct {
+	sync.RWMutex
+	sql.Sqlite
+}
+
+var crctab = crc64.MakeTable(crc64.ISO)
+
+func newworddb(dbpath string) (*worddb, error) {
+	db := &worddb{Sqlite: sql.New(dbpath)}
+	err := db.Open(time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Create(wordtable, &wordstat{})
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_words_gid_day ON " + wordtable + " (gid, day);")
+	return db, err
+}
+
+// dayof 将时间转为 20060102 形式的整数
+func dayof(t time.Time) int64 {
+	d, _ := strconv.ParseInt(t.Format("20060102"), 10, 64)
+	return d
+}
+
+// add 累加一条消息中各词的出现次数
+func (db *worddb) add(gid, uid int64, t time.Time, words map[string]int) error {
+	day := dayof(t)
+	db.Lock()
+	defer db.Unlock()
+	for w, c := range words {
+		id := int64(crc64.Checksum(binary.StringToBytes(fmt.Sprintf("%d_%d_%d_%s", gid, uid, day, w)), crctab))
+		_, err := db.Exec(
+			"INSERT INTO "+wordtable+" (id, gid, uid, day, word, count) VALUES (?, ?, ?, ?, ?, ?) "+
+				"ON CONFLICT(id) DO UPDATE SET count = count + excluded.count;",
+			id, gid, uid, day, w, c,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// top 查询时间段 [from, to] 内的前 n 个热词, uid 为 0 时统计全群
+func (db *worddb) top(gid, uid int64, from, to time.Time, n int) (pairlist, error) {
+	q := "SELECT word, SUM(count) AS total FROM " + wordtable + " WHERE gid = ? AND day >= ? AND day <= ?"
+	args := []any{gid, dayof(from), dayof(to)}
+	if uid != 0 {
+		q += " AND uid = ?"
+		args = append(args, uid)
+	}
+	q += " GROUP BY word ORDER BY total DESC LIMIT " + strconv.Itoa(n) + ";"
+	db.RLock()
+	defer db.RUnlock()
+	ps, err := sql.QueryAll[pair](&db.Sqlite, q, args...)
+	if err == sql.ErrNullResult {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pl := make(pairlist, len(ps))
+	for i, p := range ps {
+		pl[i] = *p
+	}
+	return pl, nil
+}
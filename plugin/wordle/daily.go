@@ -0,0 +1,95 @@
+package wordle
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 每日一词的编号从这一天开始计算
+var dailyEpoch = time.Date(2021, 6, 19, 0, 0, 0, 0, time.Local)
+
+// dailyfile 当天各人的每日猜单词结果, 在 init 中设置
+var dailyfile string
+
+// dailyState 当天的每日猜单词结果
+type dailyState struct {
+	sync.Mutex `json:"-"`
+	Date       string           `json:"date"`
+	Results    map[int64]string `json:"results"` // QQ: 分享文本, 进行中时为占位文本
+}
+
+var daily dailyState
+
+// dailyWord 当天的单词与编号, 所有群相同
+func dailyWord(now time.Time) (string, int) {
+	list := words[5].cet4
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(now.Format("2006-01-02")))
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	i := int(h.Sum32() % uint32(len(list)))
+	// 字典末尾的换行会切出空行
+	for list[i] == "" {
+		i = (i + 1) % len(list)
+	}
+	return list[i], int(day.Sub(dailyEpoch).Hours()/24) + 1
+}
+
+// load 读取 date 当天的结果, 跨天时清空. 调用时需持有锁
+func (d *dailyState) load(date string) {
+	if d.Date == "" {
+		if data, err := os.ReadFile(dailyfile); err == nil {
+			_ = json.Unmarshal(data, d)
+		}
+	}
+	if d.Date != date {
+		d.Date = date
+		d.Results = make(map[int64]string)
+	}
+}
+
+// write 保存到文件. 调用时需持有锁
+func (d *dailyState) write() error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dailyfile, data, 0644)
+}
+
+// start 记录某人 date 当天开始游戏, 已开始过时返回之前的结果与 false
+func (d *dailyState) start(uid int64, date, placeholder string) (string, bool) {
+	d.Lock()
+	defer d.Unlock()
+	d.load(date)
+	if prev, ok := d.Results[uid]; ok {
+		return prev, false
+	}
+	d.Results[uid] = placeholder
+	// 保存失败时仍以内存中的记录为准
+	_ = d.write()
+	return "", true
+}
+
+// save 保存某人 date 当天的结果, 已跨天时不再记录
+func (d *dailyState) save(uid int64, date, share string) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.Date != date {
+		return nil
+	}
+	d.Results[uid] = share
+	return d.write()
+}
+
+// shareText 每日猜单词的分享文本
+func shareText(no int, win bool, tries, chances int, grid string) string {
+	score := "X"
+	if win {
+		score = strconv.Itoa(tries)
+	}
+	return "每日猜单词 #" + strconv.Itoa(no) + " " + score + "/" + strconv.Itoa(chances) + "\n" + grid
+}
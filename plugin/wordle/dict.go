@@ -0,0 +1,179 @@
+package wordle
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FloatTech/floatbox/web"
+)
+
+const (
+	minWordLen = 3
+	maxWordLen = 10
+)
+
+// customdir 各群上传的词库, 位于 custom/群号/词库名.txt, 每行为 单词 释义
+var customdir string
+
+// entry 词库中的一个单词
+type entry struct {
+	word    string
+	meaning string
+}
+
+// customDict 群自定义词库
+type customDict []entry
+
+func dictpath(gid int64, name string) string {
+	return customdir + strconv.FormatInt(gid, 10) + "/" + name + ".txt"
+}
+
+// parseDict 解析上传的词库, 返回词库与无效的行数
+func parseDict(s string) (d customDict, invalid int) {
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		word, meaning, _ := strings.Cut(strings.NewReplacer("\t", " ", ":", " ", "：", " ").Replace(line), " ")
+		word = strings.ToLower(word)
+		if !isWord(word) {
+			invalid++
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		d = append(d, entry{word: word, meaning: strings.TrimSpace(meaning)})
+	}
+	return
+}
+
+// isWord 单词只能由字母组成, 长度在 minWordLen 与 maxWordLen 之间
+func isWord(s string) bool {
+	n := utf8.RuneCountInString(s)
+	if n < minWordLen || n > maxWordLen {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d customDict) String() string {
+	var sb strings.Builder
+	for _, e := range d {
+		sb.WriteString(e.word)
+		if e.meaning != "" {
+			sb.WriteString("\t")
+			sb.WriteString(e.meaning)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func saveDict(gid int64, name string, d customDict) error {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return errors.New("词库名不能为空或含有 / \\ .")
+	}
+	err := os.MkdirAll(customdir+strconv.FormatInt(gid, 10), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dictpath(gid, name), []byte(d.String()), 0644)
+}
+
+func loadDict(gid int64, name string) (customDict, error) {
+	if strings.ContainsAny(name, `/\.`) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(dictpath(gid, name))
+	if err != nil {
+		return nil, err
+	}
+	d, _ := parseDict(string(data))
+	return d, nil
+}
+
+// listDicts 群内的自定义词库
+func listDicts(gid int64) []string {
+	entries, err := os.ReadDir(customdir + strconv.FormatInt(gid, 10))
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".txt"); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// pick 随机选出一个单词, length 为 0 时不限长度
+func (d customDict) pick(length int) (entry, bool) {
+	candidates := make([]entry, 0, len(d))
+	for _, e := range d {
+		if length == 0 || utf8.RuneCountInString(e.word) == length {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return entry{}, false
+	}
+	return candidates[rand.Intn(len(candidates))], true
+}
+
+func (d customDict) contains(word string) bool {
+	for _, e := range d {
+		if e.word == word {
+			return true
+		}
+	}
+	return false
+}
+
+// define 从在线词典查询英文单词的释义, 最多返回 3 条
+func define(word string) (string, error) {
+	data, err := web.GetData("https://api.dictionaryapi.dev/api/v2/entries/en/" + word)
+	if err != nil {
+		return "", err
+	}
+	var entries []struct {
+		Meanings []struct {
+			PartOfSpeech string `json:"partOfSpeech"`
+			Definitions  []struct {
+				Definition string `json:"definition"`
+			} `json:"definitions"`
+		} `json:"meanings"`
+	}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, 3)
+	for _, e := range entries {
+		for _, m := range e.Meanings {
+			if len(m.Definitions) == 0 || len(lines) >= 3 {
+				continue
+			}
+			lines = append(lines, m.PartOfSpeech+". "+m.Definitions[0].Definition)
+		}
+	}
+	if len(lines) == 0 {
+		return "", errors.New("no definition")
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -6,23 +6,30 @@ import (
 	"fmt"
 	"image/color"
 	"math/rand"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/FloatTech/AnimeAPI/tl"
 	"github.com/FloatTech/gg/factory"
 
 	"github.com/FloatTech/floatbox/binary"
 	fcext "github.com/FloatTech/floatbox/ctxext"
+	"github.com/FloatTech/floatbox/file"
 	"github.com/FloatTech/gg"
 	ctrl "github.com/FloatTech/zbpctrl"
 	"github.com/FloatTech/zbputils/control"
 	"github.com/FloatTech/zbputils/ctxext"
+	"github.com/FloatTech/zbputils/img/text"
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/message"
+
+	"github.com/FloatTech/ZeroBot-Plugin/plugin/gamerank/stats"
 )
 
 var (
@@ -66,11 +73,18 @@ func init() {
 		Help: "- 个人猜单词\n" +
 			"- 团队猜单词\n" +
 			"- 团队六阶猜单词\n" +
-			"- 团队七阶猜单词",
+			"- 团队七阶猜单词\n" +
+			"- [个人|团队][五阶|六阶|七阶]猜[词库名]单词 (使用本群上传的词库, 不指定阶数时长度随机)\n" +
+			"- 每日猜单词 (私聊发送, 所有人当天的单词相同, 每人每天一次)\n" +
+			"- 词库列表\n" +
+			"- [群管]上传词库[词库名] 每行一个单词, 单词后可跟释义\n" +
+			"- [群管]删除词库[词库名]",
 		PublicDataFolder: "Wordle",
 	}).ApplySingle(ctxext.NewGroupSingle("已经有正在进行的游戏..."))
+	customdir = en.DataFolder() + "custom/"
+	dailyfile = en.DataFolder() + "daily.json"
 
-	en.OnRegex(`^(个人|团队)(五阶|六阶|七阶)?猜单词$`, zero.OnlyGroup, fcext.DoOnceOnSuccess(
+	getdict := fcext.DoOnceOnSuccess(
 		func(ctx *zero.Ctx) bool {
 			var errcnt uint32
 			var wg sync.WaitGroup
@@ -115,137 +129,318 @@ func init() {
 			}
 			return true
 		},
-	)).SetBlock(true).Limit(ctxext.LimitByUser).
+	)
+
+	en.OnRegex(`^(个人|团队)(五阶|六阶|七阶)?猜(\S*)单词$`, zero.OnlyGroup, getdict).SetBlock(true).Limit(ctxext.LimitByUser).
 		Handle(func(ctx *zero.Ctx) {
-			class := classdict[ctx.State["regex_matched"].([]string)[2]]
-			target := words[class].cet4[rand.Intn(len(words[class].cet4))]
-			tt, err := tl.Translate(target)
+			regex := ctx.State["regex_matched"].([]string)
+			personal := regex[1] == "个人"
+			if regex[3] == "" {
+				class := classdict[regex[2]]
+				target := words[class].cet4[rand.Intn(len(words[class].cet4))]
+				play(ctx, target, func() string { return builtinMeaning(target) }, personal, builtinValid(class))
+				return
+			}
+			d, err := loadDict(ctx.Event.GroupID, regex[3])
+			if os.IsNotExist(err) {
+				ctx.SendChain(message.Text("没有这个词库哦, 发送\"词库列表\"查看本群的词库"))
+				return
+			}
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			length := 0
+			if regex[2] != "" {
+				length = classdict[regex[2]]
+			}
+			e, ok := d.pick(length)
+			if !ok {
+				ctx.SendChain(message.Text("词库[", regex[3], "]中没有符合长度的单词"))
+				return
+			}
+			class := utf8.RuneCountInString(e.word)
+			play(ctx, e.word, func() string { return e.meaning }, personal, func(s string) bool {
+				return d.contains(s) || builtinValid(class)(s)
+			})
+		})
+	en.OnFullMatch("每日猜单词", getdict).SetBlock(true).Limit(ctxext.LimitByUser).
+		Handle(func(ctx *zero.Ctx) {
+			// 所有人当天的单词相同, 在群里猜会把答案剧透给其他人
+			if ctx.Event.GroupID != 0 {
+				ctx.SendChain(message.Text("为了不剧透, 请私聊我发送\"每日猜单词\"哦~"))
+				return
+			}
+			uid := ctx.Event.UserID
+			now := time.Now()
+			target, no := dailyWord(now)
+			chances := utf8.RuneCountInString(target) + 1
+			// 开始前先占用今天的次数, 中途放弃也算一次
+			date := now.Format("2006-01-02")
+			if prev, ok := daily.start(uid, date, shareText(no, false, 0, chances, "(未完成)")); !ok {
+				ctx.Send(message.ReplyWithMessage(ctx.Event.MessageID, message.Text("你今天已经猜过了, 明天再来吧~\n", prev)))
+				return
+			}
+			win, tries, grid := play(ctx, target, func() string { return builtinMeaning(target) }, true, builtinValid(chances-1))
+			share := shareText(no, win, tries, chances, grid)
+			err := daily.save(uid, date, share)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			ctx.SendChain(message.Text(share, "\n可以复制结果分享到群里哦~"))
+		})
+	en.OnFullMatch("词库列表", zero.OnlyGroup).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			names := listDicts(ctx.Event.GroupID)
+			if len(names) == 0 {
+				ctx.SendChain(message.Text("本群还没有上传词库"))
+				return
+			}
+			ctx.SendChain(message.Text("本群的词库:\n", strings.Join(names, "\n")))
+		})
+	en.OnRegex(`^上传词库\s*(\S+)\s+([\s\S]+)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			regex := ctx.State["regex_matched"].([]string)
+			d, invalid := parseDict(message.UnescapeCQText(regex[2]))
+			if len(d) == 0 {
+				ctx.SendChain(message.Text("没有找到有效的单词, 单词只能由字母组成, 长度为", minWordLen, "~", maxWordLen))
+				return
+			}
+			err := saveDict(ctx.Event.GroupID, regex[1], d)
+			if err != nil {
+				ctx.SendChain(message.Text("ERROR: ", err))
+				return
+			}
+			msg := "成功! 词库[" + regex[1] + "]共" + strconv.Itoa(len(d)) + "个单词"
+			if invalid > 0 {
+				msg += ", 忽略了" + strconv.Itoa(invalid) + "行无效内容"
+			}
+			ctx.SendChain(message.Text(msg))
+		})
+	en.OnRegex(`^删除词库\s*(\S+)$`, zero.OnlyGroup, zero.AdminPermission).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			name := ctx.State["regex_matched"].([]string)[1]
+			if strings.ContainsAny(name, `/\.`) {
+				ctx.SendChain(message.Text("没有这个词库哦"))
+				return
+			}
+			err := os.Remove(dictpath(ctx.Event.GroupID, name))
+			if os.IsNotExist(err) {
+				ctx.SendChain(message.Text("没有这个词库哦"))
+				return
+			}
 			if err != nil {
 				ctx.SendChain(message.Text("ERROR: ", err))
 				return
 			}
-			game := newWordleGame(target)
-			_, img, _ := game("")
+			ctx.SendChain(message.Text("已删除词库[", name, "]"))
+		})
+}
+
+// builtinValid 内置字典中是否有这个单词
+func builtinValid(class int) func(string) bool {
+	return func(s string) bool {
+		i := sort.SearchStrings(words[class].dict, s)
+		return i < len(words[class].dict) && words[class].dict[i] == s
+	}
+}
+
+// builtinMeaning 内置单词的翻译与英文释义, 查不到的部分省略
+func builtinMeaning(target string) string {
+	var lines []string
+	if tt, err := tl.Translate(target); err == nil {
+		lines = append(lines, tt)
+	}
+	if def, err := define(target); err == nil {
+		lines = append(lines, def)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// play 进行一局猜单词, 返回是否猜中, 猜的次数与 emoji 结果. meaning 在结束时才调用
+func play(ctx *zero.Ctx, target string, meaning func() string, personal bool, valid func(string) bool) (bool, int, string) {
+	class := utf8.RuneCountInString(target)
+	answer := func() string {
+		s := "答案是: " + target
+		if m := meaning(); m != "" {
+			s += "\n" + m
+		}
+		return s
+	}
+	game, result := newWordleGame(target, valid)
+	_, img, _ := game("")
+	ctx.Send(
+		message.ReplyWithMessage(ctx.Event.MessageID,
+			message.ImageBytes(img),
+			message.Text("你有", class+1, "次机会猜出单词，单词长度为", class, "，请发送单词"),
+		),
+	)
+	letters := `([A-Z]|[a-z])`
+	for _, r := range target {
+		if r >= utf8.RuneSelf {
+			letters = `\pL`
+			break
+		}
+	}
+	var next *zero.FutureEvent
+	if personal {
+		next = zero.NewFutureEvent("message", 999, false, zero.RegexRule(fmt.Sprintf(`^%s{%d}$`, letters, class)),
+			ctx.CheckSession())
+	} else {
+		next = zero.NewFutureEvent("message", 999, false, zero.RegexRule(fmt.Sprintf(`^%s{%d}$`, letters, class)),
+			zero.OnlyGroup, zero.CheckGroup(ctx.Event.GroupID))
+	}
+	var win bool
+	var err error
+	recv, cancel := next.Repeat()
+	defer cancel()
+	round := stats.NewRound(stats.Wordle, ctx.Event.GroupID)
+	tick := time.NewTimer(105 * time.Second)
+	after := time.NewTimer(120 * time.Second)
+	for {
+		select {
+		case <-tick.C:
+			ctx.SendChain(message.Text("猜单词，你还有15s作答时间"))
+		case <-after.C:
+			round.End(0)
 			ctx.Send(
 				message.ReplyWithMessage(ctx.Event.MessageID,
-					message.ImageBytes(img),
-					message.Text("你有", class+1, "次机会猜出单词，单词长度为", class, "，请发送单词"),
+					message.Text("猜单词超时，游戏结束...", answer()),
 				),
 			)
-			var next *zero.FutureEvent
-			if ctx.State["regex_matched"].([]string)[1] == "个人" {
-				next = zero.NewFutureEvent("message", 999, false, zero.RegexRule(fmt.Sprintf(`^([A-Z]|[a-z]){%d}$`, class)),
-					zero.OnlyGroup, ctx.CheckSession())
-			} else {
-				next = zero.NewFutureEvent("message", 999, false, zero.RegexRule(fmt.Sprintf(`^([A-Z]|[a-z]){%d}$`, class)),
-					zero.OnlyGroup, zero.CheckGroup(ctx.Event.GroupID))
+			tries, grid := result()
+			return false, tries, grid
+		case c := <-recv:
+			tick.Reset(105 * time.Second)
+			after.Reset(120 * time.Second)
+			win, img, err = game(message.UnescapeCQText(c.Event.Message.String()))
+			if err != errLengthNotEnough && err != errUnknownWord {
+				round.Guess(c.Event.UserID)
 			}
-			var win bool
-			recv, cancel := next.Repeat()
-			defer cancel()
-			tick := time.NewTimer(105 * time.Second)
-			after := time.NewTimer(120 * time.Second)
-			for {
-				select {
-				case <-tick.C:
-					ctx.SendChain(message.Text("猜单词，你还有15s作答时间"))
-				case <-after.C:
-					ctx.Send(
-						message.ReplyWithMessage(ctx.Event.MessageID,
-							message.Text("猜单词超时，游戏结束...答案是: ", target, "(", tt, ")"),
-						),
-					)
-					return
-				case c := <-recv:
-					tick.Reset(105 * time.Second)
-					after.Reset(120 * time.Second)
-					win, img, err = game(c.Event.Message.String())
-					switch {
-					case win:
-						tick.Stop()
-						after.Stop()
-						ctx.Send(
-							message.ReplyWithMessage(c.Event.MessageID,
-								message.ImageBytes(img),
-								message.Text("太棒了，你猜出来了！答案是: ", target, "(", tt, ")"),
-							),
-						)
-						return
-					case err == errTimesRunOut:
-						tick.Stop()
-						after.Stop()
-						ctx.Send(
-							message.ReplyWithMessage(c.Event.MessageID,
-								message.ImageBytes(img),
-								message.Text("游戏结束...答案是: ", target, "(", tt, ")"),
-							),
-						)
-						return
-					case err == errLengthNotEnough:
-						ctx.Send(
-							message.ReplyWithMessage(c.Event.MessageID,
-								message.Text("单词长度错误"),
-							),
-						)
-					case err == errUnknownWord:
-						ctx.Send(
-							message.ReplyWithMessage(c.Event.MessageID,
-								message.Text("你确定存在这样的单词吗？"),
-							),
-						)
-					default:
-						ctx.Send(
-							message.ReplyWithMessage(c.Event.MessageID,
-								message.ImageBytes(img),
-							),
-						)
-					}
-				}
+			switch {
+			case win:
+				tick.Stop()
+				after.Stop()
+				round.End(c.Event.UserID)
+				ctx.Send(
+					message.ReplyWithMessage(c.Event.MessageID,
+						message.ImageBytes(img),
+						message.Text("太棒了，你猜出来了！", answer()),
+					),
+				)
+				tries, grid := result()
+				return true, tries, grid
+			case err == errTimesRunOut:
+				tick.Stop()
+				after.Stop()
+				round.End(0)
+				ctx.Send(
+					message.ReplyWithMessage(c.Event.MessageID,
+						message.ImageBytes(img),
+						message.Text("游戏结束...", answer()),
+					),
+				)
+				tries, grid := result()
+				return false, tries, grid
+			case err == errLengthNotEnough:
+				ctx.Send(
+					message.ReplyWithMessage(c.Event.MessageID,
+						message.Text("单词长度错误"),
+					),
+				)
+			case err == errUnknownWord:
+				ctx.Send(
+					message.ReplyWithMessage(c.Event.MessageID,
+						message.Text("你确定存在这样的单词吗？"),
+					),
+				)
+			default:
+				ctx.Send(
+					message.ReplyWithMessage(c.Event.MessageID,
+						message.ImageBytes(img),
+					),
+				)
 			}
-		})
+		}
+	}
+}
+
+// mark 每个字母的猜测结果, 重复字母按目标中剩余的个数标记
+func mark(target, guess []rune) []int {
+	marks := make([]int, len(guess))
+	left := make(map[rune]int, len(target))
+	for j, r := range guess {
+		if r != target[j] {
+			left[target[j]]++
+		}
+	}
+	for j, r := range guess {
+		switch {
+		case r == target[j]:
+			marks[j] = match
+		case left[r] > 0:
+			marks[j] = exist
+			left[r]--
+		default:
+			marks[j] = notexist
+		}
+	}
+	return marks
 }
 
-func newWordleGame(target string) func(string) (bool, []byte, error) {
-	var class = len(target)
-	record := make([]string, 0, len(target)+1)
-	return func(s string) (win bool, data []byte, err error) {
+var emojis = [...]string{match: "🟩", exist: "🟨", notexist: "⬜"}
+
+// newWordleGame 返回猜测函数与结果函数, 结果为已猜次数与 emoji 方格
+func newWordleGame(target string, valid func(string) bool) (func(string) (bool, []byte, error), func() (int, string)) {
+	t := []rune(target)
+	var class = len(t)
+	record := make([][]rune, 0, class+1)
+	var fontdata []byte
+	for _, r := range t {
+		if r >= utf8.RuneSelf {
+			// 默认字体只有 ASCII
+			fontdata, _ = file.GetLazyData(text.FontFile, control.Md5File, true)
+			break
+		}
+	}
+	game := func(s string) (win bool, data []byte, err error) {
 		if s != "" {
 			s = strings.ToLower(s)
 			if target == s {
 				win = true
 			} else {
-				if len(s) != len(target) {
+				if utf8.RuneCountInString(s) != class {
 					err = errLengthNotEnough
 					return
 				}
-				i := sort.SearchStrings(words[class].dict, s)
-				if i >= len(words[class].dict) || words[class].dict[i] != s {
+				if !valid(s) {
 					err = errUnknownWord
 					return
 				}
 			}
-			record = append(record, s)
+			record = append(record, []rune(s))
 		}
 		var side = 20
 		var space = 10
 		ctx := gg.NewContext((side+4)*class+space*2-4, (side+4)*(class+1)+space*2-4)
 		ctx.SetColor(color.RGBA{255, 255, 255, 255})
 		ctx.Clear()
+		if fontdata != nil {
+			_ = ctx.ParseFontFace(fontdata, 14)
+		}
 		for i := 0; i < class+1; i++ {
+			var marks []int
+			if len(record) > i {
+				marks = mark(t, record[i])
+			}
 			for j := 0; j < class; j++ {
 				if len(record) > i {
 					ctx.DrawRectangle(float64(space+j*(side+4)), float64(space+i*(side+4)), float64(side), float64(side))
-					switch {
-					case record[i][j] == target[j]:
-						ctx.SetColor(colors[match])
-					case strings.IndexByte(target, record[i][j]) != -1:
-						ctx.SetColor(colors[exist])
-					default:
-						ctx.SetColor(colors[notexist])
-					}
+					ctx.SetColor(colors[marks[j]])
 					ctx.Fill()
 					ctx.SetColor(color.RGBA{255, 255, 255, 255})
-					ctx.DrawString(strings.ToUpper(string(record[i][j])), float64(10+j*(side+4)+7), float64(10+i*(side+4)+15))
+					ctx.DrawStringAnchored(strings.ToUpper(string(record[i][j])), float64(space+j*(side+4)+side/2), float64(space+i*(side+4)+side/2), 0.5, 0.5)
 				} else {
 					ctx.DrawRectangle(float64(10+j*(side+4)+1), float64(10+i*(side+4)+1), float64(side-2), float64(side-2))
 					ctx.SetLineWidth(1)
@@ -261,4 +456,17 @@ func newWordleGame(target string) func(string) (bool, []byte, error) {
 		}
 		return
 	}
+	result := func() (int, string) {
+		var sb strings.Builder
+		for i, r := range record {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			for _, m := range mark(t, r) {
+				sb.WriteString(emojis[m])
+			}
+		}
+		return len(record), sb.String()
+	}
+	return game, result
 }
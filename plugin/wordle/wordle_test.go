@@ -0,0 +1,113 @@
+package wordle
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDict(t *testing.T) {
+	d, invalid := parseDict("apple 苹果\n\nBanana\t香蕉\napple 重复\nno\nhello world:你好\ncafé：咖啡\nab1cd\n")
+	want := customDict{
+		{word: "apple", meaning: "苹果"},
+		{word: "banana", meaning: "香蕉"},
+		{word: "hello", meaning: "world 你好"},
+		{word: "café", meaning: "咖啡"},
+	}
+	if !slices.Equal(d, want) {
+		t.Errorf("parseDict() = %v, want %v", d, want)
+	}
+	if invalid != 2 {
+		t.Errorf("parseDict() invalid = %d, want 2", invalid)
+	}
+}
+
+func TestIsWord(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "abc", want: true},
+		{in: "ab", want: false},
+		{in: "abcdefghij", want: true},
+		{in: "abcdefghijk", want: false},
+		{in: "café", want: true},
+		{in: "über", want: true},
+		{in: "ab-c", want: false},
+		{in: "abc1", want: false},
+		{in: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := isWord(tt.in); got != tt.want {
+			t.Errorf("isWord(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMark(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		guess  string
+		want   []int
+	}{
+		{name: "all match", target: "apple", guess: "apple", want: []int{match, match, match, match, match}},
+		{name: "none", target: "apple", guess: "dirty", want: []int{notexist, notexist, notexist, notexist, notexist}},
+		{name: "exist", target: "apple", guess: "leapt", want: []int{exist, exist, exist, exist, notexist}},
+		{name: "extra duplicate", target: "apple", guess: "ppppp", want: []int{notexist, match, match, notexist, notexist}},
+		{name: "duplicate exist once", target: "steel", guess: "eeeee", want: []int{notexist, notexist, match, match, notexist}},
+		{name: "duplicate before match", target: "abbey", guess: "kebab", want: []int{notexist, exist, match, exist, exist}},
+		{name: "one of two", target: "crane", guess: "eerie", want: []int{notexist, notexist, exist, notexist, match}},
+		{name: "non ascii", target: "café", guess: "éacf", want: []int{exist, match, exist, exist}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mark([]rune(tt.target), []rune(tt.guess)); !slices.Equal(got, tt.want) {
+				t.Errorf("mark(%q, %q) = %v, want %v", tt.target, tt.guess, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDailyWord(t *testing.T) {
+	old := words
+	defer func() { words = old }()
+	// 字典末尾的换行会切出空行
+	words = dictionary{5: {cet4: []string{"", "", "apple", ""}}}
+	day := time.Date(2021, 6, 19, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 10; i++ {
+		now := day.AddDate(0, 0, i)
+		w, no := dailyWord(now)
+		if w != "apple" {
+			t.Errorf("dailyWord(%s) = %q, want apple", now.Format("2006-01-02"), w)
+		}
+		if no != i+1 {
+			t.Errorf("dailyWord(%s) no = %d, want %d", now.Format("2006-01-02"), no, i+1)
+		}
+	}
+	words = dictionary{5: {cet4: []string{"apple", "bread", "crane", "dance", "eerie"}}}
+	a, _ := dailyWord(day)
+	b, _ := dailyWord(day.Add(11 * time.Hour))
+	if a != b {
+		t.Errorf("dailyWord changed within a day: %q, %q", a, b)
+	}
+}
+
+func TestShareText(t *testing.T) {
+	grid := "⬜🟨⬜⬜⬜\n🟩🟩🟩🟩🟩"
+	tests := []struct {
+		name string
+		win  bool
+		want string
+	}{
+		{name: "win", win: true, want: "每日猜单词 #42 2/6\n" + grid},
+		{name: "lose", win: false, want: "每日猜单词 #42 X/6\n" + grid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shareText(42, tt.win, 2, 6, grid); got != tt.want {
+				t.Errorf("shareText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}